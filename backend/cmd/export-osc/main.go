@@ -0,0 +1,59 @@
+// export-osc は確認済みの障害物をOpenStreetMapの変更ファイル（.osc）として書き出すコマンド
+// 変更ファイルはJOSMで開き、地域のマッパーが現地の状況と fixme を確認してからアップロードする
+// 変更の提案の一覧（JSON）は標準エラー出力に書き出す
+//
+// 使い方:
+//
+//	OBSTACLE_TABLE_NAME=dev-obstacle-table go run ./cmd/export-osc -o obstacles.osc
+//	OBSTACLE_TABLE_NAME=dev-obstacle-table go run ./cmd/export-osc -o obstacles.osc 12 34
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"webhook/usecase"
+	"webhook/usecase/input"
+)
+
+func main() {
+	out := flag.String("o", "", "変更ファイルの書き出し先（省略時は標準出力）")
+	flag.Parse()
+
+	var ids []int
+	for _, arg := range flag.Args() {
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "usage: export-osc [-o FILE] [OBSTACLE_ID...]")
+			os.Exit(2)
+		}
+		ids = append(ids, id)
+	}
+
+	response, _, err := usecase.ExportOSMChange(context.Background(), input.OSMChangeExport{IDs: ids})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to export osm change: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *out == "" {
+		_, err = os.Stdout.Write(response.Body)
+	} else {
+		err = os.WriteFile(*out, response.Body, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write osm change: %v\n", err)
+		os.Exit(1)
+	}
+
+	encoder := json.NewEncoder(os.Stderr)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(response); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write result: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,60 @@
+// image-gc は障害物から参照されていない画像をS3から削除（隔離）するメンテナンス用のコマンド
+//
+// 使い方:
+//
+//	OBSTACLE_TABLE_NAME=dev-obstacle-table OBSTACLE_IMAGE_BUCKET_NAME=dev-obstacle-image-bucket \
+//	  go run ./cmd/image-gc -dry-run
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"webhook/shared/util"
+	"webhook/usecase"
+	"webhook/usecase/input"
+)
+
+func main() {
+	setting := util.GetSetting().ImageGC
+	dryRun := flag.Bool("dry-run", false, "対象の画像を表示するだけで削除・移動しない")
+	grace := flag.Duration("grace", setting.GracePeriod, "アップロードから対象にするまでの猶予期間")
+	mode := flag.String("mode", modeName(setting.Quarantine), "quarantine（隔離用のプレフィックスへ移動）またはdelete（削除）")
+	flag.Parse()
+
+	if *mode != "quarantine" && *mode != "delete" {
+		fmt.Fprintf(os.Stderr, "invalid -mode: %q\n", *mode)
+		os.Exit(2)
+	}
+
+	input := input.ImageGC{
+		GracePeriod: *grace,
+		Quarantine:  *mode == "quarantine",
+		DryRun:      *dryRun,
+	}
+	response, _, err := usecase.CollectOrphanedImages(context.Background(), input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to collect orphaned images: %v\n", err)
+		os.Exit(1)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(response); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write result: %v\n", err)
+		os.Exit(1)
+	}
+	if len(response.FailedKeys) > 0 {
+		os.Exit(1)
+	}
+}
+
+func modeName(quarantine bool) string {
+	if quarantine {
+		return "quarantine"
+	}
+	return "delete"
+}
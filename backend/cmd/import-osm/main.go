@@ -0,0 +1,74 @@
+// import-osm はOpenStreetMapの抽出ファイル（.osm・.osm.pbf）から階段や柵などの地物を障害物として取り込むコマンド
+// 同じ範囲の新しい抽出ファイルで再実行すると、取り込み済みの障害物を更新・解消する
+//
+// 使い方:
+//
+//	OBSTACLE_TABLE_NAME=dev-obstacle-table OBSTACLE_REVISION_TABLE_NAME=dev-obstacle-revision-table \
+//	  COUNTER_TABLE_NAME=dev-counter-table go run ./cmd/import-osm -dry-run tokyo.osm.pbf
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"webhook/shared/osm"
+	"webhook/usecase"
+	"webhook/usecase/input"
+)
+
+func main() {
+	format := flag.String("format", "", "xmlまたはpbf（省略時はファイル名と内容から判別する）")
+	dryRun := flag.Bool("dry-run", false, "変更内容を表示するだけで登録しない")
+	retire := flag.Bool("retire", true, "抽出範囲内で抽出ファイルからなくなった地物の障害物を解消済みにする")
+	actor := flag.String("actor", "osm-import", "変更履歴に記録する操作者")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: import-osm [-format xml|pbf] [-dry-run] [-retire=false] [-actor name] FILE")
+		os.Exit(2)
+	}
+	path := flag.Arg(0)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read %s: %v\n", path, err)
+		os.Exit(1)
+	}
+	if *format == "" {
+		switch {
+		case strings.HasSuffix(path, ".pbf"):
+			*format = osm.FormatPBF
+		case strings.HasSuffix(path, ".osm"):
+			*format = osm.FormatXML
+		}
+	}
+
+	input := input.OSMImport{
+		Audit: input.Audit{
+			Actor:  *actor,
+			Source: "cli import-osm",
+		},
+		Format: *format,
+		Data:   data,
+		DryRun: *dryRun,
+		Retire: *retire,
+	}
+	response, _, err := usecase.ImportOSM(context.Background(), input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to import osm features: %v\n", err)
+		os.Exit(1)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(response); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write result: %v\n", err)
+		os.Exit(1)
+	}
+	if response.Failed > 0 {
+		os.Exit(1)
+	}
+}
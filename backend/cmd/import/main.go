@@ -0,0 +1,61 @@
+// import はGeoJSONのFeatureCollectionまたはCSVから障害物を一括登録するコマンド
+// APIと異なり行数の上限はない
+//
+// 使い方:
+//
+//	OBSTACLE_TABLE_NAME=dev-obstacle-table OBSTACLE_REVISION_TABLE_NAME=dev-obstacle-revision-table \
+//	  COUNTER_TABLE_NAME=dev-counter-table go run ./cmd/import -dry-run obstacles.csv
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"webhook/usecase"
+	"webhook/usecase/input"
+)
+
+func main() {
+	format := flag.String("format", "", "geojsonまたはcsv（省略時はファイルの内容から判別する）")
+	dryRun := flag.Bool("dry-run", false, "行ごとの検証結果を表示するだけで登録しない")
+	actor := flag.String("actor", "import-cli", "変更履歴に記録する操作者")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: import [-format geojson|csv] [-dry-run] [-actor name] FILE")
+		os.Exit(2)
+	}
+	data, err := os.ReadFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read %s: %v\n", flag.Arg(0), err)
+		os.Exit(1)
+	}
+
+	input := input.ObstacleImport{
+		Audit: input.Audit{
+			Actor:  *actor,
+			Source: "cli import",
+		},
+		Format: *format,
+		Data:   data,
+		DryRun: *dryRun,
+	}
+	response, _, err := usecase.ImportObstacles(context.Background(), input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to import obstacles: %v\n", err)
+		os.Exit(1)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(response); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write result: %v\n", err)
+		os.Exit(1)
+	}
+	if response.Failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,35 @@
+// search-reindex は全障害物の説明から全文検索のインデックスを作り直し、位置から検索するGSIのキーを書き込むメンテナンス用のコマンド
+// インデックスの導入前に登録した障害物を検索や地図のタイルに含める場合や、テーブルを復元した場合に実行する
+//
+// 使い方:
+//
+//	OBSTACLE_TABLE_NAME=dev-obstacle-table OBSTACLE_SEARCH_INDEX_TABLE_NAME=dev-obstacle-search-index-table \
+//	  go run ./cmd/search-reindex
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"webhook/usecase"
+)
+
+func main() {
+	response, _, err := usecase.ReindexObstacles(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to reindex obstacles: %v\n", err)
+		os.Exit(1)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(response); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write result: %v\n", err)
+		os.Exit(1)
+	}
+	if len(response.FailedIDs) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,40 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// batchWriteSize はBatchWriteItemで一度に書き込める件数の上限
+	batchWriteSize = 25
+	// batchWriteAttempts は書き込めなかった項目（UnprocessedItems）を再試行する回数
+	batchWriteAttempts = 5
+)
+
+// batchWrite は25件ずつ書き込み、スロットリングなどで書き込めなかった項目は間隔を空けて再試行する
+func batchWrite(ctx context.Context, client *dynamodb.Client, tableName string, requests []types.WriteRequest) error {
+	for start := 0; start < len(requests); start += batchWriteSize {
+		pending := map[string][]types.WriteRequest{
+			tableName: requests[start:min(start+batchWriteSize, len(requests))],
+		}
+		for attempt := 0; len(pending) > 0; attempt++ {
+			if attempt == batchWriteAttempts {
+				return fmt.Errorf("%d items left unprocessed", len(pending[tableName]))
+			}
+			if attempt > 0 {
+				time.Sleep(time.Duration(50<<attempt) * time.Millisecond)
+			}
+			result, err := client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: pending})
+			if err != nil {
+				return err
+			}
+			pending = result.UnprocessedItems
+		}
+	}
+	return nil
+}
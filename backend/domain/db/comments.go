@@ -0,0 +1,21 @@
+package db
+
+// ObstacleComment は障害物へのコメント
+// 削除されたコメントはスレッドの流れが分かるよう、本文を消して削除の記録だけを残す
+type ObstacleComment struct {
+	ObstacleID         int    `json:"obstacle_id" dynamodbav:"obstacle_id"`
+	CommentID          string `json:"comment_id" dynamodbav:"comment_id"` // 投稿日時の順に並ぶID
+	Author             string `json:"author" dynamodbav:"author"`
+	Body               string `json:"body,omitempty" dynamodbav:"body,omitempty"`
+	CreatedAt          string `json:"created_at" dynamodbav:"created_at"`
+	UpdatedAt          string `json:"updated_at" dynamodbav:"updated_at"`
+	EditedAt           string `json:"edited_at,omitempty" dynamodbav:"edited_at,omitempty"`   // 投稿者が本文を編集した日時
+	DeletedAt          string `json:"deleted_at,omitempty" dynamodbav:"deleted_at,omitempty"` // 削除された日時
+	DeletedBy          string `json:"deleted_by,omitempty" dynamodbav:"deleted_by,omitempty"`
+	RemovedByModerator bool   `json:"removed_by_moderator,omitempty" dynamodbav:"removed_by_moderator,omitempty"`
+}
+
+// IsDeleted はコメントが削除されているかを返す
+func (c *ObstacleComment) IsDeleted() bool {
+	return c.DeletedAt != ""
+}
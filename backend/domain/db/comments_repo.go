@@ -0,0 +1,162 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"webhook/shared/util"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var (
+	// ErrCommentConflict はコメントが読み込み後に別のリクエストで更新されたことを表す
+	ErrCommentConflict = errors.New("comment has been modified by another request")
+	// ErrCommentExists は作成しようとしたIDのコメントが既に存在することを表す
+	ErrCommentExists = errors.New("comment with the same id already exists")
+)
+
+type CommentRepo struct {
+	TableName string
+	Client    *dynamodb.Client
+}
+
+func NewCommentRepo(ctx context.Context) (*CommentRepo, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	return &CommentRepo{
+		TableName: util.GetSetting().CommentTable.TableName,
+		Client:    dynamodb.NewFromConfig(cfg),
+	}, nil
+}
+
+// List は障害物のコメントを投稿の古い順に返す（削除されたコメントを含む）
+func (r *CommentRepo) List(ctx context.Context, obstacleID int) ([]ObstacleComment, int, error) {
+	keyCond := expression.Key("obstacle_id").Equal(expression.Value(obstacleID))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	comments := []ObstacleComment{}
+	paginator := dynamodb.NewQueryPaginator(r.Client, &dynamodb.QueryInput{
+		TableName:                 aws.String(r.TableName),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("failed to query obstacle comments: %w", err)
+		}
+
+		var items []ObstacleComment
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("failed to unmarshal obstacle comment: %w", err)
+		}
+		comments = append(comments, items...)
+	}
+	return comments, http.StatusOK, nil
+}
+
+// Get はコメントを返す（存在しない場合はnil）
+func (r *CommentRepo) Get(ctx context.Context, obstacleID int, commentID string) (*ObstacleComment, int, error) {
+	result, err := r.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.TableName),
+		Key: map[string]types.AttributeValue{
+			"obstacle_id": &types.AttributeValueMemberN{Value: strconv.Itoa(obstacleID)},
+			"comment_id":  &types.AttributeValueMemberS{Value: commentID},
+		},
+	})
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("failed to get obstacle comment: %w", err)
+	}
+	if result.Item == nil {
+		return nil, http.StatusNotFound, nil
+	}
+
+	var comment ObstacleComment
+	if err := attributevalue.UnmarshalMap(result.Item, &comment); err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("failed to unmarshal obstacle comment: %w", err)
+	}
+	return &comment, http.StatusOK, nil
+}
+
+// Create は新しいコメントを保存する
+// 同じIDのコメントが既に存在する場合は上書きせずにErrCommentExistsを返す
+func (r *CommentRepo) Create(ctx context.Context, comment *ObstacleComment) (int, error) {
+	cond := expression.AttributeNotExists(expression.Name("comment_id"))
+	if err := r.put(ctx, comment, cond); err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return http.StatusConflict, ErrCommentExists
+		}
+		return http.StatusInternalServerError, fmt.Errorf("failed to create obstacle comment: %w", err)
+	}
+	return http.StatusCreated, nil
+}
+
+// Update はコメントを保存する
+// 読み込み後に別のリクエストで編集・削除されていた場合はErrCommentConflictを返す
+func (r *CommentRepo) Update(ctx context.Context, comment *ObstacleComment, previousUpdatedAt string) (int, error) {
+	cond := expression.Name("updated_at").Equal(expression.Value(previousUpdatedAt))
+	if err := r.put(ctx, comment, cond); err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return http.StatusConflict, ErrCommentConflict
+		}
+		return http.StatusInternalServerError, fmt.Errorf("failed to update obstacle comment: %w", err)
+	}
+	return http.StatusOK, nil
+}
+
+// DeleteAll は障害物のコメントをすべて削除する（障害物の完全削除に使う）
+func (r *CommentRepo) DeleteAll(ctx context.Context, obstacleID int) (int, error) {
+	comments, statusCode, err := r.List(ctx, obstacleID)
+	if err != nil {
+		return statusCode, err
+	}
+	requests := make([]types.WriteRequest, 0, len(comments))
+	for _, comment := range comments {
+		requests = append(requests, types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: map[string]types.AttributeValue{
+			"obstacle_id": &types.AttributeValueMemberN{Value: strconv.Itoa(obstacleID)},
+			"comment_id":  &types.AttributeValueMemberS{Value: comment.CommentID},
+		}}})
+	}
+	if err := batchWrite(ctx, r.Client, r.TableName, requests); err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("failed to delete obstacle comments: %w", err)
+	}
+	return http.StatusOK, nil
+}
+
+func (r *CommentRepo) put(ctx context.Context, comment *ObstacleComment, cond expression.ConditionBuilder) error {
+	item, err := attributevalue.MarshalMap(comment)
+	if err != nil {
+		return fmt.Errorf("failed to marshal obstacle comment: %w", err)
+	}
+	expr, err := expression.NewBuilder().WithCondition(cond).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = r.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                 aws.String(r.TableName),
+		Item:                      item,
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ConditionExpression:       expr.Condition(),
+	})
+	return err
+}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"math"
+	"time"
+)
+
+// ConfirmationVote は利用者による障害物の現況の確認結果
+type ConfirmationVote string
+
+const (
+	ConfirmationStillThere ConfirmationVote = "still_there" // まだある
+	ConfirmationGone       ConfirmationVote = "gone"        // なくなった
+)
+
+// ObstacleConfirmation は利用者ごとの確認の記録（1人1件）
+type ObstacleConfirmation struct {
+	ObstacleID int              `json:"obstacle_id" dynamodbav:"obstacle_id"`
+	UserID     string           `json:"user_id" dynamodbav:"user_id"` // 認証済みの利用者ID（Cognitoのsub）
+	Vote       ConfirmationVote `json:"vote" dynamodbav:"vote"`
+	CreatedAt  string           `json:"created_at" dynamodbav:"created_at"`
+	UpdatedAt  string           `json:"updated_at,omitempty" dynamodbav:"updated_at,omitempty"`
+}
+
+// IsValid は確認結果が既知の値かを返す
+func (v ConfirmationVote) IsValid() bool {
+	return v == ConfirmationStillThere || v == ConfirmationGone
+}
+
+// countAttribute は確認結果ごとの集計値を保存する障害物の属性
+func (v ConfirmationVote) countAttribute() string {
+	if v == ConfirmationGone {
+		return "gone_count"
+	}
+	return "still_there_count"
+}
+
+// lastAttribute は確認結果ごとの最終確認日時を保存する障害物の属性
+func (v ConfirmationVote) lastAttribute() string {
+	if v == ConfirmationGone {
+		return "last_gone_at"
+	}
+	return "last_confirmed_at"
+}
+
+// ConfirmationWeight は確認の新しさと賛否から障害物の信頼度（0〜1）を返す
+//   - 新しさ: 最後に「まだある」と確認された日時（なければ登録日時）からの経過時間で、halfLifeごとに半減する
+//   - 賛否: 「まだある」と「なくなった」の件数の比率（確認がない場合は1）
+func (o *Obstacle) ConfirmationWeight(now time.Time, halfLife time.Duration) float64 {
+	since, err := time.Parse(time.RFC3339, o.LastConfirmedAt)
+	if err != nil {
+		since, err = time.Parse(time.RFC3339, o.CreatedAt)
+	}
+	freshness := 1.0
+	if err == nil && halfLife > 0 && now.After(since) {
+		freshness = math.Pow(0.5, float64(now.Sub(since))/float64(halfLife))
+	}
+
+	support := float64(o.StillThereCount+1) / float64(o.StillThereCount+o.GoneCount+1)
+	return freshness * support
+}
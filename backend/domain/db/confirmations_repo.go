@@ -0,0 +1,188 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"webhook/shared/util"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrConfirmationConflict は同じ利用者の確認が同時に記録されたことを表す
+var ErrConfirmationConflict = errors.New("confirmation has been modified by another request")
+
+type ConfirmationRepo struct {
+	TableName         string
+	ObstacleTableName string
+	Client            *dynamodb.Client
+}
+
+func NewConfirmationRepo(ctx context.Context) (*ConfirmationRepo, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	setting := util.GetSetting()
+	return &ConfirmationRepo{
+		TableName:         setting.ConfirmationTable.TableName,
+		ObstacleTableName: setting.ObstacleTable.TableName,
+		Client:            dynamodb.NewFromConfig(cfg),
+	}, nil
+}
+
+// Get は利用者の確認の記録を返す（記録がない場合はnil）
+func (r *ConfirmationRepo) Get(ctx context.Context, obstacleID int, userID string) (*ObstacleConfirmation, int, error) {
+	result, err := r.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.TableName),
+		Key:       confirmationKey(obstacleID, userID),
+	})
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("failed to get confirmation: %w", err)
+	}
+	if result.Item == nil {
+		return nil, http.StatusNotFound, nil
+	}
+
+	var confirmation ObstacleConfirmation
+	if err := attributevalue.UnmarshalMap(result.Item, &confirmation); err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("failed to unmarshal confirmation: %w", err)
+	}
+	return &confirmation, http.StatusOK, nil
+}
+
+// List は障害物に対するすべての利用者の確認を返す
+func (r *ConfirmationRepo) List(ctx context.Context, obstacleID int) ([]ObstacleConfirmation, int, error) {
+	keyCond := expression.Key("obstacle_id").Equal(expression.Value(obstacleID))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	confirmations := []ObstacleConfirmation{}
+	paginator := dynamodb.NewQueryPaginator(r.Client, &dynamodb.QueryInput{
+		TableName:                 aws.String(r.TableName),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("failed to query confirmations: %w", err)
+		}
+
+		var items []ObstacleConfirmation
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("failed to unmarshal confirmation: %w", err)
+		}
+		confirmations = append(confirmations, items...)
+	}
+	return confirmations, http.StatusOK, nil
+}
+
+// Save は利用者の確認を記録し、障害物の集計を同一トランザクションで更新する
+// previousは保存済みの記録（初めての確認の場合はnil）で、確認結果が変わった場合は以前の集計から差し引く
+// 障害物が存在しないかゴミ箱にある場合は404を返す
+func (r *ConfirmationRepo) Save(ctx context.Context, confirmation *ObstacleConfirmation, previous *ObstacleConfirmation) (int, error) {
+	item, err := attributevalue.MarshalMap(confirmation)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("failed to marshal confirmation: %w", err)
+	}
+	// 同じ利用者の確認が同時に記録された場合に二重に集計しないよう、読み込み時の状態を条件にする
+	putCondition := expression.AttributeNotExists(expression.Name("user_id"))
+	if previous != nil {
+		putCondition = expression.Name("vote").Equal(expression.Value(previous.Vote))
+	}
+	putExpr, err := expression.NewBuilder().WithCondition(putCondition).Build()
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	vote := confirmation.Vote
+	update := expression.Add(expression.Name(vote.countAttribute()), expression.Value(1))
+	if previous != nil && previous.Vote != vote {
+		update.Add(expression.Name(previous.Vote.countAttribute()), expression.Value(-1))
+	}
+	update.Set(expression.Name(vote.lastAttribute()), expression.Value(confirmation.UpdatedAt))
+	obstacleCondition := expression.AttributeExists(expression.Name("id")).
+		And(expression.AttributeNotExists(expression.Name("deleted_at")))
+	updateExpr, err := expression.NewBuilder().WithUpdate(update).WithCondition(obstacleCondition).Build()
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = r.Client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Put: &types.Put{
+					TableName:                 aws.String(r.TableName),
+					Item:                      item,
+					ExpressionAttributeNames:  putExpr.Names(),
+					ExpressionAttributeValues: putExpr.Values(),
+					ConditionExpression:       putExpr.Condition(),
+				},
+			},
+			{
+				Update: &types.Update{
+					TableName: aws.String(r.ObstacleTableName),
+					Key: map[string]types.AttributeValue{
+						"id": &types.AttributeValueMemberN{Value: strconv.Itoa(confirmation.ObstacleID)},
+					},
+					ExpressionAttributeNames:  updateExpr.Names(),
+					ExpressionAttributeValues: updateExpr.Values(),
+					UpdateExpression:          updateExpr.Update(),
+					ConditionExpression:       updateExpr.Condition(),
+				},
+			},
+		},
+	})
+	if err != nil {
+		var canceled *types.TransactionCanceledException
+		if errors.As(err, &canceled) && len(canceled.CancellationReasons) == 2 {
+			if aws.ToString(canceled.CancellationReasons[1].Code) == "ConditionalCheckFailed" {
+				return http.StatusNotFound, nil
+			}
+			if aws.ToString(canceled.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+				return http.StatusConflict, ErrConfirmationConflict
+			}
+		}
+		return http.StatusInternalServerError, fmt.Errorf("failed to save confirmation: %w", err)
+	}
+	return http.StatusOK, nil
+}
+
+// DeleteAll は障害物に対するすべての利用者の確認を削除する（障害物の完全削除に使う）
+// 障害物とともに集計も消えるため、集計は更新しない
+func (r *ConfirmationRepo) DeleteAll(ctx context.Context, obstacleID int) (int, error) {
+	confirmations, statusCode, err := r.List(ctx, obstacleID)
+	if err != nil {
+		return statusCode, err
+	}
+	requests := make([]types.WriteRequest, 0, len(confirmations))
+	for _, confirmation := range confirmations {
+		requests = append(requests, types.WriteRequest{DeleteRequest: &types.DeleteRequest{
+			Key: confirmationKey(obstacleID, confirmation.UserID),
+		}})
+	}
+	if err := batchWrite(ctx, r.Client, r.TableName, requests); err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("failed to delete confirmations: %w", err)
+	}
+	return http.StatusOK, nil
+}
+
+func confirmationKey(obstacleID int, userID string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"obstacle_id": &types.AttributeValueMemberN{Value: strconv.Itoa(obstacleID)},
+		"user_id":     &types.AttributeValueMemberS{Value: userID},
+	}
+}
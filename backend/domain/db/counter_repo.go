@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"webhook/shared/util"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// obstacleIDCounter は障害物IDを採番するカウンターの名前
+	obstacleIDCounter = "obstacle_id"
+	// obstacleIDStart は採番の開始値
+	// 以前は time.Now().UnixNano() % 1000000 をIDにしていたため、既存のIDと重ならない値から始める
+	obstacleIDStart = 1000000
+)
+
+type CounterRepo struct {
+	TableName string
+	Client    *dynamodb.Client
+}
+
+func NewCounterRepo(ctx context.Context) (*CounterRepo, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	counterTable := util.GetSetting().CounterTable
+	return &CounterRepo{
+		TableName: counterTable.TableName,
+		Client:    dynamodb.NewFromConfig(cfg),
+	}, nil
+}
+
+// NextObstacleID はアトミックなカウンターで新しい障害物IDを採番する
+func (r *CounterRepo) NextObstacleID(ctx context.Context) (int, int, error) {
+	update := expression.Set(
+		expression.Name("value"),
+		expression.Plus(expression.Name("value").IfNotExists(expression.Value(obstacleIDStart)), expression.Value(1)),
+	)
+	expr, err := expression.NewBuilder().WithUpdate(update).Build()
+	if err != nil {
+		return 0, http.StatusInternalServerError, fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	result, err := r.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.TableName),
+		Key: map[string]types.AttributeValue{
+			"name": &types.AttributeValueMemberS{Value: obstacleIDCounter},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ReturnValues:              types.ReturnValueUpdatedNew,
+	})
+	if err != nil {
+		return 0, http.StatusInternalServerError, fmt.Errorf("failed to increment obstacle id counter: %w", err)
+	}
+
+	value, ok := result.Attributes["value"].(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, http.StatusInternalServerError, fmt.Errorf("obstacle id counter returned no value")
+	}
+	id, err := strconv.Atoi(value.Value)
+	if err != nil {
+		return 0, http.StatusInternalServerError, fmt.Errorf("failed to parse obstacle id counter: %w", err)
+	}
+	return id, http.StatusOK, nil
+}
@@ -1,14 +1,256 @@
 package db
 
+import "sort"
+
+// ObstacleStatus は障害物報告のライフサイクル上の状態
+type ObstacleStatus string
+
+const (
+	ObstacleStatusReported ObstacleStatus = "reported"  // 報告済み（未確認）
+	ObstacleStatusVerified ObstacleStatus = "verified"  // 確認済み
+	ObstacleStatusInRepair ObstacleStatus = "in_repair" // 補修中
+	ObstacleStatusResolved ObstacleStatus = "resolved"  // 解消済み
+	ObstacleStatusRejected ObstacleStatus = "rejected"  // 却下
+)
+
+// IsValid は状態が既知の値かを返す
+func (s ObstacleStatus) IsValid() bool {
+	switch s {
+	case ObstacleStatusReported, ObstacleStatusVerified, ObstacleStatusInRepair, ObstacleStatusResolved, ObstacleStatusRejected:
+		return true
+	}
+	return false
+}
+
+// 障害物の種類（フロントエンドのObstacleTypeと合わせる）
+const (
+	ObstacleTypeBlockWall      = 0 // ブロック塀
+	ObstacleTypeVendingMachine = 1 // 自動販売機
+	ObstacleTypeStairs         = 2 // 階段
+	ObstacleTypeSteepSlope     = 3 // 急な坂
+	ObstacleTypeNarrowRoad     = 4 // 狭い道
+	ObstacleTypeOther          = 5 // その他
+)
+
+// 危険度（フロントエンドのDangerLevelと合わせる）
+const (
+	DangerLevelLow    = 0
+	DangerLevelMedium = 1
+	DangerLevelHigh   = 2
+)
+
+// 障害物の種類と危険度の上限
+const (
+	MaxObstacleType = ObstacleTypeOther
+	MaxDangerLevel  = DangerLevelHigh
+)
+
 type Obstacle struct {
-	ID          int        `json:"id" dynamodbav:"id"`
-	Position    [2]float64 `json:"position" dynamodbav:"position"`
-	Type        int        `json:"type" dynamodbav:"type"`
-	Description string     `json:"description" dynamodbav:"description"`
-	DangerLevel int        `json:"danger_level" dynamodbav:"danger_level"`
-	Nodes       []int64    `json:"nodes,omitempty" dynamodbav:"nodes,omitempty"`
-	NearestDistance float64 `json:"nearest_distance" dynamodbav:"nearest_distance"`
-	NoNearbyRoad  bool       `json:"no_nearby_road" dynamodbav:"no_nearby_road"`
-	ImageS3Key  string     `json:"image_s3_key" dynamodbav:"image_s3_key"`     
-	CreatedAt   string     `json:"created_at" dynamodbav:"created_at"`
+	ID              int                  `json:"id" dynamodbav:"id"`
+	Position        [2]float64           `json:"position" dynamodbav:"position"`
+	Type            int                  `json:"type" dynamodbav:"type"`
+	Description     string               `json:"description" dynamodbav:"description"`
+	DangerLevel     int                  `json:"danger_level" dynamodbav:"danger_level"`
+	Nodes           []int64              `json:"nodes,omitempty" dynamodbav:"nodes,omitempty"`
+	NearestDistance float64              `json:"nearest_distance" dynamodbav:"nearest_distance"`
+	NoNearbyRoad    bool                 `json:"no_nearby_road" dynamodbav:"no_nearby_road"`
+	ImageS3Key      string               `json:"image_s3_key,omitempty" dynamodbav:"image_s3_key,omitempty"` // 画像リスト導入前の単一画像（読み込み時にImagesへ移行）
+	Images          []ObstacleImage      `json:"images,omitempty" dynamodbav:"images,omitempty"`
+	Status          ObstacleStatus       `json:"status,omitempty" dynamodbav:"status,omitempty"`
+	Transitions     []ObstacleTransition `json:"transitions,omitempty" dynamodbav:"transitions,omitempty"`
+	CreatedAt       string               `json:"created_at" dynamodbav:"created_at"`
+	UpdatedAt       string               `json:"updated_at,omitempty" dynamodbav:"updated_at,omitempty"`
+	Version         int                  `json:"version" dynamodbav:"version"`
+	DeletedAt       string               `json:"deleted_at,omitempty" dynamodbav:"deleted_at,omitempty"`
+	MergedInto      int                  `json:"merged_into,omitempty" dynamodbav:"merged_into,omitempty"` // 統合先の障害物ID（統合されてゴミ箱に移動した場合）
+	MergedFrom      []int                `json:"merged_from,omitempty" dynamodbav:"merged_from,omitempty"` // この障害物に統合された障害物のID
+	ExternalID      string               `json:"external_id,omitempty" dynamodbav:"external_id,omitempty"` // 一括登録元（自治体の台帳など）での識別子
+	// 利用者による確認の集計（ConfirmationRepoのみが更新し、障害物の保存では書き込まない）
+	StillThereCount int    `json:"still_there_count,omitempty" dynamodbav:"still_there_count,omitempty"`
+	GoneCount       int    `json:"gone_count,omitempty" dynamodbav:"gone_count,omitempty"`
+	LastConfirmedAt string `json:"last_confirmed_at,omitempty" dynamodbav:"last_confirmed_at,omitempty"`
+	LastGoneAt      string `json:"last_gone_at,omitempty" dynamodbav:"last_gone_at,omitempty"`
+}
+
+// ObstacleTransition は状態遷移の記録
+type ObstacleTransition struct {
+	From       ObstacleStatus `json:"from" dynamodbav:"from"`
+	To         ObstacleStatus `json:"to" dynamodbav:"to"`
+	Actor      string         `json:"actor" dynamodbav:"actor"`
+	OnBehalfOf string         `json:"on_behalf_of,omitempty" dynamodbav:"on_behalf_of,omitempty"` // リクエストで申告された作業者（監査上の変更者とは別に参考として残す）
+	Comment    string         `json:"comment,omitempty" dynamodbav:"comment,omitempty"`
+	CreatedAt  string         `json:"created_at" dynamodbav:"created_at"`
+}
+
+// ObstacleImage は障害物に添付された画像
+type ObstacleImage struct {
+	ID        string                 `json:"id" dynamodbav:"id"`
+	S3Key     string                 `json:"s3_key" dynamodbav:"s3_key"`
+	Caption   string                 `json:"caption,omitempty" dynamodbav:"caption,omitempty"`
+	Uploader  string                 `json:"uploader,omitempty" dynamodbav:"uploader,omitempty"`
+	TakenAt   string                 `json:"taken_at,omitempty" dynamodbav:"taken_at,omitempty"`
+	SortOrder int                    `json:"sort_order" dynamodbav:"sort_order"`
+	CreatedAt string                 `json:"created_at" dynamodbav:"created_at"`
+	Variants  []ObstacleImageVariant `json:"variants,omitempty" dynamodbav:"variants,omitempty"`
+	// メタデータを取り除いた画像のS3キー（閲覧用URLはこちらに発行し、アップロードされた元画像は上書きしない）
+	ProcessedS3Key string `json:"processed_s3_key,omitempty" dynamodbav:"processed_s3_key,omitempty"`
+	// アップロード後の処理（メタデータの除去・サムネイル生成）が完了した日時
+	ProcessedAt string                 `json:"processed_at,omitempty" dynamodbav:"processed_at,omitempty"`
+	Metadata    *ObstacleImageMetadata `json:"metadata,omitempty" dynamodbav:"metadata,omitempty"`
+}
+
+// ObstacleImageMetadata は画像のEXIFから取り出した情報
+// 元画像のEXIFは除去するため、必要な情報だけをここに残す（撮影位置はAPIでは返さない）
+type ObstacleImageMetadata struct {
+	Latitude        *float64 `json:"latitude,omitempty" dynamodbav:"latitude,omitempty"`
+	Longitude       *float64 `json:"longitude,omitempty" dynamodbav:"longitude,omitempty"`
+	CapturedAt      string   `json:"captured_at,omitempty" dynamodbav:"captured_at,omitempty"`
+	DistanceMeters  *float64 `json:"distance_meters,omitempty" dynamodbav:"distance_meters,omitempty"` // 撮影位置から障害物までの距離
+	FarFromObstacle bool     `json:"far_from_obstacle,omitempty" dynamodbav:"far_from_obstacle,omitempty"`
+}
+
+// ObstacleImageVariant は画像から生成した縮小版（サムネイル）
+type ObstacleImageVariant struct {
+	Width int    `json:"width" dynamodbav:"width"`
+	S3Key string `json:"s3_key" dynamodbav:"s3_key"`
+}
+
+// legacyImageID は画像リスト導入前の単一画像に割り当てる画像ID
+const legacyImageID = "legacy"
+
+// CurrentStatus は障害物の現在の状態を返す
+// statusが導入される前に登録された障害物は運用中のデータとして確認済み扱いにする
+func (o *Obstacle) CurrentStatus() ObstacleStatus {
+	if o.Status == "" {
+		return ObstacleStatusVerified
+	}
+	return o.Status
+}
+
+// IsDeleted は障害物がゴミ箱に移動（論理削除）されているかを返す
+func (o *Obstacle) IsDeleted() bool {
+	return o.DeletedAt != ""
+}
+
+// FindImage は画像IDに一致する画像を返す
+func (o *Obstacle) FindImage(imageID string) *ObstacleImage {
+	for i := range o.Images {
+		if o.Images[i].ID == imageID {
+			return &o.Images[i]
+		}
+	}
+	return nil
+}
+
+// ViewS3Key は閲覧用URLを発行するS3キーを返す（発行しない場合は空文字）
+// 処理が完了していない画像（処理に失敗した画像を含む）は撮影位置などのメタデータを含みうるため発行しない
+// 画像リスト導入前の単一画像（image_s3_key）のみ、アップロードされた画像をそのまま使う
+func (i *ObstacleImage) ViewS3Key() string {
+	switch {
+	case i.ProcessedAt != "" && i.ProcessedS3Key != "":
+		return i.ProcessedS3Key
+	case i.ID == legacyImageID:
+		return i.S3Key
+	}
+	return ""
+}
+
+// FindImageByKey はS3キーに一致する画像を返す
+func (o *Obstacle) FindImageByKey(s3Key string) *ObstacleImage {
+	for i := range o.Images {
+		if o.Images[i].S3Key == s3Key {
+			return &o.Images[i]
+		}
+	}
+	return nil
+}
+
+// S3Keys は画像とその処理済みの画像・縮小版のS3キーをすべて返す
+func (i *ObstacleImage) S3Keys() []string {
+	keys := []string{i.S3Key}
+	if i.ProcessedS3Key != "" {
+		keys = append(keys, i.ProcessedS3Key)
+	}
+	for _, variant := range i.Variants {
+		keys = append(keys, variant.S3Key)
+	}
+	return keys
+}
+
+// SortImages は画像を表示順に並べ替える
+func (o *Obstacle) SortImages() {
+	sort.SliceStable(o.Images, func(i, j int) bool {
+		return o.Images[i].SortOrder < o.Images[j].SortOrder
+	})
+}
+
+// NextImageSortOrder は新しく追加する画像の表示順を返す
+func (o *Obstacle) NextImageSortOrder() int {
+	next := 0
+	for _, image := range o.Images {
+		if image.SortOrder >= next {
+			next = image.SortOrder + 1
+		}
+	}
+	return next
+}
+
+// migrateLegacyImage は単一画像（image_s3_key）の障害物を画像リストの形式に変換する
+func (o *Obstacle) migrateLegacyImage() {
+	if o.ImageS3Key != "" && o.FindImageByKey(o.ImageS3Key) == nil {
+		createdAt := o.UpdatedAt
+		if createdAt == "" {
+			createdAt = o.CreatedAt
+		}
+		o.Images = append([]ObstacleImage{{
+			ID:        legacyImageID,
+			S3Key:     o.ImageS3Key,
+			SortOrder: -1,
+			CreatedAt: createdAt,
+		}}, o.Images...)
+	}
+	o.ImageS3Key = ""
+	o.SortImages()
+}
+
+// ObstaclePatch は部分更新（JSON Merge Patch）で変更するフィールド
+// nilのフィールドは変更しない。Nodesに空のスライスを指定した場合は属性を削除する
+type ObstaclePatch struct {
+	Position        *[2]float64
+	Type            *int
+	Description     *string
+	DangerLevel     *int
+	Nodes           *[]int64
+	NearestDistance *float64
+	NoNearbyRoad    *bool
+	UpdatedAt       string
+}
+
+// Apply は部分更新を障害物に適用する
+func (p ObstaclePatch) Apply(o *Obstacle) {
+	if p.Position != nil {
+		o.Position = *p.Position
+	}
+	if p.Type != nil {
+		o.Type = *p.Type
+	}
+	if p.Description != nil {
+		o.Description = *p.Description
+	}
+	if p.DangerLevel != nil {
+		o.DangerLevel = *p.DangerLevel
+	}
+	if p.Nodes != nil {
+		o.Nodes = nil
+		if len(*p.Nodes) > 0 {
+			o.Nodes = append([]int64{}, *p.Nodes...)
+		}
+	}
+	if p.NearestDistance != nil {
+		o.NearestDistance = *p.NearestDistance
+	}
+	if p.NoNearbyRoad != nil {
+		o.NoNearbyRoad = *p.NoNearbyRoad
+	}
+	o.UpdatedAt = p.UpdatedAt
 }
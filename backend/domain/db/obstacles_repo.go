@@ -2,9 +2,15 @@ package db
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"iter"
 	"net/http"
+	"time"
 
+	"webhook/shared/geohash"
 	"webhook/shared/util"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -15,9 +21,38 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
+var (
+	// ErrVersionConflict は楽観的排他制御で他の更新と競合したことを表す
+	ErrVersionConflict = errors.New("obstacle has been modified by another request")
+	// ErrObstacleExists は作成しようとしたIDの障害物が既に存在することを表す
+	ErrObstacleExists = errors.New("obstacle with the same id already exists")
+	// ErrInvalidCursor は一覧の続きを取得する位置が不正であることを表す
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrAreaTooLarge は位置から検索する範囲が広すぎることを表す
+	ErrAreaTooLarge = errors.New("area is too large to search by location")
+)
+
+const (
+	// obstacleExternalIDIndex は外部IDから障害物を検索するGSI（キーのみを射影する）
+	obstacleExternalIDIndex = "external_id-index"
+	// obstacleGeohashIndex は位置から障害物を検索するGSI（geocellがパーティションキー、geohashがソートキーで、すべての属性を射影する）
+	obstacleGeohashIndex = "geohash-index"
+	// geocellPrecision はパーティションキーにするgeohashの文字数（約156km四方）
+	geocellPrecision = 3
+	// maxGeohashQueries は範囲の検索で発行するQueryの上限
+	maxGeohashQueries = 16
+	// batchGetSize はBatchGetItemで一度に取得できる件数の上限
+	batchGetSize = 100
+	// batchGetAttempts は取得できなかったキー（UnprocessedKeys）を再試行する回数
+	batchGetAttempts = 5
+)
+
 type ObstacleRepo struct {
-	TableName string
-	Client    *dynamodb.Client
+	TableName            string
+	RevisionTableName    string
+	OutboxTableName      string
+	SearchIndexTableName string
+	Client               *dynamodb.Client
 }
 
 func NewObstacleRepo(ctx context.Context) (*ObstacleRepo, error) {
@@ -26,98 +61,768 @@ func NewObstacleRepo(ctx context.Context) (*ObstacleRepo, error) {
 		return nil, fmt.Errorf("failed to load config: %w", err)
 	}
 
-	obstacleRepo := util.GetSetting().ObstacleTable
+	setting := util.GetSetting()
 	return &ObstacleRepo{
-		TableName: obstacleRepo.TableName,
-		Client:    dynamodb.NewFromConfig(cfg),
+		TableName:            setting.ObstacleTable.TableName,
+		RevisionTableName:    setting.ObstacleRevisionTable.TableName,
+		OutboxTableName:      setting.OutboxTable.TableName,
+		SearchIndexTableName: setting.SearchIndexTable.TableName,
+		Client:               dynamodb.NewFromConfig(cfg),
 	}, nil
 }
 
-func (r *ObstacleRepo) List(ctx context.Context) (*[]Obstacle, int, error) {
-	input := &dynamodb.ScanInput{
+// All は全障害物（ゴミ箱を含む）を先頭から走査する
+func (r *ObstacleRepo) All(ctx context.Context) iter.Seq2[*Obstacle, error] {
+	return r.After(ctx, "")
+}
+
+// After はcursorが指す障害物の次から全障害物（ゴミ箱を含む）を走査する（cursorが空の場合は先頭から）
+// Scanは1回あたり1MBまでしか返さないため、LastEvaluatedKeyがなくなるまでページングする
+// 取得に失敗した場合やcursorが不正な場合はエラーを1度渡して終了する
+func (r *ObstacleRepo) After(ctx context.Context, cursor string) iter.Seq2[*Obstacle, error] {
+	return func(yield func(*Obstacle, error) bool) {
+		input := &dynamodb.ScanInput{
+			TableName: aws.String(r.TableName),
+		}
+		if cursor != "" {
+			key, err := decodeObstacleCursor(cursor)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			input.ExclusiveStartKey = key
+		}
+
+		paginator := dynamodb.NewScanPaginator(r.Client, input)
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				yield(nil, fmt.Errorf("failed to scan obstacles: %w", err))
+				return
+			}
+
+			var items []Obstacle
+			if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+				yield(nil, fmt.Errorf("failed to unmarshal obstacle: %w", err))
+				return
+			}
+			for i := range items {
+				items[i].migrateLegacyImage()
+				if !yield(&items[i], nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// obstacleCursor はAfterで続きから走査するための位置（最後に返した障害物のキー）
+type obstacleCursor struct {
+	ID int `json:"id" dynamodbav:"id"`
+}
+
+// Cursor はこの障害物の次から走査するためのcursorを返す
+// 中身はテーブルのキーだが、APIの利用者には不透明な文字列として扱わせる
+func (o *Obstacle) Cursor() string {
+	data, _ := json.Marshal(obstacleCursor{ID: o.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeObstacleCursor(cursor string) (map[string]types.AttributeValue, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var decoded obstacleCursor
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.ID <= 0 {
+		return nil, ErrInvalidCursor
+	}
+	return attributevalue.MarshalMap(decoded)
+}
+
+// Within は範囲（南端・西端・北端・東端）に重なるgeohashのセルにある障害物（ゴミ箱を含む）を走査する
+// セルは範囲より広いため、範囲外の障害物も含まれる。呼び出し側で位置を確かめる
+// Queryの数がmaxGeohashQueriesに収まる範囲で最も細かいセルを使い、収まらない広さの場合はErrAreaTooLargeを1度渡して終了する
+// GSIは結果整合性のため、保存直後の変更は反映されていないことがある
+func (r *ObstacleRepo) Within(ctx context.Context, minLat, minLon, maxLat, maxLon float64) iter.Seq2[*Obstacle, error] {
+	return func(yield func(*Obstacle, error) bool) {
+		precision := geocellPrecision
+		if geohash.CoverCount(minLat, minLon, maxLat, maxLon, precision) > maxGeohashQueries {
+			yield(nil, ErrAreaTooLarge)
+			return
+		}
+		for precision < geohash.MaxPrecision && geohash.CoverCount(minLat, minLon, maxLat, maxLon, precision+1) <= maxGeohashQueries {
+			precision++
+		}
+
+		for _, cell := range geohash.Cover(minLat, minLon, maxLat, maxLon, precision) {
+			keyCond := expression.Key("geocell").Equal(expression.Value(cell[:geocellPrecision]))
+			if len(cell) > geocellPrecision {
+				keyCond = keyCond.And(expression.Key("geohash").BeginsWith(cell))
+			}
+			expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+			if err != nil {
+				yield(nil, fmt.Errorf("failed to build expression: %w", err))
+				return
+			}
+
+			paginator := dynamodb.NewQueryPaginator(r.Client, &dynamodb.QueryInput{
+				TableName:                 aws.String(r.TableName),
+				IndexName:                 aws.String(obstacleGeohashIndex),
+				KeyConditionExpression:    expr.KeyCondition(),
+				ExpressionAttributeNames:  expr.Names(),
+				ExpressionAttributeValues: expr.Values(),
+			})
+			for paginator.HasMorePages() {
+				page, err := paginator.NextPage(ctx)
+				if err != nil {
+					yield(nil, fmt.Errorf("failed to query obstacles by location: %w", err))
+					return
+				}
+
+				var items []Obstacle
+				if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+					yield(nil, fmt.Errorf("failed to unmarshal obstacle: %w", err))
+					return
+				}
+				for i := range items {
+					items[i].migrateLegacyImage()
+					if !yield(&items[i], nil) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+// IndexLocation は位置から検索するGSIのキーを障害物の現在の位置で書き込む
+// キーは保存のたびに書き込むため、キーの導入前に保存した障害物にのみ必要になる
+// 読み込み後に他の更新があった場合は、その更新でキーも書き込まれているため何もしない
+func (r *ObstacleRepo) IndexLocation(ctx context.Context, obstacle *Obstacle) (int, error) {
+	cell, hash := geohashKeys(obstacle.Position)
+	update := expression.Set(expression.Name("geocell"), expression.Value(cell))
+	update.Set(expression.Name("geohash"), expression.Value(hash))
+	condition := expression.AttributeExists(expression.Name("id")).And(versionCondition(obstacle.Version))
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	_, err = r.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
 		TableName: aws.String(r.TableName),
-		Limit:     aws.Int32(1000),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", obstacle.ID)},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return http.StatusOK, nil
 	}
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("failed to index obstacle location: %w", err)
+	}
+	return http.StatusOK, nil
+}
+
+// geohashKeys は位置から検索するGSIのパーティションキーとソートキーを返す
+func geohashKeys(position [2]float64) (string, string) {
+	hash := geohash.Encode(position[0], position[1], geohash.MaxPrecision)
+	return hash[:geocellPrecision], hash
+}
 
-	result, err := r.Client.Scan(ctx, input)
+// ListImageKeys は全障害物（ゴミ箱を含む）が参照している画像と縮小版のS3キーを返す
+// 画像の属性だけを取得し、テーブル全体をページングして走査する
+func (r *ObstacleRepo) ListImageKeys(ctx context.Context) (map[string]bool, int, error) {
+	proj := expression.NamesList(expression.Name("images"), expression.Name("image_s3_key"))
+	expr, err := expression.NewBuilder().WithProjection(proj).Build()
 	if err != nil {
-		return nil, http.StatusInternalServerError, fmt.Errorf("failed to scan dictionary word: %w", err)
+		return nil, http.StatusInternalServerError, fmt.Errorf("failed to build expression: %w", err)
 	}
 
-	var obstacles []Obstacle
-	err = attributevalue.UnmarshalListOfMaps(result.Items, &obstacles)
+	keys := map[string]bool{}
+	paginator := dynamodb.NewScanPaginator(r.Client, &dynamodb.ScanInput{
+		TableName:                aws.String(r.TableName),
+		ProjectionExpression:     expr.Projection(),
+		ExpressionAttributeNames: expr.Names(),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("failed to scan obstacle images: %w", err)
+		}
+
+		var items []Obstacle
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("failed to unmarshal obstacle: %w", err)
+		}
+		for i := range items {
+			items[i].migrateLegacyImage()
+			for _, image := range items[i].Images {
+				for _, key := range image.S3Keys() {
+					keys[key] = true
+				}
+			}
+		}
+	}
+	return keys, http.StatusOK, nil
+}
+
+// GetByExternalID は外部IDが一致する障害物（ゴミ箱を含む）を返す（存在しない場合はnil）
+// GSIは結果整合性のため、登録直後の障害物は見つからないことがある
+func (r *ObstacleRepo) GetByExternalID(ctx context.Context, externalID string) (*Obstacle, int, error) {
+	keyCond := expression.Key("external_id").Equal(expression.Value(externalID))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
 	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	result, err := r.Client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(r.TableName),
+		IndexName:                 aws.String(obstacleExternalIDIndex),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		Limit:                     aws.Int32(1),
+	})
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("failed to query obstacle by external id: %w", err)
+	}
+	if len(result.Items) == 0 {
+		return nil, http.StatusNotFound, nil
+	}
+
+	var key struct {
+		ID int `dynamodbav:"id"`
+	}
+	if err := attributevalue.UnmarshalMap(result.Items[0], &key); err != nil {
 		return nil, http.StatusInternalServerError, fmt.Errorf("failed to unmarshal obstacle: %w", err)
 	}
-	return &obstacles, http.StatusOK, nil
+	return r.Get(ctx, key.ID)
 }
 
 func (r *ObstacleRepo) Get(ctx context.Context, id int) (*Obstacle, int, error) {
+	return r.get(ctx, id, false)
+}
+
+// get は障害物を返す（consistentReadの場合は直前に確定した書き込みを必ず反映した値を読む）
+func (r *ObstacleRepo) get(ctx context.Context, id int, consistentRead bool) (*Obstacle, int, error) {
 	input := &dynamodb.GetItemInput{
 		TableName: aws.String(r.TableName),
 		Key: map[string]types.AttributeValue{
 			"id": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", id)},
 		},
+		ConsistentRead: aws.Bool(consistentRead),
 	}
 
 	result, err := r.Client.GetItem(ctx, input)
 	if err != nil {
 		return nil, http.StatusInternalServerError, fmt.Errorf("failed to query dictionary word: %w", err)
 	}
+	if result.Item == nil {
+		return nil, http.StatusNotFound, nil
+	}
 
 	var obstacle Obstacle
 	err = attributevalue.UnmarshalMap(result.Item, &obstacle)
 	if err != nil {
 		return nil, http.StatusInternalServerError, fmt.Errorf("failed to unmarshal obstacle: %w", err)
 	}
+	obstacle.migrateLegacyImage()
 	return &obstacle, http.StatusOK, nil
 }
 
-func (r *ObstacleRepo) CreateOrUpdate(ctx context.Context, obstacle *Obstacle) (int, error) {
+// BatchGet は指定したIDの障害物を返す（存在しないIDは含めず、順序は保証しない）
+func (r *ObstacleRepo) BatchGet(ctx context.Context, ids []int) ([]*Obstacle, int, error) {
+	var obstacles []*Obstacle
+	for start := 0; start < len(ids); start += batchGetSize {
+		var keys []map[string]types.AttributeValue
+		for _, id := range ids[start:min(start+batchGetSize, len(ids))] {
+			keys = append(keys, map[string]types.AttributeValue{
+				"id": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", id)},
+			})
+		}
+		pending := map[string]types.KeysAndAttributes{r.TableName: {Keys: keys}}
+		for attempt := 0; len(pending) > 0; attempt++ {
+			if attempt == batchGetAttempts {
+				return nil, http.StatusInternalServerError, fmt.Errorf("failed to get obstacles: %d keys left unprocessed", len(pending[r.TableName].Keys))
+			}
+			if attempt > 0 {
+				time.Sleep(time.Duration(50<<attempt) * time.Millisecond)
+			}
+			result, err := r.Client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{RequestItems: pending})
+			if err != nil {
+				return nil, http.StatusInternalServerError, fmt.Errorf("failed to get obstacles: %w", err)
+			}
+			var items []Obstacle
+			if err := attributevalue.UnmarshalListOfMaps(result.Responses[r.TableName], &items); err != nil {
+				return nil, http.StatusInternalServerError, fmt.Errorf("failed to unmarshal obstacle: %w", err)
+			}
+			for i := range items {
+				items[i].migrateLegacyImage()
+				obstacles = append(obstacles, &items[i])
+			}
+			pending = result.UnprocessedKeys
+		}
+	}
+	return obstacles, http.StatusOK, nil
+}
+
+// Create は新しい障害物を保存し、作成を履歴として同一トランザクションで記録する
+// 同じIDの障害物が既に存在する場合は上書きせずにErrObstacleExistsを返す
+func (r *ObstacleRepo) Create(ctx context.Context, obstacle *Obstacle, audit Audit) (int, error) {
+	obstacle.Version = 0
+	condition := expression.AttributeNotExists(expression.Name("id"))
+	statusCode, err := r.save(ctx, obstacle, nil, condition, audit, nil)
+	if errors.Is(err, ErrVersionConflict) {
+		return http.StatusConflict, ErrObstacleExists
+	}
+	return statusCode, err
+}
+
+// CreateOrUpdate は障害物を保存し、変更前後のスナップショットを履歴として同一トランザクションで記録する
+// obstacle.Versionは読み込み時のバージョンで、保存中に他の更新があった場合はErrVersionConflictを返す
+// 保存に成功するとobstacle.Versionは新しいバージョンに更新される
+func (r *ObstacleRepo) CreateOrUpdate(ctx context.Context, obstacle *Obstacle, audit Audit) (int, error) {
+	before, statusCode, err := r.Get(ctx, obstacle.ID)
+	if err != nil {
+		return statusCode, err
+	}
+	if before != nil && before.Version != obstacle.Version {
+		return http.StatusPreconditionFailed, ErrVersionConflict
+	}
+	return r.save(ctx, obstacle, before, versionCondition(obstacle.Version), audit, nil)
+}
+
+// CreateOrUpdateWithOutbox はCreateOrUpdateと同じく障害物を保存し、確定後に実行する処理を同一トランザクションで記録する
+func (r *ObstacleRepo) CreateOrUpdateWithOutbox(ctx context.Context, obstacle *Obstacle, audit Audit, outbox *OutboxEntry) (int, error) {
+	before, statusCode, err := r.Get(ctx, obstacle.ID)
+	if err != nil {
+		return statusCode, err
+	}
+	if before != nil && before.Version != obstacle.Version {
+		return http.StatusPreconditionFailed, ErrVersionConflict
+	}
+	return r.save(ctx, obstacle, before, versionCondition(obstacle.Version), audit, outbox)
+}
+
+// Merge は重複する障害物（sources）を統合した結果をtargetとあわせて保存し、それぞれの履歴を同一トランザクションで記録する
+// 各障害物のVersionは読み込み時のバージョンで、保存中に他の更新があった場合はErrVersionConflictを返す
+// 保存に成功すると各障害物のVersionは新しいバージョンに更新される
+func (r *ObstacleRepo) Merge(ctx context.Context, target *Obstacle, sources []*Obstacle, audit Audit) (int, error) {
+	obstacles := append([]*Obstacle{target}, sources...)
+	befores := make([]*Obstacle, len(obstacles))
+	for i, obstacle := range obstacles {
+		before, statusCode, err := r.Get(ctx, obstacle.ID)
+		if err != nil {
+			return statusCode, err
+		}
+		if before == nil {
+			return http.StatusNotFound, fmt.Errorf("obstacle %d not found", obstacle.ID)
+		}
+		if before.Version != obstacle.Version {
+			return http.StatusPreconditionFailed, ErrVersionConflict
+		}
+		befores[i] = before
+	}
+
+	// 失敗した場合は読み込み時のバージョンに戻す
+	restoreVersions := func() {
+		for i, obstacle := range obstacles {
+			obstacle.Version = befores[i].Version
+		}
+	}
+	var items []types.TransactWriteItem
+	var indexEntries []*OutboxEntry
+	for i, obstacle := range obstacles {
+		obstacle.Version = befores[i].Version + 1
+		saveItems, indexEntry, err := r.saveItems(obstacle, befores[i], versionCondition(befores[i].Version), audit)
+		if err != nil {
+			restoreVersions()
+			return http.StatusInternalServerError, err
+		}
+		items = append(items, saveItems...)
+		indexEntries = append(indexEntries, indexEntry)
+	}
+
+	_, err := r.Client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+	if err != nil {
+		restoreVersions()
+		if isConditionalCheckFailed(err) {
+			return http.StatusPreconditionFailed, ErrVersionConflict
+		}
+		return http.StatusInternalServerError, fmt.Errorf("failed to merge obstacles: %w", err)
+	}
+	r.syncSearchIndex(ctx, indexEntries...)
+	return http.StatusOK, nil
+}
+
+// save は条件付きで障害物を書き込み、履歴（とoutboxがあれば後処理）を同一トランザクションで記録する
+// 条件を満たさない場合はErrVersionConflictを返す
+func (r *ObstacleRepo) save(ctx context.Context, obstacle *Obstacle, before *Obstacle, condition expression.ConditionBuilder, audit Audit, outbox *OutboxEntry) (int, error) {
+	expectedVersion := obstacle.Version
+	obstacle.Version = expectedVersion + 1
+
+	items, indexEntry, err := r.saveItems(obstacle, before, condition, audit)
+	if err != nil {
+		obstacle.Version = expectedVersion
+		return http.StatusInternalServerError, err
+	}
+	input := &dynamodb.TransactWriteItemsInput{TransactItems: items}
+	if outbox != nil {
+		put, err := outboxItem(r.OutboxTableName, outbox)
+		if err != nil {
+			obstacle.Version = expectedVersion
+			return http.StatusInternalServerError, err
+		}
+		input.TransactItems = append(input.TransactItems, types.TransactWriteItem{Put: put})
+	}
+
+	_, err = r.Client.TransactWriteItems(ctx, input)
+	if err != nil {
+		obstacle.Version = expectedVersion
+		if isConditionalCheckFailed(err) {
+			return http.StatusPreconditionFailed, ErrVersionConflict
+		}
+		return http.StatusInternalServerError, fmt.Errorf("failed to create obstacle: %w", err)
+	}
+	r.syncSearchIndex(ctx, indexEntry)
+	return http.StatusOK, nil
+}
+
+// saveItems は障害物の条件付きの書き込みと履歴の記録（と説明が変わった場合は検索インデックスの更新）を組み立てる
+// obstacle.Versionは保存後の新しいバージョンにしておく
+func (r *ObstacleRepo) saveItems(obstacle *Obstacle, before *Obstacle, condition expression.ConditionBuilder, audit Audit) ([]types.TransactWriteItem, *OutboxEntry, error) {
+	// 確認の集計（still_there_countなど）はConfirmationRepoが加算で更新するため、読み込み時の値で上書きしない
 	update := expression.Set(expression.Name("position"), expression.Value(obstacle.Position))
+	cell, hash := geohashKeys(obstacle.Position)
+	update.Set(expression.Name("geocell"), expression.Value(cell))
+	update.Set(expression.Name("geohash"), expression.Value(hash))
 	update.Set(expression.Name("type"), expression.Value(obstacle.Type))
 	update.Set(expression.Name("description"), expression.Value(obstacle.Description))
 	update.Set(expression.Name("danger_level"), expression.Value(obstacle.DangerLevel))
 	update.Set(expression.Name("nodes"), expression.Value(obstacle.Nodes))
 	update.Set(expression.Name("nearest_distance"), expression.Value(obstacle.NearestDistance))
 	update.Set(expression.Name("no_nearby_road"), expression.Value(obstacle.NoNearbyRoad))
-	update.Set(expression.Name("image_s3_key"), expression.Value(obstacle.ImageS3Key))
+	update.Set(expression.Name("images"), expression.Value(obstacle.Images))
+	update.Remove(expression.Name("image_s3_key"))
+	update.Set(expression.Name("status"), expression.Value(obstacle.Status))
+	update.Set(expression.Name("transitions"), expression.Value(obstacle.Transitions))
 	update.Set(expression.Name("created_at"), expression.Value(obstacle.CreatedAt))
+	update.Set(expression.Name("updated_at"), expression.Value(obstacle.UpdatedAt))
+	update.Set(expression.Name("version"), expression.Value(obstacle.Version))
+	if obstacle.IsDeleted() {
+		update.Set(expression.Name("deleted_at"), expression.Value(obstacle.DeletedAt))
+	} else {
+		update.Remove(expression.Name("deleted_at"))
+	}
+	if obstacle.MergedInto != 0 {
+		update.Set(expression.Name("merged_into"), expression.Value(obstacle.MergedInto))
+	} else {
+		update.Remove(expression.Name("merged_into"))
+	}
+	if len(obstacle.MergedFrom) > 0 {
+		update.Set(expression.Name("merged_from"), expression.Value(obstacle.MergedFrom))
+	} else {
+		update.Remove(expression.Name("merged_from"))
+	}
+	// GSIのキーは空文字列にできないため、外部IDがない場合は属性ごと削除する
+	if obstacle.ExternalID != "" {
+		update.Set(expression.Name("external_id"), expression.Value(obstacle.ExternalID))
+	} else {
+		update.Remove(expression.Name("external_id"))
+	}
 
-	expr, err := expression.NewBuilder().WithUpdate(update).Build()
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
 	if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("failed to build expression: %w", err)
+		return nil, nil, fmt.Errorf("failed to build expression: %w", err)
 	}
 
-	input := &dynamodb.UpdateItemInput{
-		TableName: aws.String(r.TableName),
-		Key: map[string]types.AttributeValue{
-			"id": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", obstacle.ID)},
+	revision, err := r.revisionItem(obstacle.ID, before, obstacle, audit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	items := []types.TransactWriteItem{
+		{
+			Update: &types.Update{
+				TableName: aws.String(r.TableName),
+				Key: map[string]types.AttributeValue{
+					"id": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", obstacle.ID)},
+				},
+				ExpressionAttributeNames:  expr.Names(),
+				ExpressionAttributeValues: expr.Values(),
+				UpdateExpression:          expr.Update(),
+				ConditionExpression:       expr.Condition(),
+			},
 		},
-		ExpressionAttributeNames:  expr.Names(),
-		ExpressionAttributeValues: expr.Values(),
-		UpdateExpression:          expr.Update(),
+		{Put: revision},
+	}
+	if before != nil && before.Description == obstacle.Description {
+		return items, nil, nil
+	}
+	indexEntry, indexItem, err := r.indexItem(obstacle.ID)
+	if err != nil {
+		return nil, nil, err
 	}
+	return append(items, indexItem), indexEntry, nil
+}
 
-	_, err = r.Client.UpdateItem(ctx, input)
+// Patch は指定されたフィールドのみを更新し、変更前後のスナップショットを履歴として同一トランザクションで記録する
+// expectedVersionが指定された場合は、そのバージョンの障害物のみを更新する
+func (r *ObstacleRepo) Patch(ctx context.Context, id int, patch ObstaclePatch, expectedVersion *int, audit Audit) (*Obstacle, int, error) {
+	before, statusCode, err := r.Get(ctx, id)
 	if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("failed to create obstacle: %w", err)
+		return nil, statusCode, err
 	}
-	return http.StatusOK, nil
+	if before == nil || before.IsDeleted() {
+		return nil, http.StatusNotFound, nil
+	}
+	if expectedVersion != nil && before.Version != *expectedVersion {
+		return nil, http.StatusPreconditionFailed, ErrVersionConflict
+	}
+
+	after := *before
+	patch.Apply(&after)
+	after.Version = before.Version + 1
+
+	update := expression.Set(expression.Name("updated_at"), expression.Value(after.UpdatedAt))
+	update.Set(expression.Name("version"), expression.Value(after.Version))
+	if patch.Position != nil {
+		update.Set(expression.Name("position"), expression.Value(after.Position))
+		cell, hash := geohashKeys(after.Position)
+		update.Set(expression.Name("geocell"), expression.Value(cell))
+		update.Set(expression.Name("geohash"), expression.Value(hash))
+	}
+	if patch.Type != nil {
+		update.Set(expression.Name("type"), expression.Value(after.Type))
+	}
+	if patch.Description != nil {
+		update.Set(expression.Name("description"), expression.Value(after.Description))
+	}
+	if patch.DangerLevel != nil {
+		update.Set(expression.Name("danger_level"), expression.Value(after.DangerLevel))
+	}
+	if patch.Nodes != nil {
+		if len(after.Nodes) == 0 {
+			update.Remove(expression.Name("nodes"))
+		} else {
+			update.Set(expression.Name("nodes"), expression.Value(after.Nodes))
+		}
+	}
+	if patch.NearestDistance != nil {
+		update.Set(expression.Name("nearest_distance"), expression.Value(after.NearestDistance))
+	}
+	if patch.NoNearbyRoad != nil {
+		update.Set(expression.Name("no_nearby_road"), expression.Value(after.NoNearbyRoad))
+	}
+
+	condition := expression.AttributeExists(expression.Name("id")).And(versionCondition(before.Version))
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	revision, err := r.revisionItem(id, before, &after, audit)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Update: &types.Update{
+					TableName: aws.String(r.TableName),
+					Key: map[string]types.AttributeValue{
+						"id": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", id)},
+					},
+					ExpressionAttributeNames:  expr.Names(),
+					ExpressionAttributeValues: expr.Values(),
+					UpdateExpression:          expr.Update(),
+					ConditionExpression:       expr.Condition(),
+				},
+			},
+			{Put: revision},
+		},
+	}
+	var indexEntry *OutboxEntry
+	if after.Description != before.Description {
+		var indexItem types.TransactWriteItem
+		if indexEntry, indexItem, err = r.indexItem(id); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		input.TransactItems = append(input.TransactItems, indexItem)
+	}
+
+	_, err = r.Client.TransactWriteItems(ctx, input)
+	if err != nil {
+		if isConditionalCheckFailed(err) {
+			return nil, http.StatusPreconditionFailed, ErrVersionConflict
+		}
+		return nil, http.StatusInternalServerError, fmt.Errorf("failed to patch obstacle: %w", err)
+	}
+	r.syncSearchIndex(ctx, indexEntry)
+	return &after, http.StatusOK, nil
 }
 
-func (r *ObstacleRepo) Delete(ctx context.Context, id int) (int, error) {
-	input := &dynamodb.DeleteItemInput{
-		TableName: aws.String(r.TableName),
-		Key: map[string]types.AttributeValue{
-			"id": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", id)},
+// Delete は障害物を物理削除し、削除前のスナップショットを履歴として同一トランザクションで記録する
+// 通常の削除は論理削除（DeletedAt）で行い、物理削除は保持期間を過ぎたゴミ箱の完全削除に使う
+// expectedVersionが指定された場合は、そのバージョンの障害物のみを削除する
+// outboxが指定された場合は、確定後に実行する処理（画像の削除など）も同一トランザクションで記録する
+func (r *ObstacleRepo) Delete(ctx context.Context, id int, expectedVersion *int, audit Audit, outbox *OutboxEntry) (int, error) {
+	before, statusCode, err := r.Get(ctx, id)
+	if err != nil {
+		return statusCode, err
+	}
+	if before == nil {
+		return http.StatusNotFound, fmt.Errorf("obstacle %d not found", id)
+	}
+	if expectedVersion != nil && before.Version != *expectedVersion {
+		return http.StatusPreconditionFailed, ErrVersionConflict
+	}
+
+	expr, err := expression.NewBuilder().WithCondition(versionCondition(before.Version)).Build()
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	revision, err := r.revisionItem(id, before, nil, audit)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Delete: &types.Delete{
+					TableName: aws.String(r.TableName),
+					Key: map[string]types.AttributeValue{
+						"id": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", id)},
+					},
+					ExpressionAttributeNames:  expr.Names(),
+					ExpressionAttributeValues: expr.Values(),
+					ConditionExpression:       expr.Condition(),
+				},
+			},
+			{Put: revision},
 		},
 	}
+	if outbox != nil {
+		put, err := outboxItem(r.OutboxTableName, outbox)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		input.TransactItems = append(input.TransactItems, types.TransactWriteItem{Put: put})
+	}
+	indexEntry, indexItem, err := r.indexItem(id)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	input.TransactItems = append(input.TransactItems, indexItem)
 
-	_, err := r.Client.DeleteItem(ctx, input)
+	_, err = r.Client.TransactWriteItems(ctx, input)
 	if err != nil {
+		if isConditionalCheckFailed(err) {
+			return http.StatusPreconditionFailed, ErrVersionConflict
+		}
 		return http.StatusInternalServerError, fmt.Errorf("failed to delete obstacle: %w", err)
 	}
+	r.syncSearchIndex(ctx, indexEntry)
 
 	return http.StatusOK, nil
 }
+
+// indexItem は検索インデックスを更新する処理を、障害物の変更と同じトランザクションでoutboxに記録する書き込みを組み立てる
+// トークンの数だけ書き込みが必要でトランザクションの上限を超えうるため、インデックス自体は確定後に更新する
+func (r *ObstacleRepo) indexItem(id int) (*OutboxEntry, types.TransactWriteItem, error) {
+	entry, err := NewIndexObstacleEntry(id)
+	if err != nil {
+		return nil, types.TransactWriteItem{}, err
+	}
+	put, err := outboxItem(r.OutboxTableName, entry)
+	if err != nil {
+		return nil, types.TransactWriteItem{}, err
+	}
+	return entry, types.TransactWriteItem{Put: put}, nil
+}
+
+// syncSearchIndex は確定した変更をすぐに検索インデックスへ反映し、反映できた処理をoutboxから削除する
+// 失敗した場合は記録済みの処理をProcessOutboxが再試行するため、保存の結果には影響させない
+func (r *ObstacleRepo) syncSearchIndex(ctx context.Context, entries ...*OutboxEntry) {
+	outboxRepo := &OutboxRepo{TableName: r.OutboxTableName, Client: r.Client}
+	for _, entry := range entries {
+		if entry == nil {
+			continue
+		}
+		if _, err := r.Reindex(ctx, entry.ObstacleID); err != nil {
+			continue
+		}
+		_, _ = outboxRepo.Complete(ctx, entry.ID)
+	}
+}
+
+// Reindex は障害物の最新の説明で検索インデックスを更新する（物理削除済みの場合はインデックスから削除する）
+func (r *ObstacleRepo) Reindex(ctx context.Context, id int) (int, error) {
+	obstacle, statusCode, err := r.get(ctx, id, true)
+	if err != nil {
+		return statusCode, err
+	}
+	description := ""
+	if obstacle != nil {
+		description = obstacle.Description
+	}
+	searchIndexRepo := &SearchIndexRepo{TableName: r.SearchIndexTableName, Client: r.Client}
+	return searchIndexRepo.Index(ctx, id, description)
+}
+
+// revisionItem は履歴テーブルへの書き込み（上書き不可）を組み立てる
+func (r *ObstacleRepo) revisionItem(id int, before, after *Obstacle, audit Audit) (*types.Put, error) {
+	now := time.Now()
+	revision := ObstacleRevision{
+		ObstacleID: id,
+		RevisionID: now.UnixNano(),
+		Actor:      audit.Actor,
+		Source:     audit.Source,
+		Before:     before,
+		After:      after,
+		CreatedAt:  now.Format(time.RFC3339),
+	}
+
+	item, err := attributevalue.MarshalMap(revision)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal obstacle revision: %w", err)
+	}
+
+	return &types.Put{
+		TableName:           aws.String(r.RevisionTableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(revision_id)"),
+	}, nil
+}
+
+// versionCondition は保存済みのバージョンが読み込み時から変わっていないことを確認する条件
+// versionが導入される前の障害物と新規作成はバージョン0として扱う
+func versionCondition(version int) expression.ConditionBuilder {
+	if version == 0 {
+		return expression.AttributeNotExists(expression.Name("version"))
+	}
+	return expression.Name("version").Equal(expression.Value(version))
+}
+
+// isConditionalCheckFailed はトランザクションが条件式の不一致で取り消されたかを判定する
+func isConditionalCheckFailed(err error) bool {
+	var canceled *types.TransactionCanceledException
+	if !errors.As(err, &canceled) {
+		return false
+	}
+	for _, reason := range canceled.CancellationReasons {
+		if aws.ToString(reason.Code) == "ConditionalCheckFailed" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// OutboxKind は後から実行する処理の種類
+type OutboxKind string
+
+const (
+	OutboxKindDeleteS3Objects OutboxKind = "delete_s3_objects" // S3オブジェクトの削除
+	OutboxKindIndexObstacle   OutboxKind = "index_obstacle"    // 検索インデックスの更新
+	OutboxKindPurgeObstacle   OutboxKind = "purge_obstacle"    // 完全削除した障害物の画像・確認・コメントの削除
+)
+
+// OutboxState は後から実行する処理の状態
+type OutboxState string
+
+const (
+	OutboxStatePending OutboxState = "pending" // 実行待ち（再試行待ちを含む）
+	OutboxStateDead    OutboxState = "dead"    // 再試行の上限に達した
+)
+
+// OutboxEntry は障害物の変更と同じトランザクションで記録し、確定後に実行する処理
+// S3の操作や件数の多い検索インデックスの書き込みはトランザクションに含められないため、記録しておいて失敗しても再試行できるようにする
+type OutboxEntry struct {
+	ID            string      `json:"id" dynamodbav:"id"`
+	Kind          OutboxKind  `json:"kind" dynamodbav:"kind"`
+	ObstacleID    int         `json:"obstacle_id" dynamodbav:"obstacle_id"`
+	S3Keys        []string    `json:"s3_keys,omitempty" dynamodbav:"s3_keys,omitempty"` // 未削除のS3キー
+	State         OutboxState `json:"state" dynamodbav:"state"`
+	Attempts      int         `json:"attempts" dynamodbav:"attempts"`
+	NextAttemptAt int64       `json:"next_attempt_at" dynamodbav:"next_attempt_at"` // 次に実行する時刻（UNIX秒）
+	LastError     string      `json:"last_error,omitempty" dynamodbav:"last_error,omitempty"`
+	CreatedAt     string      `json:"created_at" dynamodbav:"created_at"`
+	UpdatedAt     string      `json:"updated_at,omitempty" dynamodbav:"updated_at,omitempty"`
+}
+
+// NewDeleteS3ObjectsEntry はS3オブジェクトを削除する処理を作成する（削除するキーがない場合はnil）
+func NewDeleteS3ObjectsEntry(obstacleID int, s3Keys []string) (*OutboxEntry, error) {
+	if len(s3Keys) == 0 {
+		return nil, nil
+	}
+	id, err := newOutboxID()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &OutboxEntry{
+		ID:            id,
+		Kind:          OutboxKindDeleteS3Objects,
+		ObstacleID:    obstacleID,
+		S3Keys:        append([]string{}, s3Keys...),
+		State:         OutboxStatePending,
+		NextAttemptAt: now.Unix(),
+		CreatedAt:     now.Format(time.RFC3339),
+	}, nil
+}
+
+// NewPurgeObstacleEntry は完全削除した障害物の画像と、確認・コメントを削除する処理を作成する
+// 変更履歴は監査の記録と統合先からの履歴の参照のために残す
+func NewPurgeObstacleEntry(obstacleID int, s3Keys []string) (*OutboxEntry, error) {
+	id, err := newOutboxID()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &OutboxEntry{
+		ID:            id,
+		Kind:          OutboxKindPurgeObstacle,
+		ObstacleID:    obstacleID,
+		S3Keys:        append([]string{}, s3Keys...),
+		State:         OutboxStatePending,
+		NextAttemptAt: now.Unix(),
+		CreatedAt:     now.Format(time.RFC3339),
+	}, nil
+}
+
+// NewIndexObstacleEntry は障害物の検索インデックスを更新する処理を作成する
+// 実行時に障害物の最新の説明を読み直すため、何度実行しても同じ結果になる
+func NewIndexObstacleEntry(obstacleID int) (*OutboxEntry, error) {
+	id, err := newOutboxID()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &OutboxEntry{
+		ID:            id,
+		Kind:          OutboxKindIndexObstacle,
+		ObstacleID:    obstacleID,
+		State:         OutboxStatePending,
+		NextAttemptAt: now.Unix(),
+		CreatedAt:     now.Format(time.RFC3339),
+	}, nil
+}
+
+// newOutboxID は作成時刻順に並ぶIDを生成する
+func newOutboxID() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate outbox id: %w", err)
+	}
+	return fmt.Sprintf("%d-%s", time.Now().UnixNano(), hex.EncodeToString(b)), nil
+}
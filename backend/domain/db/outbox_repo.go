@@ -0,0 +1,128 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"webhook/shared/util"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// outboxStateIndex は状態と次の実行時刻で検索するインデックス
+const outboxStateIndex = "state-next_attempt_at-index"
+
+type OutboxRepo struct {
+	TableName string
+	Client    *dynamodb.Client
+}
+
+func NewOutboxRepo(ctx context.Context) (*OutboxRepo, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	return &OutboxRepo{
+		TableName: util.GetSetting().OutboxTable.TableName,
+		Client:    dynamodb.NewFromConfig(cfg),
+	}, nil
+}
+
+// ListDue は実行時刻を過ぎた実行待ちの処理を古い順に返す
+func (r *OutboxRepo) ListDue(ctx context.Context, now int64) ([]OutboxEntry, int, error) {
+	keyCond := expression.Key("state").Equal(expression.Value(OutboxStatePending)).
+		And(expression.Key("next_attempt_at").LessThanEqual(expression.Value(now)))
+	return r.query(ctx, keyCond)
+}
+
+// ListDead は再試行の上限に達した処理を返す
+func (r *OutboxRepo) ListDead(ctx context.Context) ([]OutboxEntry, int, error) {
+	keyCond := expression.Key("state").Equal(expression.Value(OutboxStateDead))
+	return r.query(ctx, keyCond)
+}
+
+func (r *OutboxRepo) query(ctx context.Context, keyCond expression.KeyConditionBuilder) ([]OutboxEntry, int, error) {
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	entries := []OutboxEntry{}
+	paginator := dynamodb.NewQueryPaginator(r.Client, &dynamodb.QueryInput{
+		TableName:                 aws.String(r.TableName),
+		IndexName:                 aws.String(outboxStateIndex),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("failed to query outbox: %w", err)
+		}
+
+		var items []OutboxEntry
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("failed to unmarshal outbox entry: %w", err)
+		}
+		entries = append(entries, items...)
+	}
+	return entries, http.StatusOK, nil
+}
+
+// Update は処理の状態（残りのキー・試行回数・次の実行時刻など）を保存する
+// 他の実行で完了済みの処理は作り直さない
+func (r *OutboxRepo) Update(ctx context.Context, entry *OutboxEntry) (int, error) {
+	item, err := attributevalue.MarshalMap(entry)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("failed to marshal outbox entry: %w", err)
+	}
+	_, err = r.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(r.TableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_exists(id)"),
+	})
+	if err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return http.StatusNotFound, nil
+		}
+		return http.StatusInternalServerError, fmt.Errorf("failed to update outbox entry: %w", err)
+	}
+	return http.StatusOK, nil
+}
+
+// Complete は完了した処理を削除する
+func (r *OutboxRepo) Complete(ctx context.Context, id string) (int, error) {
+	_, err := r.Client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(r.TableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+	})
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("failed to complete outbox entry: %w", err)
+	}
+	return http.StatusOK, nil
+}
+
+// outboxItem は障害物の変更と同じトランザクションで処理を記録する書き込みを組み立てる
+func outboxItem(tableName string, entry *OutboxEntry) (*types.Put, error) {
+	item, err := attributevalue.MarshalMap(entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal outbox entry: %w", err)
+	}
+	return &types.Put{
+		TableName:           aws.String(tableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	}, nil
+}
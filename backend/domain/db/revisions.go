@@ -0,0 +1,28 @@
+package db
+
+// ObstacleRevision は障害物の変更履歴（変更前後のスナップショット）
+type ObstacleRevision struct {
+	ObstacleID int       `json:"obstacle_id" dynamodbav:"obstacle_id"`
+	RevisionID int64     `json:"revision_id" dynamodbav:"revision_id"`
+	Actor      string    `json:"actor" dynamodbav:"actor"`
+	Source     string    `json:"source" dynamodbav:"source"`
+	Before     *Obstacle `json:"before,omitempty" dynamodbav:"before,omitempty"`
+	After      *Obstacle `json:"after,omitempty" dynamodbav:"after,omitempty"`
+	CreatedAt  string    `json:"created_at" dynamodbav:"created_at"`
+}
+
+// Audit は変更の実行者と変更元のエンドポイント
+type Audit struct {
+	Actor  string
+	Source string
+}
+
+// migrateLegacyImages はスナップショットの単一画像を画像リストの形式に変換する
+func (r *ObstacleRevision) migrateLegacyImages() {
+	if r.Before != nil {
+		r.Before.migrateLegacyImage()
+	}
+	if r.After != nil {
+		r.After.migrateLegacyImage()
+	}
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"webhook/shared/util"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type RevisionRepo struct {
+	TableName string
+	Client    *dynamodb.Client
+}
+
+func NewRevisionRepo(ctx context.Context) (*RevisionRepo, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	revisionTable := util.GetSetting().ObstacleRevisionTable
+	return &RevisionRepo{
+		TableName: revisionTable.TableName,
+		Client:    dynamodb.NewFromConfig(cfg),
+	}, nil
+}
+
+// List は障害物の変更履歴を新しい順に返す
+func (r *RevisionRepo) List(ctx context.Context, obstacleID int) (*[]ObstacleRevision, int, error) {
+	keyCond := expression.Key("obstacle_id").Equal(expression.Value(obstacleID))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	revisions := []ObstacleRevision{}
+	paginator := dynamodb.NewQueryPaginator(r.Client, &dynamodb.QueryInput{
+		TableName:                 aws.String(r.TableName),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ScanIndexForward:          aws.Bool(false),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("failed to query obstacle revisions: %w", err)
+		}
+
+		var items []ObstacleRevision
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("failed to unmarshal obstacle revision: %w", err)
+		}
+		for i := range items {
+			items[i].migrateLegacyImages()
+		}
+		revisions = append(revisions, items...)
+	}
+	return &revisions, http.StatusOK, nil
+}
+
+func (r *RevisionRepo) Get(ctx context.Context, obstacleID int, revisionID int64) (*ObstacleRevision, int, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(r.TableName),
+		Key: map[string]types.AttributeValue{
+			"obstacle_id": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", obstacleID)},
+			"revision_id": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", revisionID)},
+		},
+	}
+
+	result, err := r.Client.GetItem(ctx, input)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("failed to get obstacle revision: %w", err)
+	}
+	if result.Item == nil {
+		return nil, http.StatusNotFound, nil
+	}
+
+	var revision ObstacleRevision
+	err = attributevalue.UnmarshalMap(result.Item, &revision)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("failed to unmarshal obstacle revision: %w", err)
+	}
+	revision.migrateLegacyImages()
+	return &revision, http.StatusOK, nil
+}
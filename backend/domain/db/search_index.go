@@ -0,0 +1,9 @@
+package db
+
+// SearchPosting は検索インデックスの1件（トークンとそれを説明に含む障害物）
+type SearchPosting struct {
+	Token      string `json:"token" dynamodbav:"token"`
+	ObstacleID int    `json:"obstacle_id" dynamodbav:"obstacle_id"`
+	Count      int    `json:"count" dynamodbav:"count"`   // 説明でのトークンの出現回数
+	Length     int    `json:"length" dynamodbav:"length"` // 説明のトークンの総数（説明の長さによる補正に使う）
+}
@@ -0,0 +1,144 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"webhook/shared/search"
+	"webhook/shared/util"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// searchIndexObstacleIndex は障害物ごとに登録済みのトークンを探すGSI（キーのみを射影する）
+	searchIndexObstacleIndex = "obstacle_id-index"
+)
+
+// SearchIndexRepo は障害物の説明の全文検索用の転置インデックス
+// パーティションキーがトークン、ソートキーが障害物IDで、トークンを含む障害物をQueryで取得する
+type SearchIndexRepo struct {
+	TableName string
+	Client    *dynamodb.Client
+}
+
+func NewSearchIndexRepo(ctx context.Context) (*SearchIndexRepo, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	return &SearchIndexRepo{
+		TableName: util.GetSetting().SearchIndexTable.TableName,
+		Client:    dynamodb.NewFromConfig(cfg),
+	}, nil
+}
+
+// Postings はトークンを説明に含む障害物をすべて返す
+func (r *SearchIndexRepo) Postings(ctx context.Context, token string) ([]SearchPosting, int, error) {
+	keyCond := expression.Key("token").Equal(expression.Value(token))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	var postings []SearchPosting
+	paginator := dynamodb.NewQueryPaginator(r.Client, &dynamodb.QueryInput{
+		TableName:                 aws.String(r.TableName),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("failed to query search index: %w", err)
+		}
+		var items []SearchPosting
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("failed to unmarshal search posting: %w", err)
+		}
+		postings = append(postings, items...)
+	}
+	return postings, http.StatusOK, nil
+}
+
+// Index は障害物の説明のトークンを登録し、説明からなくなったトークンを削除する
+// 説明が空の場合（障害物を物理削除した場合を含む）は障害物のトークンをすべて削除する
+func (r *SearchIndexRepo) Index(ctx context.Context, obstacleID int, description string) (int, error) {
+	registered, statusCode, err := r.tokensOf(ctx, obstacleID)
+	if err != nil {
+		return statusCode, err
+	}
+
+	tokens := search.Tokens(description)
+	length := 0
+	for _, count := range tokens {
+		length += count
+	}
+
+	var requests []types.WriteRequest
+	for token, count := range tokens {
+		item, err := attributevalue.MarshalMap(SearchPosting{Token: token, ObstacleID: obstacleID, Count: count, Length: length})
+		if err != nil {
+			return http.StatusInternalServerError, fmt.Errorf("failed to marshal search posting: %w", err)
+		}
+		requests = append(requests, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+	}
+	for _, token := range registered {
+		if _, ok := tokens[token]; ok {
+			continue
+		}
+		requests = append(requests, types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: searchPostingKey(token, obstacleID)}})
+	}
+
+	if err := batchWrite(ctx, r.Client, r.TableName, requests); err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("failed to write search index: %w", err)
+	}
+	return http.StatusOK, nil
+}
+
+// tokensOf は障害物について登録済みのトークンを返す
+func (r *SearchIndexRepo) tokensOf(ctx context.Context, obstacleID int) ([]string, int, error) {
+	keyCond := expression.Key("obstacle_id").Equal(expression.Value(obstacleID))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("failed to build expression: %w", err)
+	}
+
+	var tokens []string
+	paginator := dynamodb.NewQueryPaginator(r.Client, &dynamodb.QueryInput{
+		TableName:                 aws.String(r.TableName),
+		IndexName:                 aws.String(searchIndexObstacleIndex),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("failed to query search index: %w", err)
+		}
+		var items []SearchPosting
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("failed to unmarshal search posting: %w", err)
+		}
+		for _, item := range items {
+			tokens = append(tokens, item.Token)
+		}
+	}
+	return tokens, http.StatusOK, nil
+}
+
+func searchPostingKey(token string, obstacleID int) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"token":       &types.AttributeValueMemberS{Value: token},
+		"obstacle_id": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", obstacleID)},
+	}
+}
@@ -0,0 +1,97 @@
+package osmapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"webhook/shared/osm"
+)
+
+// maxElementsPerRequest は1回の要求で取得する要素の数（URLの長さの上限に収まる数）
+const maxElementsPerRequest = 100
+
+type OSMAPIRepo interface {
+	GetNodes(ctx context.Context, ids []int64) ([]*osm.Node, error)
+	GetWaysOfNode(ctx context.Context, id int64) ([]*osm.Way, error)
+}
+
+type osmAPIRepo struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewOSMAPIRepo はOpenStreetMapの編集用API（読み取りのみ）を使うリポジトリを作成する
+func NewOSMAPIRepo() OSMAPIRepo {
+	return &osmAPIRepo{
+		baseURL: "https://api.openstreetmap.org/api/0.6",
+		client: &http.Client{
+			Timeout: time.Second * 30,
+		},
+	}
+}
+
+// GetNodes はノードの最新版を返す（削除済みのノードは含まない）
+func (r *osmAPIRepo) GetNodes(ctx context.Context, ids []int64) ([]*osm.Node, error) {
+	var nodes []*osm.Node
+	for start := 0; start < len(ids); start += maxElementsPerRequest {
+		end := min(start+maxElementsPerRequest, len(ids))
+		values := make([]string, 0, end-start)
+		for _, id := range ids[start:end] {
+			values = append(values, strconv.FormatInt(id, 10))
+		}
+
+		url := fmt.Sprintf("%s/nodes?nodes=%s", r.baseURL, strings.Join(values, ","))
+		err := r.get(ctx, url, osm.Handler{
+			Node: func(node *osm.Node) error {
+				nodes = append(nodes, node)
+				return nil
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+// GetWaysOfNode はノードを構成ノードに含むウェイの最新版を返す
+func (r *osmAPIRepo) GetWaysOfNode(ctx context.Context, id int64) ([]*osm.Way, error) {
+	var ways []*osm.Way
+	url := fmt.Sprintf("%s/node/%d/ways", r.baseURL, id)
+	err := r.get(ctx, url, osm.Handler{
+		Way: func(way *osm.Way) error {
+			ways = append(ways, way)
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ways, nil
+}
+
+func (r *osmAPIRepo) get(ctx context.Context, url string, h osm.Handler) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	// 利用規約により、アプリケーションを識別できるUser-Agentを付ける
+	req.Header.Set("User-Agent", "osrm-obstacle-management/1.0")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("OSM API returned status %d: %s", resp.StatusCode, string(body))
+	}
+	return osm.ReadXML(resp.Body, h)
+}
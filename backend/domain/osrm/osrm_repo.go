@@ -0,0 +1,91 @@
+package osrm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// NearestRoad は地点の最寄りの道路
+type NearestRoad struct {
+	Nodes    []int64    // 最寄りの道路区間の両端のOSMノードID（昇順）
+	Distance float64    // 地点から道路までの距離（メートル）
+	Name     string     // 道路名
+	Location [2]float64 // 道路上の最寄りの地点 [緯度, 経度]
+}
+
+type OSRMRepo interface {
+	Nearest(ctx context.Context, position [2]float64) (*NearestRoad, error)
+}
+
+type osrmRepo struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewOSRMRepo はフロントエンドの障害物登録と同じOSRMサーバーを使うリポジトリを作成する
+func NewOSRMRepo() OSRMRepo {
+	return &osrmRepo{
+		baseURL: "https://router.project-osrm.org",
+		client: &http.Client{
+			Timeout: time.Second * 10,
+		},
+	}
+}
+
+type nearestResponse struct {
+	Code      string `json:"code"`
+	Waypoints []struct {
+		Nodes    []int64    `json:"nodes"`
+		Distance float64    `json:"distance"`
+		Name     string     `json:"name"`
+		Location [2]float64 `json:"location"` // [経度, 緯度]
+	} `json:"waypoints"`
+}
+
+// Nearest は地点（[緯度, 経度]）の最寄りの道路を返す（近くに道路がない場合はnil）
+func (r *osrmRepo) Nearest(ctx context.Context, position [2]float64) (*NearestRoad, error) {
+	// OSRMは[経度, 緯度]の順序で指定する
+	url := fmt.Sprintf("%s/nearest/v1/driving/%f,%f?number=1", r.baseURL, position[1], position[0])
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("OSRM API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var nearest nearestResponse
+	if err := json.NewDecoder(resp.Body).Decode(&nearest); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(nearest.Waypoints) == 0 {
+		return nil, nil
+	}
+
+	waypoint := nearest.Waypoints[0]
+	nodes := append([]int64{}, waypoint.Nodes...)
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i] < nodes[j] })
+	if len(nodes) > 2 {
+		nodes = nodes[:2]
+	}
+	return &NearestRoad{
+		Nodes:    nodes,
+		Distance: waypoint.Distance,
+		Name:     waypoint.Name,
+		Location: [2]float64{waypoint.Location[1], waypoint.Location[0]},
+	}, nil
+}
@@ -1,8 +1,13 @@
 package s3
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/url"
+	"strings"
 	"time"
 
 	"webhook/shared/util"
@@ -10,20 +15,30 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 type S3Repo struct {
 	BucketName string
+	URLExpiry  time.Duration
 	Client     *s3.Client
 }
 
+// PresignedPost はブラウザからフォームでアップロードするためのURLとフォーム項目
+type PresignedPost struct {
+	URL    string            `json:"url"`
+	Fields map[string]string `json:"fields"`
+}
+
 func NewS3Repo() (*S3Repo, error) {
 	cfg, err := config.LoadDefaultConfig(context.TODO())
 	if err != nil {
 		return nil, fmt.Errorf("unable to load SDK config: %w", err)
 	}
+	bucket := util.GetSetting().ObstacleImageBucket
 	return &S3Repo{
-		BucketName: util.GetSetting().ObstacleImageBucket.BucketName,
+		BucketName: bucket.BucketName,
+		URLExpiry:  bucket.URLExpiry,
 		Client:     s3.NewFromConfig(cfg),
 	}, nil
 }
@@ -40,16 +55,154 @@ func (r *S3Repo) DeleteObject(s3Key string) error {
 	return err
 }
 
-// プリサインドURL生成（PUT）
-func (r *S3Repo) GeneratePresignedPUTURL(s3Key string) (string, error) {
+// プリサインドURL生成（GET）
+func (r *S3Repo) GeneratePresignedGETURL(s3Key string) (string, error) {
 	presignClient := s3.NewPresignClient(r.Client)
 
-	req, err := presignClient.PresignPutObject(context.TODO(), &s3.PutObjectInput{
+	req, err := presignClient.PresignGetObject(context.TODO(), &s3.GetObjectInput{
 		Bucket: aws.String(r.BucketName),
 		Key:    aws.String(s3Key),
-	}, s3.WithPresignExpires(15*time.Minute))
+	}, s3.WithPresignExpires(r.URLExpiry))
 	if err != nil {
-		return "", fmt.Errorf("failed to generate presigned PUT URL: %w", err)
+		return "", fmt.Errorf("failed to generate presigned GET URL: %w", err)
 	}
 	return req.URL, nil
 }
+
+// S3オブジェクト取得
+func (r *S3Repo) GetObject(s3Key string) ([]byte, error) {
+	resp, err := r.Client.GetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: aws.String(r.BucketName),
+		Key:    aws.String(s3Key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object %s: %w", s3Key, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object %s: %w", s3Key, err)
+	}
+	return data, nil
+}
+
+// S3オブジェクト保存
+func (r *S3Repo) PutObject(s3Key string, data []byte, contentType string) error {
+	_, err := r.Client.PutObject(context.TODO(), &s3.PutObjectInput{
+		Bucket:      aws.String(r.BucketName),
+		Key:         aws.String(s3Key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put object %s: %w", s3Key, err)
+	}
+	return nil
+}
+
+// ダウンロード用のS3オブジェクト保存
+// ブラウザでプリサインドURLを開いたときにfileNameで保存されるようにする
+func (r *S3Repo) PutDownload(s3Key string, data []byte, contentType, fileName string) error {
+	_, err := r.Client.PutObject(context.TODO(), &s3.PutObjectInput{
+		Bucket:             aws.String(r.BucketName),
+		Key:                aws.String(s3Key),
+		Body:               bytes.NewReader(data),
+		ContentType:        aws.String(contentType),
+		ContentDisposition: aws.String(fmt.Sprintf("attachment; filename=%q", fileName)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put object %s: %w", s3Key, err)
+	}
+	return nil
+}
+
+// プリサインドPOST生成
+// ポリシーでContent-Typeとサイズの上限を制限する
+func (r *S3Repo) GeneratePresignedPOST(s3Key, contentType string, maxBytes int64) (*PresignedPost, error) {
+	presignClient := s3.NewPresignClient(r.Client)
+
+	req, err := presignClient.PresignPostObject(context.TODO(), &s3.PutObjectInput{
+		Bucket: aws.String(r.BucketName),
+		Key:    aws.String(s3Key),
+	}, func(o *s3.PresignPostOptions) {
+		o.Expires = 15 * time.Minute
+		o.Conditions = []interface{}{
+			[]interface{}{"eq", "$Content-Type", contentType},
+			[]interface{}{"content-length-range", 1, maxBytes},
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate presigned POST: %w", err)
+	}
+
+	fields := map[string]string{"Content-Type": contentType}
+	for k, v := range req.Values {
+		fields[k] = v
+	}
+	return &PresignedPost{URL: req.URL, Fields: fields}, nil
+}
+
+// S3オブジェクトの存在確認
+func (r *S3Repo) ObjectExists(s3Key string) (bool, error) {
+	_, err := r.Client.HeadObject(context.TODO(), &s3.HeadObjectInput{
+		Bucket: aws.String(r.BucketName),
+		Key:    aws.String(s3Key),
+	})
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to head object %s: %w", s3Key, err)
+	}
+	return true, nil
+}
+
+// Object はS3オブジェクトの一覧の要素
+type Object struct {
+	Key          string
+	LastModified time.Time
+}
+
+// S3オブジェクト一覧取得（プレフィックス指定、全ページ）
+func (r *S3Repo) ListObjects(prefix string, fn func(Object) error) error {
+	paginator := s3.NewListObjectsV2Paginator(r.Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(r.BucketName),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return fmt.Errorf("failed to list objects under %s: %w", prefix, err)
+		}
+		for _, obj := range page.Contents {
+			if err := fn(Object{Key: aws.ToString(obj.Key), LastModified: aws.ToTime(obj.LastModified)}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// S3オブジェクト移動（コピー後に元のオブジェクトを削除）
+func (r *S3Repo) MoveObject(srcKey, dstKey string) error {
+	_, err := r.Client.CopyObject(context.TODO(), &s3.CopyObjectInput{
+		Bucket:     aws.String(r.BucketName),
+		CopySource: aws.String(copySource(r.BucketName, srcKey)),
+		Key:        aws.String(dstKey),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to copy object %s to %s: %w", srcKey, dstKey, err)
+	}
+	return r.DeleteObject(srcKey)
+}
+
+// copySource はCopyObjectのコピー元（URLエンコードした bucket/key）を返す
+func copySource(bucket, key string) string {
+	segments := strings.Split(bucket+"/"+key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
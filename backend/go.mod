@@ -13,6 +13,7 @@ require (
 	github.com/aws/aws-sdk-go-v2/service/dynamodb v1.43.1
 	github.com/aws/aws-sdk-go-v2/service/s3 v1.79.3
 	go.uber.org/zap v1.27.0
+	golang.org/x/image v0.24.0
 )
 
 require (
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"webhook/usecase"
+	"webhook/usecase/input"
+	"webhook/usecase/output"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"go.uber.org/zap"
+)
+
+// imageEventActor は自動登録の変更履歴に記録する操作者
+const imageEventActor = "s3-event"
+
+// maxAttachAttempts はAPIからの登録と競合した場合に登録を試みる回数
+const maxAttachAttempts = 3
+
+// AttachFunc は画像を障害物に登録する処理（テスト時に差し替えられるようにする）
+type AttachFunc func(ctx context.Context, input input.ObstacleUpdateImageS3Key) (*output.Obstacle, int, error)
+
+// Handler はS3のObjectCreatedイベントを受け取り、アップロードされた画像を障害物に登録する
+type Handler struct {
+	Attach AttachFunc
+	Logger *zap.Logger
+}
+
+// NewHandler は既存のユースケースで画像を登録するHandlerを作成する
+func NewHandler(logger *zap.Logger) *Handler {
+	return &Handler{
+		Attach: usecase.UpdateObstacleImageS3Key,
+		Logger: logger,
+	}
+}
+
+// HandleRequest はイベント内のすべての画像を登録する
+// 再試行しても解決しないエラー（障害物が存在しない、形式が不正など）はログに記録して無視し、
+// 一時的なエラーの場合のみエラーを返してLambdaに再試行させる
+func (h *Handler) HandleRequest(ctx context.Context, event events.S3Event) error {
+	var errs []error
+	for _, record := range event.Records {
+		key, err := url.QueryUnescape(record.S3.Object.Key)
+		if err != nil {
+			h.Logger.Warn("invalid object key", zap.String("key", record.S3.Object.Key), zap.Error(err))
+			continue
+		}
+		obstacleID, ok := parseObstacleID(key)
+		if !ok {
+			h.Logger.Info("skipped object outside obstacle images", zap.String("key", key))
+			continue
+		}
+
+		if err := h.attach(ctx, obstacleID, key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h *Handler) attach(ctx context.Context, obstacleID, key string) error {
+	input := input.ObstacleUpdateImageS3Key{
+		Audit: input.Audit{
+			Actor:  imageEventActor,
+			Source: "S3 ObjectCreated",
+		},
+		ID:         obstacleID,
+		ImageS3Key: key,
+	}
+
+	for attempt := 1; ; attempt++ {
+		obstacle, statusCode, err := h.Attach(ctx, input)
+		switch {
+		case err == nil && obstacle == nil:
+			h.Logger.Warn("obstacle not found for uploaded image", zap.String("obstacle_id", obstacleID), zap.String("key", key))
+			return nil
+		case err == nil:
+			h.Logger.Info("attached uploaded image", zap.String("obstacle_id", obstacleID), zap.String("key", key), zap.Int("version", obstacle.Version))
+			return nil
+		case statusCode == http.StatusPreconditionFailed && attempt < maxAttachAttempts:
+			// APIからの登録などと同時に更新された場合は最新の状態で登録し直す
+			continue
+		case statusCode >= 400 && statusCode < 500:
+			h.Logger.Warn("skipped uploaded image", zap.String("obstacle_id", obstacleID), zap.String("key", key), zap.Int("status_code", statusCode), zap.Error(err))
+			return nil
+		default:
+			h.Logger.Error("failed to attach uploaded image", zap.String("obstacle_id", obstacleID), zap.String("key", key), zap.Error(err))
+			return fmt.Errorf("failed to attach %s: %w", key, err)
+		}
+	}
+}
+
+// parseObstacleID は obstacles/{id}/{file} 形式のキーから障害物IDを取り出す
+func parseObstacleID(key string) (string, bool) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 3 || parts[0] != "obstacles" || parts[1] == "" || parts[2] == "" {
+		return "", false
+	}
+	for _, c := range parts[1] {
+		if c < '0' || c > '9' {
+			return "", false
+		}
+	}
+	return parts[1], true
+}
+
+func main() {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	lambda.Start(NewHandler(logger).HandleRequest)
+}
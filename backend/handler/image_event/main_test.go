@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"webhook/usecase/input"
+	"webhook/usecase/output"
+
+	"github.com/aws/aws-lambda-go/events"
+	"go.uber.org/zap"
+)
+
+func s3Event(keys ...string) events.S3Event {
+	var event events.S3Event
+	for _, key := range keys {
+		event.Records = append(event.Records, events.S3EventRecord{
+			EventName: "ObjectCreated:Post",
+			S3: events.S3Entity{
+				Bucket: events.S3Bucket{Name: "dev-obstacle-image-bucket"},
+				Object: events.S3Object{Key: key},
+			},
+		})
+	}
+	return event
+}
+
+// attachCall は差し替えたAttachに渡された入力
+type attachCall struct {
+	id  string
+	key string
+}
+
+// fakeAttach はstatusesの順に結果を返すAttach（最後の結果を繰り返す）
+func fakeAttach(calls *[]attachCall, statuses ...int) AttachFunc {
+	return func(ctx context.Context, input input.ObstacleUpdateImageS3Key) (*output.Obstacle, int, error) {
+		*calls = append(*calls, attachCall{id: input.ID, key: input.ImageS3Key})
+		statusCode := statuses[min(len(*calls), len(statuses))-1]
+		switch statusCode {
+		case http.StatusOK:
+			return &output.Obstacle{Version: len(*calls)}, statusCode, nil
+		case http.StatusNotFound:
+			return nil, statusCode, nil
+		}
+		return nil, statusCode, errors.New(http.StatusText(statusCode))
+	}
+}
+
+func TestParseObstacleID(t *testing.T) {
+	tests := []struct {
+		key    string
+		wantID string
+		wantOK bool
+	}{
+		{"obstacles/12/abc.jpg", "12", true},
+		{"obstacles/12/", "", false},
+		{"obstacles//abc.jpg", "", false},
+		{"obstacles/12a/abc.jpg", "", false},
+		{"obstacles/12/nested/abc.jpg", "", false},
+		{"obstacles/abc.jpg", "", false},
+		{"thumbnails/200/obstacles/12/abc.jpg", "", false},
+		{"processed/obstacles/12/abc.jpg", "", false},
+		{"quarantine/obstacles/12/abc.jpg", "", false},
+		{"exports/20260101T000000Z/obstacles.csv", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		id, ok := parseObstacleID(tt.key)
+		if id != tt.wantID || ok != tt.wantOK {
+			t.Errorf("parseObstacleID(%q) = (%q, %v), want (%q, %v)", tt.key, id, ok, tt.wantID, tt.wantOK)
+		}
+	}
+}
+
+func TestHandleRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		event     events.S3Event
+		statuses  []int
+		wantCalls []attachCall
+		wantErr   bool
+	}{
+		{
+			name:      "attaches an uploaded image",
+			event:     s3Event("obstacles/12/abc.jpg"),
+			statuses:  []int{http.StatusOK},
+			wantCalls: []attachCall{{"12", "obstacles/12/abc.jpg"}},
+		},
+		{
+			name:      "decodes the URL-encoded key",
+			event:     s3Event("obstacles/12/a+b%28c%29.jpg"),
+			statuses:  []int{http.StatusOK},
+			wantCalls: []attachCall{{"12", "obstacles/12/a b(c).jpg"}},
+		},
+		{
+			name:     "ignores the objects written by the image processing",
+			event:    s3Event("processed/obstacles/12/abc.jpg", "thumbnails/200/obstacles/12/abc.jpg", "thumbnails/800/obstacles/12/abc.jpg"),
+			statuses: []int{http.StatusOK},
+		},
+		{
+			name:     "ignores the quarantine and exports",
+			event:    s3Event("quarantine/obstacles/12/abc.jpg", "exports/20260101T000000Z/obstacles.csv"),
+			statuses: []int{http.StatusOK},
+		},
+		{
+			name:      "retries a version conflict",
+			event:     s3Event("obstacles/12/abc.jpg"),
+			statuses:  []int{http.StatusPreconditionFailed, http.StatusPreconditionFailed, http.StatusOK},
+			wantCalls: []attachCall{{"12", "obstacles/12/abc.jpg"}, {"12", "obstacles/12/abc.jpg"}, {"12", "obstacles/12/abc.jpg"}},
+		},
+		{
+			name:      "gives up after three version conflicts",
+			event:     s3Event("obstacles/12/abc.jpg"),
+			statuses:  []int{http.StatusPreconditionFailed},
+			wantCalls: []attachCall{{"12", "obstacles/12/abc.jpg"}, {"12", "obstacles/12/abc.jpg"}, {"12", "obstacles/12/abc.jpg"}},
+		},
+		{
+			name:      "skips an image of a missing obstacle",
+			event:     s3Event("obstacles/12/abc.jpg"),
+			statuses:  []int{http.StatusNotFound},
+			wantCalls: []attachCall{{"12", "obstacles/12/abc.jpg"}},
+		},
+		{
+			name:      "skips an unsupported image",
+			event:     s3Event("obstacles/12/abc.jpg"),
+			statuses:  []int{http.StatusUnsupportedMediaType},
+			wantCalls: []attachCall{{"12", "obstacles/12/abc.jpg"}},
+		},
+		{
+			name:      "returns a temporary error for Lambda to retry",
+			event:     s3Event("obstacles/12/abc.jpg"),
+			statuses:  []int{http.StatusInternalServerError},
+			wantCalls: []attachCall{{"12", "obstacles/12/abc.jpg"}},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []attachCall
+			handler := &Handler{
+				Attach: fakeAttach(&calls, tt.statuses...),
+				Logger: zap.NewNop(),
+			}
+			err := handler.HandleRequest(context.Background(), tt.event)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("HandleRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(calls) != len(tt.wantCalls) {
+				t.Fatalf("Attach called %d times (%v), want %d", len(calls), calls, len(tt.wantCalls))
+			}
+			for i := range calls {
+				if calls[i] != tt.wantCalls[i] {
+					t.Errorf("Attach call %d = %v, want %v", i, calls[i], tt.wantCalls[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+
+	"webhook/shared/util"
+	"webhook/usecase"
+	"webhook/usecase/input"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"go.uber.org/zap"
+)
+
+// HandleRequest はスケジュール実行で障害物から参照されていない画像を削除（隔離）する
+func HandleRequest(ctx context.Context, event events.CloudWatchEvent) error {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	setting := util.GetSetting().ImageGC
+	input := input.ImageGC{
+		GracePeriod: setting.GracePeriod,
+		Quarantine:  setting.Quarantine,
+	}
+	response, _, err := usecase.CollectOrphanedImages(ctx, input)
+	if err != nil {
+		logger.Error("failed to collect orphaned images", zap.Error(err))
+		return err
+	}
+
+	logger.Info("collected orphaned images",
+		zap.Int("scanned_count", response.ScannedCount),
+		zap.Bool("quarantine", input.Quarantine),
+		zap.Strings("collected_keys", response.CollectedKeys),
+		zap.Strings("failed_keys", response.FailedKeys),
+	)
+	return nil
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
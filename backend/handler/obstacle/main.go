@@ -2,14 +2,19 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
-	"webhook/domain/s3"
 	apiinput "webhook/pkg/api/input"
+	"webhook/shared/util"
 	"webhook/usecase"
 	"webhook/usecase/input"
+	"webhook/usecase/output"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
@@ -23,6 +28,12 @@ type ErrorResponse struct {
 	Errors     map[string][]string `json:"errors,omitempty"`
 }
 
+// DuplicateObstacleResponse is returned when a similar obstacle has already been reported nearby
+type DuplicateObstacleResponse struct {
+	ErrorResponse
+	Candidates []output.Obstacle `json:"candidates"`
+}
+
 func HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	// Initialize logger
 	logger, _ := zap.NewProduction()
@@ -34,17 +45,30 @@ func HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (
 			StatusCode: http.StatusOK,
 			Headers: map[string]string{
 				"Access-Control-Allow-Origin":  "*",
-				"Access-Control-Allow-Methods": "GET,POST,PUT,DELETE,OPTIONS",
-				"Access-Control-Allow-Headers": "Content-Type,Authorization",
+				"Access-Control-Allow-Methods": "GET,POST,PUT,PATCH,DELETE,OPTIONS",
+				"Access-Control-Allow-Headers": "Content-Type,Authorization,X-Actor,If-Match,If-None-Match",
 			},
 		}, nil
 	}
 
+	// バイナリのメディアタイプに*/*を指定しているため、API Gatewayはリクエストの本文をBase64で渡す
+	if request.IsBase64Encoded {
+		body, err := base64.StdEncoding.DecodeString(request.Body)
+		if err != nil {
+			return errorResponse(logger, request, http.StatusBadRequest, "Invalid request body", nil, err)
+		}
+		request.Body = string(body)
+		request.IsBase64Encoded = false
+	}
+
 	// Handle different HTTP methods and paths
 	switch {
-	// GET /obstacles - List all obstacles
+	// GET /obstacles - List obstacles page by page, filtered and sorted by the query parameters
 	case request.HTTPMethod == "GET" && request.Resource == "/obstacles":
-		response, statusCode, err := usecase.GetObstacles(ctx, input.ObstacleGetAll{})
+		input := obstacleFilterFromRequest(request)
+		input.Limit = request.QueryStringParameters["limit"]
+		input.Cursor = request.QueryStringParameters["cursor"]
+		response, statusCode, err := usecase.GetObstacles(ctx, input)
 		if err != nil {
 			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
 		}
@@ -57,23 +81,128 @@ func HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (
 			return errorResponse(logger, request, http.StatusBadRequest, "Invalid request body", nil, err)
 		}
 
+		// 重複を確認として記録する場合は、二重投票を防ぐため書き換えられるX-Actorヘッダーではなく認証済みのIDで識別する
+		audit := auditFromRequest(request)
+		if input.DuplicatePolicy(createRequest.OnDuplicate) == input.DuplicatePolicyConfirm {
+			var ok bool
+			if audit, ok = authenticatedAudit(request); !ok {
+				return errorResponse(logger, request, http.StatusUnauthorized, "Authentication is required to confirm a duplicate", nil, nil)
+			}
+		}
 		input := input.ObstacleCreate{
-			Position:    createRequest.Position,
-			Type:        createRequest.Type,
-			Description: createRequest.Description,
-			DangerLevel: createRequest.DangerLevel,
-			Nodes:       createRequest.Nodes,
+			Audit:           audit,
+			Position:        createRequest.Position,
+			Type:            createRequest.Type,
+			Description:     createRequest.Description,
+			DangerLevel:     createRequest.DangerLevel,
+			Nodes:           createRequest.Nodes,
 			NearestDistance: createRequest.NearestDistance,
-			NoNearbyRoad:  createRequest.NoNearbyRoad,
+			NoNearbyRoad:    createRequest.NoNearbyRoad,
+			OnDuplicate:     input.DuplicatePolicy(createRequest.OnDuplicate),
 		}
 
 		createdObstacle, statusCode, err := usecase.CreateObstacle(ctx, input)
+		var duplicateErr *usecase.DuplicateObstacleError
+		if errors.As(err, &duplicateErr) {
+			return jsonResponse(statusCode, DuplicateObstacleResponse{
+				ErrorResponse: ErrorResponse{StatusCode: statusCode, Message: err.Error()},
+				Candidates:    duplicateErr.Candidates,
+			})
+		}
 		if err != nil {
 			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
 		}
 
 		return jsonResponse(statusCode, createdObstacle)
 
+	// GET /obstacles/search - Search obstacle descriptions, ranked by relevance
+	case request.HTTPMethod == "GET" && request.Resource == "/obstacles/search":
+		input := input.ObstacleSearch{
+			Query:  request.QueryStringParameters["q"],
+			Filter: obstacleFilterFromRequest(request),
+		}
+		input.Filter.Limit = request.QueryStringParameters["limit"]
+		response, statusCode, err := usecase.SearchObstacles(ctx, input)
+		if err != nil {
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
+		}
+		return jsonResponse(statusCode, response)
+
+	// GET /obstacles/export - Export all matching obstacles as GeoJSON, CSV or KML
+	case request.HTTPMethod == "GET" && request.Resource == "/obstacles/export":
+		input := input.ObstacleExport{
+			Filter: obstacleFilterFromRequest(request),
+			Format: request.QueryStringParameters["format"],
+		}
+		export, statusCode, err := usecase.ExportObstacles(ctx, input)
+		if err != nil {
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
+		}
+
+		// 大きいファイルはS3に保存したため、ファイルの代わりにダウンロードURLと有効期限を返す
+		if export.URL != "" {
+			return jsonResponse(statusCode, export)
+		}
+		return events.APIGatewayProxyResponse{
+			StatusCode: statusCode,
+			Headers: map[string]string{
+				"Content-Type":                  export.ContentType,
+				"Content-Disposition":           fmt.Sprintf("attachment; filename=%q", export.FileName),
+				"Access-Control-Allow-Origin":   "*",
+				"Access-Control-Expose-Headers": "Content-Disposition",
+			},
+			Body: string(export.Body),
+		}, nil
+
+	// GET /tiles/obstacles/{z}/{x}/{y}.mvt - Get the obstacles inside a map tile as a Mapbox Vector Tile
+	case request.HTTPMethod == "GET" && request.Resource == "/tiles/obstacles/{z}/{x}/{y}":
+		input := input.ObstacleTile{
+			Z:      request.PathParameters["z"],
+			X:      request.PathParameters["x"],
+			Y:      strings.TrimSuffix(request.PathParameters["y"], ".mvt"),
+			Filter: obstacleFilterFromRequest(request),
+		}
+		tile, statusCode, err := usecase.GetObstacleTile(ctx, input)
+		if err != nil {
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
+		}
+
+		headers := map[string]string{
+			"Content-Type":                  "application/vnd.mapbox-vector-tile",
+			"Cache-Control":                 fmt.Sprintf("public, max-age=%d", int(util.GetSetting().Tile.MaxAge.Seconds())),
+			"ETag":                          tile.ETag,
+			"Access-Control-Allow-Origin":   "*",
+			"Access-Control-Expose-Headers": "ETag",
+		}
+		// 内容が変わっていない場合は本文を返さない
+		if headerValue(request, "If-None-Match") == tile.ETag {
+			return events.APIGatewayProxyResponse{StatusCode: http.StatusNotModified, Headers: headers}, nil
+		}
+		return events.APIGatewayProxyResponse{
+			StatusCode:      statusCode,
+			Headers:         headers,
+			Body:            base64.StdEncoding.EncodeToString(tile.Body),
+			IsBase64Encoded: true,
+		}, nil
+
+	// POST /obstacles:import - Import obstacles from a GeoJSON FeatureCollection or a CSV
+	case request.HTTPMethod == "POST" && request.Resource == "/obstacles:import":
+		data := []byte(request.Body)
+
+		input := input.ObstacleImport{
+			Audit:   auditFromRequest(request),
+			Format:  importFormat(request),
+			Data:    data,
+			DryRun:  request.QueryStringParameters["dry_run"] == "true",
+			MaxRows: util.GetSetting().Import.MaxRows,
+		}
+		importResponse, statusCode, err := usecase.ImportObstacles(ctx, input)
+		if err != nil {
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
+		}
+
+		return jsonResponse(statusCode, importResponse)
+
 	// GET /obstacles/{id} - Get an obstacle by ID
 	case request.HTTPMethod == "GET" && request.Resource == "/obstacles/{id}":
 		idStr := request.PathParameters["id"]
@@ -91,7 +220,7 @@ func HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (
 			return errorResponse(logger, request, http.StatusNotFound, "Obstacle not found", nil, nil)
 		}
 
-		return jsonResponse(statusCode, foundObstacle)
+		return jsonResponseWithHeaders(statusCode, foundObstacle, etagHeaders(foundObstacle.Version))
 
 	// PUT /obstacles/{id} - Update an obstacle
 	case request.HTTPMethod == "PUT" && request.Resource == "/obstacles/{id}":
@@ -103,32 +232,87 @@ func HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (
 			return errorResponse(logger, request, http.StatusBadRequest, "Invalid request body", nil, err)
 		}
 
+		expectedVersion, statusCode, err := ifMatchVersion(request)
+		if err != nil {
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
+		}
+
 		input := input.ObstacleUpdate{
-			ID:          idStr,
-			Position:    updateRequest.Position,
-			Type:        updateRequest.Type,
-			Description: updateRequest.Description,
-			DangerLevel: updateRequest.DangerLevel,
-			Nodes:       updateRequest.Nodes,
+			Audit:           auditFromRequest(request),
+			ExpectedVersion: expectedVersion,
+			ID:              idStr,
+			Position:        updateRequest.Position,
+			Type:            updateRequest.Type,
+			Description:     updateRequest.Description,
+			DangerLevel:     updateRequest.DangerLevel,
+			Nodes:           updateRequest.Nodes,
 			NearestDistance: updateRequest.NearestDistance,
-			NoNearbyRoad:  updateRequest.NoNearbyRoad,
+			NoNearbyRoad:    updateRequest.NoNearbyRoad,
 		}
 
 		updatedObstacle, statusCode, err := usecase.UpdateObstacle(ctx, input)
 		if err != nil {
 			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
 		}
+		if updatedObstacle == nil {
+			return errorResponse(logger, request, http.StatusNotFound, "Obstacle not found", nil, nil)
+		}
+
+		return jsonResponseWithHeaders(statusCode, updatedObstacle, etagHeaders(updatedObstacle.Version))
+
+	// PATCH /obstacles/{id} - Partially update an obstacle (JSON Merge Patch)
+	case request.HTTPMethod == "PATCH" && request.Resource == "/obstacles/{id}":
+		idStr := request.PathParameters["id"]
+
+		contentType := strings.TrimSpace(strings.Split(headerValue(request, "Content-Type"), ";")[0])
+		if contentType != "application/merge-patch+json" && contentType != "application/json" {
+			return errorResponse(logger, request, http.StatusUnsupportedMediaType, "Content-Type must be application/merge-patch+json", nil, nil)
+		}
+
+		var patchRequest apiinput.PatchObstacleRequest
+		if err := json.Unmarshal([]byte(request.Body), &patchRequest); err != nil {
+			return errorResponse(logger, request, http.StatusBadRequest, "Invalid request body", nil, err)
+		}
+
+		expectedVersion, statusCode, err := ifMatchVersion(request)
+		if err != nil {
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
+		}
 
-		return jsonResponse(statusCode, updatedObstacle)
+		input := input.ObstaclePatch{
+			Audit:           auditFromRequest(request),
+			ExpectedVersion: expectedVersion,
+			ID:              idStr,
+		}
+		if err := mergePatchInput(patchRequest, &input); err != nil {
+			return errorResponse(logger, request, http.StatusBadRequest, err.Error(), nil, err)
+		}
+
+		patchedObstacle, statusCode, err := usecase.PatchObstacle(ctx, input)
+		if err != nil {
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
+		}
+		if patchedObstacle == nil {
+			return errorResponse(logger, request, http.StatusNotFound, "Obstacle not found", nil, nil)
+		}
+
+		return jsonResponseWithHeaders(statusCode, patchedObstacle, etagHeaders(patchedObstacle.Version))
 
 	// DELETE /obstacles/{id} - Delete an obstacle
 	case request.HTTPMethod == "DELETE" && request.Resource == "/obstacles/{id}":
 		idStr := request.PathParameters["id"]
+		expectedVersion, statusCode, err := ifMatchVersion(request)
+		if err != nil {
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
+		}
+
 		input := input.ObstacleDelete{
-			ID: idStr,
+			Audit:           auditFromRequest(request),
+			ExpectedVersion: expectedVersion,
+			ID:              idStr,
 		}
 
-		statusCode, err := usecase.DeleteObstacle(ctx, input)
+		statusCode, err = usecase.DeleteObstacle(ctx, input)
 		if err != nil {
 			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
 		}
@@ -141,31 +325,403 @@ func HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (
 			},
 		}, nil
 
+	// POST /obstacles/{id}/confirmations - Confirm whether an obstacle is still there
+	case request.HTTPMethod == "POST" && request.Resource == "/obstacles/{id}/confirmations":
+		var confirmationRequest apiinput.ObstacleConfirmationRequest
+		if err := json.Unmarshal([]byte(request.Body), &confirmationRequest); err != nil {
+			return errorResponse(logger, request, http.StatusBadRequest, "Invalid request body", nil, err)
+		}
+
+		// 二重投票を防ぐため、利用者は書き換えられるX-Actorヘッダーではなく認証済みのIDで識別する
+		audit, ok := authenticatedAudit(request)
+		if !ok {
+			return errorResponse(logger, request, http.StatusUnauthorized, "Authentication is required", nil, nil)
+		}
+		input := input.ObstacleConfirm{
+			Audit: audit,
+			ID:    request.PathParameters["id"],
+			Vote:  confirmationRequest.Vote,
+		}
+		confirmation, statusCode, err := usecase.ConfirmObstacle(ctx, input)
+		if err != nil {
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
+		}
+		if confirmation == nil {
+			return errorResponse(logger, request, http.StatusNotFound, "Obstacle not found", nil, nil)
+		}
+
+		return jsonResponse(statusCode, confirmation)
+
+	// POST /obstacles/{id}/merge - Fold duplicate obstacles into an obstacle
+	case request.HTTPMethod == "POST" && request.Resource == "/obstacles/{id}/merge":
+		var mergeRequest apiinput.MergeObstaclesRequest
+		if err := json.Unmarshal([]byte(request.Body), &mergeRequest); err != nil || len(mergeRequest.SourceIDs) == 0 {
+			return errorResponse(logger, request, http.StatusBadRequest, "Invalid request body or missing source_ids", nil, err)
+		}
+
+		expectedVersion, statusCode, err := ifMatchVersion(request)
+		if err != nil {
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
+		}
+
+		input := input.ObstacleMerge{
+			Audit:           auditFromRequest(request),
+			ExpectedVersion: expectedVersion,
+			ID:              request.PathParameters["id"],
+			SourceIDs:       mergeRequest.SourceIDs,
+		}
+		mergedObstacle, statusCode, err := usecase.MergeObstacles(ctx, input)
+		if err != nil {
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
+		}
+		if mergedObstacle == nil {
+			return errorResponse(logger, request, http.StatusNotFound, "Obstacle not found", nil, nil)
+		}
+
+		return jsonResponseWithHeaders(statusCode, mergedObstacle, etagHeaders(mergedObstacle.Version))
+
+	// GET /obstacles/{id}/comments - List the comments of an obstacle
+	case request.HTTPMethod == "GET" && request.Resource == "/obstacles/{id}/comments":
+		input := input.ObstacleCommentGet{
+			ID: request.PathParameters["id"],
+		}
+
+		comments, statusCode, err := usecase.GetObstacleComments(ctx, input)
+		if err != nil {
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
+		}
+		if comments == nil {
+			return errorResponse(logger, request, http.StatusNotFound, "Obstacle not found", nil, nil)
+		}
+
+		return jsonResponse(statusCode, comments)
+
+	// POST /obstacles/{id}/comments - Post a comment to an obstacle
+	case request.HTTPMethod == "POST" && request.Resource == "/obstacles/{id}/comments":
+		var commentRequest apiinput.ObstacleCommentRequest
+		if err := json.Unmarshal([]byte(request.Body), &commentRequest); err != nil {
+			return errorResponse(logger, request, http.StatusBadRequest, "Invalid request body", nil, err)
+		}
+
+		// 投稿者は書き換えられるX-Actorヘッダーではなく認証済みのIDで識別する
+		audit, ok := authenticatedAudit(request)
+		if !ok {
+			return errorResponse(logger, request, http.StatusUnauthorized, "Authentication is required", nil, nil)
+		}
+		input := input.ObstacleCommentCreate{
+			Audit: audit,
+			ID:    request.PathParameters["id"],
+			Body:  commentRequest.Body,
+		}
+		comment, statusCode, err := usecase.CreateObstacleComment(ctx, input)
+		if err != nil {
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
+		}
+		if comment == nil {
+			return errorResponse(logger, request, http.StatusNotFound, "Obstacle not found", nil, nil)
+		}
+
+		return jsonResponse(statusCode, comment)
+
+	// PUT /obstacles/{id}/comments/{commentId} - Edit a comment
+	case request.HTTPMethod == "PUT" && request.Resource == "/obstacles/{id}/comments/{commentId}":
+		var commentRequest apiinput.ObstacleCommentRequest
+		if err := json.Unmarshal([]byte(request.Body), &commentRequest); err != nil {
+			return errorResponse(logger, request, http.StatusBadRequest, "Invalid request body", nil, err)
+		}
+
+		audit, ok := authenticatedAudit(request)
+		if !ok {
+			return errorResponse(logger, request, http.StatusUnauthorized, "Authentication is required", nil, nil)
+		}
+		input := input.ObstacleCommentUpdate{
+			Audit:     audit,
+			ID:        request.PathParameters["id"],
+			CommentID: request.PathParameters["commentId"],
+			Body:      commentRequest.Body,
+		}
+		comment, statusCode, err := usecase.UpdateObstacleComment(ctx, input)
+		if err != nil {
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
+		}
+		if comment == nil {
+			return errorResponse(logger, request, http.StatusNotFound, "Comment not found", nil, nil)
+		}
+
+		return jsonResponse(statusCode, comment)
+
+	// DELETE /obstacles/{id}/comments/{commentId} - Delete a comment
+	case request.HTTPMethod == "DELETE" && request.Resource == "/obstacles/{id}/comments/{commentId}":
+		audit, ok := authenticatedAudit(request)
+		if !ok {
+			return errorResponse(logger, request, http.StatusUnauthorized, "Authentication is required", nil, nil)
+		}
+		input := input.ObstacleCommentDelete{
+			Audit:     audit,
+			ID:        request.PathParameters["id"],
+			CommentID: request.PathParameters["commentId"],
+		}
+
+		statusCode, err := usecase.DeleteObstacleComment(ctx, input)
+		if err != nil {
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
+		}
+
+		return events.APIGatewayProxyResponse{
+			StatusCode: statusCode,
+			Headers: map[string]string{
+				"Content-Type":                "application/json",
+				"Access-Control-Allow-Origin": "*",
+			},
+		}, nil
+
+	// POST /obstacles/{id}/transitions - Change the status of an obstacle
+	case request.HTTPMethod == "POST" && request.Resource == "/obstacles/{id}/transitions":
+		idStr := request.PathParameters["id"]
+
+		var transitionRequest apiinput.ObstacleTransitionRequest
+		if err := json.Unmarshal([]byte(request.Body), &transitionRequest); err != nil {
+			return errorResponse(logger, request, http.StatusBadRequest, "Invalid request body", nil, err)
+		}
+
+		input := input.ObstacleTransition{
+			Audit:      auditFromRequest(request),
+			ID:         idStr,
+			Status:     transitionRequest.Status,
+			OnBehalfOf: transitionRequest.Actor,
+			Comment:    transitionRequest.Comment,
+		}
+
+		updatedObstacle, statusCode, err := usecase.TransitionObstacle(ctx, input)
+		if err != nil {
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
+		}
+		if updatedObstacle == nil {
+			return errorResponse(logger, request, http.StatusNotFound, "Obstacle not found", nil, nil)
+		}
+
+		return jsonResponseWithHeaders(statusCode, updatedObstacle, etagHeaders(updatedObstacle.Version))
+
+	// POST /obstacles/{id}/restore - Restore an obstacle from the trash
+	case request.HTTPMethod == "POST" && request.Resource == "/obstacles/{id}/restore":
+		idStr := request.PathParameters["id"]
+		expectedVersion, statusCode, err := ifMatchVersion(request)
+		if err != nil {
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
+		}
+
+		input := input.ObstacleRestore{
+			Audit:           auditFromRequest(request),
+			ExpectedVersion: expectedVersion,
+			ID:              idStr,
+		}
+
+		restoredObstacle, statusCode, err := usecase.RestoreObstacle(ctx, input)
+		if err != nil {
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
+		}
+		if restoredObstacle == nil {
+			return errorResponse(logger, request, http.StatusNotFound, "Obstacle not found", nil, nil)
+		}
+
+		return jsonResponseWithHeaders(statusCode, restoredObstacle, etagHeaders(restoredObstacle.Version))
+
+	// GET /obstacles/{id}/history - Get the revision history of an obstacle
+	case request.HTTPMethod == "GET" && request.Resource == "/obstacles/{id}/history":
+		idStr := request.PathParameters["id"]
+		input := input.ObstacleHistory{
+			ID: idStr,
+		}
+
+		history, statusCode, err := usecase.GetObstacleHistory(ctx, input)
+		if err != nil {
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
+		}
+		if history == nil {
+			return errorResponse(logger, request, http.StatusNotFound, "Obstacle not found", nil, nil)
+		}
+
+		return jsonResponse(statusCode, history)
+
+	// POST /obstacles/{id}/revert - Revert an obstacle to a revision
+	case request.HTTPMethod == "POST" && request.Resource == "/obstacles/{id}/revert":
+		idStr := request.PathParameters["id"]
+
+		var revertRequest apiinput.RevertObstacleRequest
+		if err := json.Unmarshal([]byte(request.Body), &revertRequest); err != nil || revertRequest.RevisionID == 0 {
+			return errorResponse(logger, request, http.StatusBadRequest, "Invalid request body or missing revision_id", nil, err)
+		}
+		// 古い内容で上書きする操作のため、設定によらず現在のバージョンの指定を必須にする
+		expectedVersion, statusCode, err := ifMatchVersion(request)
+		if err != nil {
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
+		}
+		if expectedVersion == nil {
+			return errorResponse(logger, request, http.StatusPreconditionRequired, "If-Match header with the current version is required", nil, nil)
+		}
+
+		input := input.ObstacleRevert{
+			Audit:           auditFromRequest(request),
+			ExpectedVersion: expectedVersion,
+			ID:              idStr,
+			RevisionID:      revertRequest.RevisionID,
+		}
+
+		revertedObstacle, statusCode, err := usecase.RevertObstacle(ctx, input)
+		if err != nil {
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
+		}
+		if revertedObstacle == nil {
+			return errorResponse(logger, request, http.StatusNotFound, "Revision not found", nil, nil)
+		}
+
+		return jsonResponseWithHeaders(statusCode, revertedObstacle, etagHeaders(revertedObstacle.Version))
+
 	// POST /obstacles/{id}/image-upload - Generate presigned URL for image upload
 	case request.HTTPMethod == "POST" && request.Resource == "/obstacles/{id}/image-upload":
+		var uploadRequest apiinput.CreateImageUploadRequest
+		if err := json.Unmarshal([]byte(request.Body), &uploadRequest); err != nil || (uploadRequest.ContentType == "" && uploadRequest.Filename == "") {
+			return errorResponse(logger, request, http.StatusBadRequest, "Invalid request body or missing content_type", nil, err)
+		}
+
+		input := input.ObstacleImageUpload{
+			ID:          request.PathParameters["id"],
+			ContentType: uploadRequest.ContentType,
+			Filename:    uploadRequest.Filename,
+		}
+		upload, statusCode, err := usecase.CreateImageUpload(ctx, input)
+		if err != nil {
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
+		}
+		if upload == nil {
+			return errorResponse(logger, request, http.StatusNotFound, "Obstacle not found", nil, nil)
+		}
+		return jsonResponse(statusCode, upload)
+
+	// POST /obstacles/{id}/images - Add an image record and generate a presigned URL for uploading it
+	case request.HTTPMethod == "POST" && request.Resource == "/obstacles/{id}/images":
 		idStr := request.PathParameters["id"]
-		var req struct {
-			Filename string `json:"filename"`
+
+		var imageRequest apiinput.CreateObstacleImageRequest
+		if err := json.Unmarshal([]byte(request.Body), &imageRequest); err != nil || (imageRequest.ContentType == "" && imageRequest.Filename == "") {
+			return errorResponse(logger, request, http.StatusBadRequest, "Invalid request body or missing content_type", nil, err)
+		}
+
+		expectedVersion, statusCode, err := ifMatchVersion(request)
+		if err != nil {
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
+		}
+
+		input := input.ObstacleImageCreate{
+			Audit:           auditFromRequest(request),
+			ExpectedVersion: expectedVersion,
+			ID:              idStr,
+			ContentType:     imageRequest.ContentType,
+			Filename:        imageRequest.Filename,
+			Caption:         imageRequest.Caption,
+			TakenAt:         imageRequest.TakenAt,
+		}
+
+		upload, statusCode, err := usecase.CreateObstacleImage(ctx, input)
+		if err != nil {
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
+		}
+		if upload == nil {
+			return errorResponse(logger, request, http.StatusNotFound, "Obstacle not found", nil, nil)
+		}
+
+		return jsonResponse(statusCode, upload)
+
+	// GET /obstacles/{id}/images - List the images of an obstacle
+	case request.HTTPMethod == "GET" && request.Resource == "/obstacles/{id}/images":
+		input := input.ObstacleImageGet{
+			ID: request.PathParameters["id"],
+		}
+
+		images, statusCode, err := usecase.GetObstacleImages(ctx, input)
+		if err != nil {
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
+		}
+		if images == nil {
+			return errorResponse(logger, request, http.StatusNotFound, "Obstacle not found", nil, nil)
+		}
+
+		return jsonResponse(statusCode, images)
+
+	// GET /obstacles/{id}/images/{imageId} - Get an image of an obstacle
+	case request.HTTPMethod == "GET" && request.Resource == "/obstacles/{id}/images/{imageId}":
+		input := input.ObstacleImageGet{
+			ID:      request.PathParameters["id"],
+			ImageID: request.PathParameters["imageId"],
+		}
+
+		image, statusCode, err := usecase.GetObstacleImage(ctx, input)
+		if err != nil {
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
+		}
+		if image == nil {
+			return errorResponse(logger, request, http.StatusNotFound, "Image not found", nil, nil)
 		}
-		if err := json.Unmarshal([]byte(request.Body), &req); err != nil || req.Filename == "" {
-			return errorResponse(logger, request, http.StatusBadRequest, "Invalid request body or missing filename", nil, err)
+
+		return jsonResponse(statusCode, image)
+
+	// DELETE /obstacles/{id}/images/{imageId} - Remove an image from an obstacle
+	case request.HTTPMethod == "DELETE" && request.Resource == "/obstacles/{id}/images/{imageId}":
+		expectedVersion, statusCode, err := ifMatchVersion(request)
+		if err != nil {
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
+		}
+
+		input := input.ObstacleImageDelete{
+			Audit:           auditFromRequest(request),
+			ExpectedVersion: expectedVersion,
+			ID:              request.PathParameters["id"],
+			ImageID:         request.PathParameters["imageId"],
+		}
+
+		statusCode, err = usecase.DeleteObstacleImage(ctx, input)
+		if err != nil {
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
 		}
 
-		// s3Keyを obstacles/{id}_{filename} 形式で生成
-		s3Key := fmt.Sprintf("obstacles/%s_%s", idStr, req.Filename)
+		return events.APIGatewayProxyResponse{
+			StatusCode: statusCode,
+			Headers: map[string]string{
+				"Content-Type":                "application/json",
+				"Access-Control-Allow-Origin": "*",
+			},
+		}, nil
 
-		s3Repo, err := s3.NewS3Repo()
+	// PUT /obstacles/{id}/images/order - Change the display order of the images
+	case request.HTTPMethod == "PUT" && request.Resource == "/obstacles/{id}/images/order":
+		var reorderRequest apiinput.ReorderObstacleImagesRequest
+		if err := json.Unmarshal([]byte(request.Body), &reorderRequest); err != nil {
+			return errorResponse(logger, request, http.StatusBadRequest, "Invalid request body", nil, err)
+		}
+
+		expectedVersion, statusCode, err := ifMatchVersion(request)
 		if err != nil {
-			return errorResponse(logger, request, http.StatusInternalServerError, "Failed to create S3 repository", nil, err)
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
 		}
 
-		url, err := s3Repo.GeneratePresignedPUTURL(s3Key)
+		input := input.ObstacleImageReorder{
+			Audit:           auditFromRequest(request),
+			ExpectedVersion: expectedVersion,
+			ID:              request.PathParameters["id"],
+			ImageIDs:        reorderRequest.ImageIDs,
+		}
+
+		reorderedObstacle, statusCode, err := usecase.ReorderObstacleImages(ctx, input)
 		if err != nil {
-			return errorResponse(logger, request, http.StatusInternalServerError, "Failed to generate presigned URL", nil, err)
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
 		}
-		return jsonResponse(http.StatusOK, map[string]string{"url": url, "image_s3_key": s3Key})
+		if reorderedObstacle == nil {
+			return errorResponse(logger, request, http.StatusNotFound, "Obstacle not found", nil, nil)
+		}
+
+		return jsonResponseWithHeaders(statusCode, reorderedObstacle, etagHeaders(reorderedObstacle.Version))
 
-	// PUT /obstacles/{id}/image - Save image_s3_key to obstacle
+	// PUT /obstacles/{id}/image - Attach an uploaded image to obstacle
 	case request.HTTPMethod == "PUT" && request.Resource == "/obstacles/{id}/image":
 		idStr := request.PathParameters["id"]
 		var req struct {
@@ -175,15 +731,25 @@ func HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (
 			return errorResponse(logger, request, http.StatusBadRequest, "Invalid request body or missing image_s3_key", nil, err)
 		}
 
+		expectedVersion, statusCode, err := ifMatchVersion(request)
+		if err != nil {
+			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
+		}
+
 		input := input.ObstacleUpdateImageS3Key{
-			ID:         idStr,
-			ImageS3Key: req.ImageS3Key,
+			Audit:           auditFromRequest(request),
+			ExpectedVersion: expectedVersion,
+			ID:              idStr,
+			ImageS3Key:      req.ImageS3Key,
 		}
 		updatedObstacle, statusCode, err := usecase.UpdateObstacleImageS3Key(ctx, input)
 		if err != nil {
 			return errorResponse(logger, request, statusCode, err.Error(), nil, err)
 		}
-		return jsonResponse(statusCode, updatedObstacle)
+		if updatedObstacle == nil {
+			return errorResponse(logger, request, http.StatusNotFound, "Obstacle not found", nil, nil)
+		}
+		return jsonResponseWithHeaders(statusCode, updatedObstacle, etagHeaders(updatedObstacle.Version))
 
 	// POST /route-with-obstacles - Get route with obstacles
 	case request.HTTPMethod == "POST" && request.Resource == "/route-with-obstacles":
@@ -206,7 +772,7 @@ func HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (
 		if routeRequest.DetectionMethod != "" {
 			detectionMethod = input.ObstacleDetectionMethod(routeRequest.DetectionMethod)
 		}
-		
+
 		distanceThreshold := 0.02 // デフォルト20m
 		if routeRequest.DistanceThreshold > 0 {
 			distanceThreshold = routeRequest.DistanceThreshold
@@ -218,6 +784,8 @@ func HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (
 			Costing:           routeRequest.Costing,
 			DetectionMethod:   detectionMethod,
 			DistanceThreshold: distanceThreshold,
+			Statuses:          routeRequest.Statuses,
+			MinConfidence:     routeRequest.MinConfidence,
 		}
 
 		routeResponse, statusCode, err := usecase.GetRouteWithObstacles(ctx, usecaseInput)
@@ -232,7 +800,175 @@ func HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (
 	}
 }
 
+// auditFromRequest はリクエストから変更者（X-Actorヘッダー）と変更元のエンドポイントを取得する
+func auditFromRequest(request events.APIGatewayProxyRequest) input.Audit {
+	return input.Audit{
+		Actor:  headerValue(request, "X-Actor"),
+		Source: request.HTTPMethod + " " + request.Resource,
+	}
+}
+
+// authenticatedAudit はAPI GatewayのCognitoオーソライザーが検証したIDトークンの利用者（sub）を変更者とした監査情報を返す
+// 利用者ごとに制限する操作で使い、認証されていない場合はfalseを返す
+func authenticatedAudit(request events.APIGatewayProxyRequest) (input.Audit, bool) {
+	audit := auditFromRequest(request)
+	claims, _ := request.RequestContext.Authorizer["claims"].(map[string]interface{})
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return audit, false
+	}
+	audit.Actor = sub
+	return audit, true
+}
+
+// headerValue はヘッダー名の大文字小文字を区別せずに値を取得する
+func headerValue(request events.APIGatewayProxyRequest, name string) string {
+	for key, value := range request.Headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// obstacleFilterFromRequest は一覧・検索・エクスポートに共通の絞り込み条件と並び順をクエリから取得する
+// 値の検証はユースケースで行う
+func obstacleFilterFromRequest(request events.APIGatewayProxyRequest) input.ObstacleGetAll {
+	query := request.QueryStringParameters
+	return input.ObstacleGetAll{
+		Deleted:       query["deleted"] == "true",
+		Types:         queryValues(request, "type"),
+		MinDanger:     query["min_danger"],
+		MaxDanger:     query["max_danger"],
+		Statuses:      queryValues(request, "status"),
+		CreatedAfter:  query["created_after"],
+		CreatedBefore: query["created_before"],
+		HasImage:      query["has_image"],
+		NoNearbyRoad:  query["no_nearby_road"],
+		BBox:          query["bbox"],
+		Near:          query["near"],
+		Radius:        query["radius"],
+		Sort:          query["sort"],
+	}
+}
+
+// queryValues は複数指定できるクエリパラメーターの値を返す
+// 「type=1&type=2」と「type=1,2」のどちらの指定にも対応する
+func queryValues(request events.APIGatewayProxyRequest, name string) []string {
+	values := request.MultiValueQueryStringParameters[name]
+	if len(values) == 0 {
+		if value, ok := request.QueryStringParameters[name]; ok {
+			values = []string{value}
+		}
+	}
+	var result []string
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				result = append(result, part)
+			}
+		}
+	}
+	return result
+}
+
+// importFormat は一括登録するファイルの形式をformatパラメーターまたはContent-Typeから決める
+// どちらからも決まらない場合は空文字を返し、内容から判別させる
+func importFormat(request events.APIGatewayProxyRequest) string {
+	if format := request.QueryStringParameters["format"]; format != "" {
+		return format
+	}
+	contentType := strings.ToLower(headerValue(request, "Content-Type"))
+	switch {
+	case strings.HasPrefix(contentType, "text/csv"):
+		return "csv"
+	case strings.HasPrefix(contentType, "application/geo+json"), strings.HasPrefix(contentType, "application/json"):
+		return "geojson"
+	}
+	return ""
+}
+
+// mergePatchInput はJSON Merge Patchのリクエストを部分更新の入力に変換する
+// nullは属性の削除を表すため、必須の属性にnullが指定された場合はエラーにする
+func mergePatchInput(patchRequest apiinput.PatchObstacleRequest, obstacleInput *input.ObstaclePatch) error {
+	var err error
+	if obstacleInput.Position, err = decodePatchField[[2]float64](patchRequest.Position, "position", false); err != nil {
+		return err
+	}
+	if obstacleInput.Type, err = decodePatchField[int](patchRequest.Type, "type", false); err != nil {
+		return err
+	}
+	if obstacleInput.Description, err = decodePatchField[string](patchRequest.Description, "description", true); err != nil {
+		return err
+	}
+	if obstacleInput.DangerLevel, err = decodePatchField[int](patchRequest.DangerLevel, "dangerLevel", false); err != nil {
+		return err
+	}
+	if obstacleInput.Nodes, err = decodePatchField[[]int64](patchRequest.Nodes, "nodes", true); err != nil {
+		return err
+	}
+	if obstacleInput.NearestDistance, err = decodePatchField[float64](patchRequest.NearestDistance, "nearestDistance", true); err != nil {
+		return err
+	}
+	if obstacleInput.NoNearbyRoad, err = decodePatchField[bool](patchRequest.NoNearbyRoad, "noNearbyRoad", true); err != nil {
+		return err
+	}
+	return nil
+}
+
+// decodePatchField はMerge Patchの1フィールドをデコードする
+// 省略された場合はnil、nullの場合はゼロ値を返す
+func decodePatchField[T any](raw json.RawMessage, name string, nullable bool) (*T, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	var value T
+	if string(raw) == "null" {
+		if !nullable {
+			return nil, fmt.Errorf("%s cannot be null", name)
+		}
+		return &value, nil
+	}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return &value, nil
+}
+
+// ifMatchVersion はIf-Matchヘッダーから更新対象として期待するバージョンを取得する
+// ヘッダーがない場合と「*」の場合はnilを返す
+func ifMatchVersion(request events.APIGatewayProxyRequest) (*int, int, error) {
+	value := strings.TrimSpace(headerValue(request, "If-Match"))
+	if value == "" {
+		if util.GetSetting().API.RequireIfMatch {
+			return nil, http.StatusPreconditionRequired, fmt.Errorf("If-Match header is required")
+		}
+		return nil, http.StatusOK, nil
+	}
+	if value == "*" {
+		return nil, http.StatusOK, nil
+	}
+
+	version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(value, "W/"), `"`))
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid If-Match header: %s", value)
+	}
+	return &version, http.StatusOK, nil
+}
+
+// etagHeaders は障害物のバージョンをETagとして返すヘッダー
+func etagHeaders(version int) map[string]string {
+	return map[string]string{
+		"ETag":                          fmt.Sprintf(`"%d"`, version),
+		"Access-Control-Expose-Headers": "ETag",
+	}
+}
+
 func jsonResponse(statusCode int, data interface{}) (events.APIGatewayProxyResponse, error) {
+	return jsonResponseWithHeaders(statusCode, data, nil)
+}
+
+func jsonResponseWithHeaders(statusCode int, data interface{}, headers map[string]string) (events.APIGatewayProxyResponse, error) {
 	body, err := json.Marshal(data)
 	if err != nil {
 		return events.APIGatewayProxyResponse{
@@ -245,13 +981,18 @@ func jsonResponse(statusCode int, data interface{}) (events.APIGatewayProxyRespo
 		}, err
 	}
 
+	responseHeaders := map[string]string{
+		"Content-Type":                "application/json",
+		"Access-Control-Allow-Origin": "*",
+	}
+	for key, value := range headers {
+		responseHeaders[key] = value
+	}
+
 	return events.APIGatewayProxyResponse{
 		StatusCode: statusCode,
-		Headers: map[string]string{
-			"Content-Type":                "application/json",
-			"Access-Control-Allow-Origin": "*",
-		},
-		Body: string(body),
+		Headers:    responseHeaders,
+		Body:       string(body),
 	}, nil
 }
 
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestAuthenticatedAudit(t *testing.T) {
+	tests := []struct {
+		name       string
+		authorizer map[string]interface{}
+		wantActor  string
+		wantOK     bool
+	}{
+		{
+			name:       "uses the sub claim verified by the authorizer",
+			authorizer: map[string]interface{}{"claims": map[string]interface{}{"sub": "5f1c6b2e", "email": "user@example.com"}},
+			wantActor:  "5f1c6b2e",
+			wantOK:     true,
+		},
+		{
+			name:       "rejects a request without an authorizer",
+			authorizer: nil,
+		},
+		{
+			name:       "rejects claims without a sub",
+			authorizer: map[string]interface{}{"claims": map[string]interface{}{"email": "user@example.com"}},
+		},
+		{
+			name:       "rejects a sub outside of the claims",
+			authorizer: map[string]interface{}{"sub": "5f1c6b2e"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := events.APIGatewayProxyRequest{
+				HTTPMethod: "POST",
+				Resource:   "/obstacles/{id}/confirmations",
+				// X-Actorは誰でも送れるため、認証済みのIDの代わりには使わない
+				Headers:        map[string]string{"X-Actor": "someone-else"},
+				RequestContext: events.APIGatewayProxyRequestContext{Authorizer: tt.authorizer},
+			}
+			audit, ok := authenticatedAudit(request)
+			if ok != tt.wantOK {
+				t.Fatalf("authenticatedAudit() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && audit.Actor != tt.wantActor {
+				t.Errorf("Actor = %q, want %q", audit.Actor, tt.wantActor)
+			}
+			if audit.Source != "POST /obstacles/{id}/confirmations" {
+				t.Errorf("Source = %q", audit.Source)
+			}
+		})
+	}
+}
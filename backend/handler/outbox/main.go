@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+
+	"webhook/shared/util"
+	"webhook/usecase"
+	"webhook/usecase/input"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"go.uber.org/zap"
+)
+
+// HandleRequest はスケジュール実行でoutboxに記録された後処理（S3の削除など）を実行・再試行する
+func HandleRequest(ctx context.Context, event events.CloudWatchEvent) error {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	input := input.OutboxProcess{
+		MaxAttempts: util.GetSetting().Outbox.MaxAttempts,
+	}
+	response, _, err := usecase.ProcessOutbox(ctx, input)
+	if err != nil {
+		logger.Error("failed to process outbox", zap.Error(err))
+		return err
+	}
+
+	// デッドレターは自動では再試行しないため、エラーとして記録して通知の対象にする
+	for _, entry := range response.DeadLetters {
+		logger.Error("outbox entry moved to dead letters",
+			zap.String("id", entry.ID),
+			zap.String("kind", entry.Kind),
+			zap.Int("obstacle_id", entry.ObstacleID),
+			zap.Strings("s3_keys", entry.S3Keys),
+			zap.Int("attempts", entry.Attempts),
+			zap.String("last_error", entry.LastError),
+		)
+	}
+	logger.Info("processed outbox",
+		zap.Strings("completed_ids", response.CompletedIDs),
+		zap.Strings("retried_ids", response.RetriedIDs),
+		zap.Int("dead_letter_count", response.DeadLetterCount),
+	)
+	return nil
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
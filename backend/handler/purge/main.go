@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+
+	"webhook/shared/util"
+	"webhook/usecase"
+	"webhook/usecase/input"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"go.uber.org/zap"
+)
+
+// HandleRequest はスケジュール実行で保持期間を過ぎたゴミ箱の障害物を完全削除する
+func HandleRequest(ctx context.Context, event events.CloudWatchEvent) error {
+	logger, _ := zap.NewProduction()
+	defer logger.Sync()
+
+	input := input.ObstaclePurge{
+		Retention: util.GetSetting().Trash.Retention,
+	}
+	response, _, err := usecase.PurgeDeletedObstacles(ctx, input)
+	if err != nil {
+		logger.Error("failed to purge deleted obstacles", zap.Error(err))
+		return err
+	}
+
+	logger.Info("purged deleted obstacles",
+		zap.Ints("purged_ids", response.PurgedIDs),
+		zap.Ints("failed_ids", response.FailedIDs),
+	)
+	return nil
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
@@ -1,21 +1,74 @@
 package apiinput
 
+import "encoding/json"
+
 type CreateObstacleRequest struct {
-	Position    [2]float64 `json:"position" validate:"required"`
-	Type        int        `json:"type" validate:"required"`
-	Description string     `json:"description"`
-	DangerLevel int        `json:"dangerLevel" validate:"required"`
-	Nodes       []int64    `json:"nodes" validate:"required"`
-	NearestDistance float64 `json:"nearestDistance" validate:"required"`
-	NoNearbyRoad  bool       `json:"noNearbyRoad"`
+	Position        [2]float64 `json:"position" validate:"required"`
+	Type            int        `json:"type" validate:"required"`
+	Description     string     `json:"description"`
+	DangerLevel     int        `json:"dangerLevel" validate:"required"`
+	Nodes           []int64    `json:"nodes" validate:"required"`
+	NearestDistance float64    `json:"nearestDistance" validate:"required"`
+	NoNearbyRoad    bool       `json:"noNearbyRoad"`
+	OnDuplicate     string     `json:"onDuplicate"` // reject・confirm・force（既定）
+}
+
+type ObstacleTransitionRequest struct {
+	Status  string `json:"status" validate:"required"`
+	Actor   string `json:"actor"` // 作業者（遷移の記録に残すのみで、監査上の変更者はX-Actorヘッダー）
+	Comment string `json:"comment"`
+}
+
+// PatchObstacleRequest はJSON Merge Patch（RFC 7396）のリクエスト
+// 省略されたフィールドはnil、nullが指定されたフィールドは"null"になる
+type PatchObstacleRequest struct {
+	Position        json.RawMessage `json:"position"`
+	Type            json.RawMessage `json:"type"`
+	Description     json.RawMessage `json:"description"`
+	DangerLevel     json.RawMessage `json:"dangerLevel"`
+	Nodes           json.RawMessage `json:"nodes"`
+	NearestDistance json.RawMessage `json:"nearestDistance"`
+	NoNearbyRoad    json.RawMessage `json:"noNearbyRoad"`
+}
+
+type CreateObstacleImageRequest struct {
+	ContentType string `json:"content_type"`
+	Filename    string `json:"filename"` // content_typeの指定がない場合に拡張子から判定する
+	Caption     string `json:"caption"`
+	TakenAt     string `json:"taken_at"`
+}
+
+type CreateImageUploadRequest struct {
+	ContentType string `json:"content_type"`
+	Filename    string `json:"filename"` // content_typeの指定がない場合に拡張子から判定する
+}
+
+type ReorderObstacleImagesRequest struct {
+	ImageIDs []string `json:"image_ids" validate:"required"`
+}
+
+type RevertObstacleRequest struct {
+	RevisionID int64 `json:"revision_id" validate:"required"`
 }
 
 type UpdateObstacleRequest struct {
-	Position    [2]float64 `json:"position" validate:"required"`
-	Type        int        `json:"type" validate:"required"`
-	Description string     `json:"description"`
-	DangerLevel int        `json:"dangerLevel" validate:"required"`
-	Nodes       []int64    `json:"nodes" validate:"required"`
-	NearestDistance float64 `json:"nearestDistance" validate:"required"`
-	NoNearbyRoad  bool       `json:"noNearbyRoad"`
+	Position        [2]float64 `json:"position" validate:"required"`
+	Type            int        `json:"type" validate:"required"`
+	Description     string     `json:"description"`
+	DangerLevel     int        `json:"dangerLevel" validate:"required"`
+	Nodes           []int64    `json:"nodes" validate:"required"`
+	NearestDistance float64    `json:"nearestDistance" validate:"required"`
+	NoNearbyRoad    bool       `json:"noNearbyRoad"`
+}
+
+type ObstacleConfirmationRequest struct {
+	Vote string `json:"vote" validate:"required"` // still_there または gone
+}
+
+type ObstacleCommentRequest struct {
+	Body string `json:"body" validate:"required"`
+}
+
+type MergeObstaclesRequest struct {
+	SourceIDs []int `json:"source_ids" validate:"required"`
 }
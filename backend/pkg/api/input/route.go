@@ -6,14 +6,16 @@ type RouteLocation struct {
 }
 
 type RouteWithObstaclesRequest struct {
-	Locations           []RouteLocation `json:"locations"`
-	Language            string          `json:"language,omitempty"`
-	Costing             string          `json:"costing,omitempty"`
-	DetectionMethod     string          `json:"detection_method,omitempty"`     // 障害物検出方法
-	DistanceThreshold   float64         `json:"distance_threshold,omitempty"`   // 距離閾値（km）
+	Locations         []RouteLocation `json:"locations"`
+	Language          string          `json:"language,omitempty"`
+	Costing           string          `json:"costing,omitempty"`
+	DetectionMethod   string          `json:"detection_method,omitempty"`   // 障害物検出方法
+	DistanceThreshold float64         `json:"distance_threshold,omitempty"` // 距離閾値（km）
+	Statuses          []string        `json:"statuses,omitempty"`           // 検出対象の状態
+	MinConfidence     float64         `json:"min_confidence,omitempty"`     // 検出対象とする信頼度の下限
 }
 
 type LocationRequest struct {
 	Lat float64 `json:"lat"`
 	Lon float64 `json:"lon"`
-} 
\ No newline at end of file
+}
@@ -0,0 +1,79 @@
+// Package geohash は地点をgeohash（経度・緯度のビットを交互に並べたbase32の文字列）に変換する
+// 同じ接頭辞を持つgeohashは同じ矩形の中にあるため、DynamoDBのキーにして範囲内の障害物を探すのに使う
+package geohash
+
+import "math"
+
+// base32 はgeohashで使う文字（a・i・l・oを除く）
+const base32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// MaxPrecision は扱う最大の文字数（約5m四方）
+const MaxPrecision = 9
+
+// Encode は地点（緯度・経度）を指定した文字数のgeohashに変換する
+func Encode(lat, lon float64, precision int) string {
+	latIndex, lonIndex := cellIndexes(lat, lon, precision)
+	return encodeIndexes(latIndex, lonIndex, precision)
+}
+
+// Cover は範囲（南端・西端・北端・東端）と重なる、指定した文字数のgeohashをすべて返す
+// 範囲が日付変更線をまたぐ場合は扱わない（西端は東端以下とする）
+func Cover(minLat, minLon, maxLat, maxLon float64, precision int) []string {
+	minLatIndex, minLonIndex := cellIndexes(minLat, minLon, precision)
+	maxLatIndex, maxLonIndex := cellIndexes(maxLat, maxLon, precision)
+	cells := make([]string, 0, (maxLatIndex-minLatIndex+1)*(maxLonIndex-minLonIndex+1))
+	for latIndex := minLatIndex; latIndex <= maxLatIndex; latIndex++ {
+		for lonIndex := minLonIndex; lonIndex <= maxLonIndex; lonIndex++ {
+			cells = append(cells, encodeIndexes(latIndex, lonIndex, precision))
+		}
+	}
+	return cells
+}
+
+// CoverCount はCoverが返すgeohashの数を、文字列を作らずに返す
+func CoverCount(minLat, minLon, maxLat, maxLon float64, precision int) int {
+	minLatIndex, minLonIndex := cellIndexes(minLat, minLon, precision)
+	maxLatIndex, maxLonIndex := cellIndexes(maxLat, maxLon, precision)
+	return (maxLatIndex - minLatIndex + 1) * (maxLonIndex - minLonIndex + 1)
+}
+
+// bits は文字数precisionのgeohashが緯度・経度それぞれに使うビット数を返す（経度のビットが先に並ぶ）
+func bits(precision int) (int, int) {
+	total := precision * 5
+	return total / 2, (total + 1) / 2
+}
+
+// cellIndexes は地点を含むセルの、南端・西端から数えた位置を返す（範囲外の地点は端のセルに含める）
+func cellIndexes(lat, lon float64, precision int) (int, int) {
+	latBits, lonBits := bits(precision)
+	return gridIndex(lat, -90, 180, latBits), gridIndex(lon, -180, 360, lonBits)
+}
+
+func gridIndex(value, origin, span float64, bits int) int {
+	cells := 1 << bits
+	index := int(math.Floor((value - origin) / span * float64(cells)))
+	return max(0, min(index, cells-1))
+}
+
+// encodeIndexes はセルの位置の経度・緯度のビットを上位から交互に並べ、5ビットずつ文字にする
+func encodeIndexes(latIndex, lonIndex, precision int) string {
+	latBits, lonBits := bits(precision)
+	hash := make([]byte, precision)
+	for i := range hash {
+		var c int
+		for j := 0; j < 5; j++ {
+			k := i*5 + j
+			var bit int
+			if k%2 == 0 {
+				lonBits--
+				bit = lonIndex >> lonBits & 1
+			} else {
+				latBits--
+				bit = latIndex >> latBits & 1
+			}
+			c = c<<1 | bit
+		}
+		hash[i] = base32[c]
+	}
+	return string(hash)
+}
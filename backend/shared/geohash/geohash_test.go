@@ -0,0 +1,49 @@
+package geohash
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		lat, lon  float64
+		precision int
+		want      string
+	}{
+		{57.64911, 10.40744, 11, "u4pruydqqvj"},
+		{35.681236, 139.767125, 9, "xn76urx66"},
+		{-90, -180, 3, "000"},
+		{90, 180, 3, "zzz"},
+	}
+	for _, tt := range tests {
+		if got := Encode(tt.lat, tt.lon, tt.precision); got != tt.want {
+			t.Errorf("Encode(%v, %v, %d) = %q, want %q", tt.lat, tt.lon, tt.precision, got, tt.want)
+		}
+	}
+}
+
+func TestCover(t *testing.T) {
+	// 東京駅の周辺（約1km四方）は文字数5のセル（約4.9km×4.9km）の1〜4個に収まる
+	minLat, minLon, maxLat, maxLon := 35.676, 139.761, 35.686, 139.773
+	cells := Cover(minLat, minLon, maxLat, maxLon, 5)
+	if len(cells) == 0 || len(cells) > 4 {
+		t.Fatalf("Cover returned %d cells: %v", len(cells), cells)
+	}
+	if got := CoverCount(minLat, minLon, maxLat, maxLon, 5); got != len(cells) {
+		t.Errorf("CoverCount = %d, want %d", got, len(cells))
+	}
+	// 範囲内の地点のgeohashは、いずれかのセルを接頭辞に持つ
+	for _, point := range [][2]float64{{minLat, minLon}, {maxLat, maxLon}, {35.681236, 139.767125}} {
+		hash := Encode(point[0], point[1], MaxPrecision)
+		if !slices.ContainsFunc(cells, func(cell string) bool { return strings.HasPrefix(hash, cell) }) {
+			t.Errorf("%v (%s) is not covered by %v", point, hash, cells)
+		}
+	}
+	// セルは重複しない
+	slices.Sort(cells)
+	if len(slices.Compact(cells)) != CoverCount(minLat, minLon, maxLat, maxLon, 5) {
+		t.Errorf("Cover returned duplicate cells: %v", cells)
+	}
+}
@@ -0,0 +1,246 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"time"
+)
+
+// Metadata は画像のEXIFから取り出した情報
+type Metadata struct {
+	HasGPS      bool
+	Latitude    float64
+	Longitude   float64
+	CapturedAt  time.Time // 撮影日時（不明な場合はゼロ値）
+	Orientation int       // EXIFのOrientation（1〜8、不明な場合は1）
+}
+
+// EXIF（TIFF形式）のタグ
+const (
+	tagOrientation        = 0x0112
+	tagExifIFD            = 0x8769
+	tagGPSIFD             = 0x8825
+	tagDateTimeOriginal   = 0x9003
+	tagOffsetTimeOriginal = 0x9011
+	tagGPSLatitudeRef     = 0x0001
+	tagGPSLatitude        = 0x0002
+	tagGPSLongitudeRef    = 0x0003
+	tagGPSLongitude       = 0x0004
+)
+
+// TIFFの値の型ごとのバイト数
+var tiffTypeSizes = map[uint16]int{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 7: 1, 9: 4, 10: 8,
+}
+
+var exifHeader = []byte("Exif\x00\x00")
+
+// ReadMetadata は画像（JPEG・PNG・WebP）のEXIFを読み取る
+// EXIFがない、または壊れている場合は読み取れた範囲の情報を返す
+func ReadMetadata(data []byte) *Metadata {
+	meta := &Metadata{Orientation: 1}
+	tiff := findExif(data)
+	if tiff == nil {
+		return meta
+	}
+	parseTIFF(tiff, meta)
+	return meta
+}
+
+// findExif は画像からEXIF（TIFF形式）のデータ部分を取り出す
+func findExif(data []byte) []byte {
+	var payload []byte
+	switch detectFormat(data) {
+	case formatJPEG:
+		segments, _ := jpegSegments(data)
+		for _, seg := range segments {
+			if seg.marker == 0xE1 && bytes.HasPrefix(seg.payload, exifHeader) {
+				payload = seg.payload
+				break
+			}
+		}
+	case formatPNG:
+		for _, c := range pngChunks(data) {
+			if c.name == "eXIf" {
+				payload = c.payload
+				break
+			}
+		}
+	case formatWebP:
+		for _, c := range webpChunks(data) {
+			if c.name == "EXIF" {
+				payload = c.payload
+				break
+			}
+		}
+	}
+	return bytes.TrimPrefix(payload, exifHeader)
+}
+
+// tiffReader はバイトオーダーを考慮してTIFFのデータを読み取る
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+func (r *tiffReader) uint16(offset int) (uint16, bool) {
+	if offset < 0 || offset+2 > len(r.data) {
+		return 0, false
+	}
+	return r.order.Uint16(r.data[offset:]), true
+}
+
+func (r *tiffReader) uint32(offset int) (uint32, bool) {
+	if offset < 0 || offset+4 > len(r.data) {
+		return 0, false
+	}
+	return r.order.Uint32(r.data[offset:]), true
+}
+
+// tiffEntry はIFDのエントリ
+type tiffEntry struct {
+	typ   uint16
+	count int
+	value []byte
+}
+
+// readIFD はoffsetにあるIFDのエントリをタグごとに返す
+func (r *tiffReader) readIFD(offset int) map[uint16]tiffEntry {
+	entries := map[uint16]tiffEntry{}
+	n, ok := r.uint16(offset)
+	if !ok {
+		return entries
+	}
+	for i := 0; i < int(n); i++ {
+		pos := offset + 2 + i*12
+		tag, ok1 := r.uint16(pos)
+		typ, ok2 := r.uint16(pos + 2)
+		count, ok3 := r.uint32(pos + 4)
+		if !ok1 || !ok2 || !ok3 {
+			break
+		}
+		size, known := tiffTypeSizes[typ]
+		if !known || count > uint32(len(r.data)) {
+			continue
+		}
+		length := size * int(count)
+		valuePos := pos + 8
+		// 4バイトに収まらない値はオフセットの位置に格納されている
+		if length > 4 {
+			valueOffset, _ := r.uint32(pos + 8)
+			valuePos = int(valueOffset)
+		}
+		if valuePos < 0 || valuePos+length > len(r.data) {
+			continue
+		}
+		entries[tag] = tiffEntry{typ: typ, count: int(count), value: r.data[valuePos : valuePos+length]}
+	}
+	return entries
+}
+
+func (r *tiffReader) short(e tiffEntry) (int, bool) {
+	if e.typ != 3 || e.count < 1 {
+		return 0, false
+	}
+	return int(r.order.Uint16(e.value)), true
+}
+
+func (r *tiffReader) long(e tiffEntry) (int, bool) {
+	if e.typ != 4 || e.count < 1 {
+		return 0, false
+	}
+	return int(r.order.Uint32(e.value)), true
+}
+
+func (r *tiffReader) ascii(e tiffEntry) string {
+	if e.typ != 2 {
+		return ""
+	}
+	return strings.TrimRight(string(e.value), "\x00 ")
+}
+
+// degrees は度・分・秒の3つの有理数を度に変換する
+func (r *tiffReader) degrees(e tiffEntry) (float64, bool) {
+	if e.typ != 5 || e.count < 3 {
+		return 0, false
+	}
+	var result float64
+	for i, scale := range []float64{1, 60, 3600} {
+		num := r.order.Uint32(e.value[i*8:])
+		den := r.order.Uint32(e.value[i*8+4:])
+		if den == 0 {
+			return 0, false
+		}
+		result += float64(num) / float64(den) / scale
+	}
+	return result, true
+}
+
+// parseTIFF はTIFF形式のEXIFから撮影日時・位置・向きを読み取る
+func parseTIFF(data []byte, meta *Metadata) {
+	if len(data) < 8 {
+		return
+	}
+	r := &tiffReader{data: data}
+	switch string(data[:2]) {
+	case "II":
+		r.order = binary.LittleEndian
+	case "MM":
+		r.order = binary.BigEndian
+	default:
+		return
+	}
+	ifd0Offset, _ := r.uint32(4)
+	ifd0 := r.readIFD(int(ifd0Offset))
+
+	if e, ok := ifd0[tagOrientation]; ok {
+		if orientation, ok := r.short(e); ok && orientation >= 1 && orientation <= 8 {
+			meta.Orientation = orientation
+		}
+	}
+
+	if e, ok := ifd0[tagExifIFD]; ok {
+		if offset, ok := r.long(e); ok {
+			exifIFD := r.readIFD(offset)
+			meta.CapturedAt = parseExifTime(r.ascii(exifIFD[tagDateTimeOriginal]), r.ascii(exifIFD[tagOffsetTimeOriginal]))
+		}
+	}
+
+	if e, ok := ifd0[tagGPSIFD]; ok {
+		if offset, ok := r.long(e); ok {
+			gps := r.readIFD(offset)
+			lat, okLat := r.degrees(gps[tagGPSLatitude])
+			lon, okLon := r.degrees(gps[tagGPSLongitude])
+			if okLat && okLon {
+				if r.ascii(gps[tagGPSLatitudeRef]) == "S" {
+					lat = -lat
+				}
+				if r.ascii(gps[tagGPSLongitudeRef]) == "W" {
+					lon = -lon
+				}
+				meta.HasGPS = true
+				meta.Latitude = lat
+				meta.Longitude = lon
+			}
+		}
+	}
+}
+
+// parseExifTime はEXIFの日時（"2006:01:02 15:04:05"）を解釈する
+// タイムゾーンの記録がない場合はUTCとして扱う
+func parseExifTime(value, offset string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	if offset != "" {
+		if t, err := time.Parse("2006:01:02 15:04:05-07:00", value+offset); err == nil {
+			return t
+		}
+	}
+	t, err := time.Parse("2006:01:02 15:04:05", value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,264 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+// tiffField はテスト用のTIFFに書き込むIFDのエントリ
+type tiffField struct {
+	tag   uint16
+	typ   uint16
+	count int
+	value []byte // 型に従ってエンコード済みの値
+}
+
+// tiffOrder はTIFFのバイト順（binary.LittleEndianかbinary.BigEndian）
+type tiffOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// tiffBuilder はテスト用のEXIF（TIFF形式）を組み立てる
+type tiffBuilder struct {
+	order tiffOrder
+}
+
+func (b tiffBuilder) short(tag uint16, value uint16) tiffField {
+	return tiffField{tag: tag, typ: 3, count: 1, value: b.order.AppendUint16(nil, value)}
+}
+
+func (b tiffBuilder) long(tag uint16, value uint32) tiffField {
+	return tiffField{tag: tag, typ: 4, count: 1, value: b.order.AppendUint32(nil, value)}
+}
+
+func (b tiffBuilder) ascii(tag uint16, value string) tiffField {
+	return tiffField{tag: tag, typ: 2, count: len(value) + 1, value: append([]byte(value), 0)}
+}
+
+func (b tiffBuilder) rationals(tag uint16, values ...[2]uint32) tiffField {
+	var data []byte
+	for _, v := range values {
+		data = b.order.AppendUint32(data, v[0])
+		data = b.order.AppendUint32(data, v[1])
+	}
+	return tiffField{tag: tag, typ: 5, count: len(values), value: data}
+}
+
+// build はIFD0・Exif IFD・GPS IFDを順に並べたTIFFを返す
+// IFD0にはExif IFDとGPS IFDへのポインターを自動で加える（空のIFDは書き込まない）
+func (b tiffBuilder) build(ifd0, exif, gps []tiffField) []byte {
+	header := []byte("II")
+	if b.order == binary.BigEndian {
+		header = []byte("MM")
+	}
+	header = b.order.AppendUint16(header, 42)
+	header = b.order.AppendUint32(header, 8)
+
+	ifdSize := func(fields []tiffField) int {
+		size := 2 + 12*len(fields) + 4
+		for _, f := range fields {
+			if len(f.value) > 4 {
+				size += len(f.value)
+			}
+		}
+		return size
+	}
+	ifd0Count := len(ifd0)
+	if len(exif) > 0 {
+		ifd0Count++
+	}
+	if len(gps) > 0 {
+		ifd0Count++
+	}
+	exifOffset := 8 + ifdSize(make([]tiffField, ifd0Count)) + extraSize(ifd0)
+	gpsOffset := exifOffset
+	if len(exif) > 0 {
+		gpsOffset += ifdSize(exif)
+	}
+	if len(exif) > 0 {
+		ifd0 = append(ifd0, b.long(tagExifIFD, uint32(exifOffset)))
+	}
+	if len(gps) > 0 {
+		ifd0 = append(ifd0, b.long(tagGPSIFD, uint32(gpsOffset)))
+	}
+
+	data := b.appendIFD(header, ifd0)
+	if len(exif) > 0 {
+		data = b.appendIFD(data, exif)
+	}
+	if len(gps) > 0 {
+		data = b.appendIFD(data, gps)
+	}
+	return data
+}
+
+func extraSize(fields []tiffField) int {
+	size := 0
+	for _, f := range fields {
+		if len(f.value) > 4 {
+			size += len(f.value)
+		}
+	}
+	return size
+}
+
+// appendIFD はdataの末尾にIFDを書き込み、4バイトに収まらない値はIFDの直後に置く
+func (b tiffBuilder) appendIFD(data []byte, fields []tiffField) []byte {
+	valueOffset := len(data) + 2 + 12*len(fields) + 4
+	var values []byte
+	data = b.order.AppendUint16(data, uint16(len(fields)))
+	for _, f := range fields {
+		data = b.order.AppendUint16(data, f.tag)
+		data = b.order.AppendUint16(data, f.typ)
+		data = b.order.AppendUint32(data, uint32(f.count))
+		if len(f.value) > 4 {
+			data = b.order.AppendUint32(data, uint32(valueOffset+len(values)))
+			values = append(values, f.value...)
+			continue
+		}
+		data = append(data, f.value...)
+		data = append(data, make([]byte, 4-len(f.value))...)
+	}
+	data = b.order.AppendUint32(data, 0) // 次のIFDはない
+	return append(data, values...)
+}
+
+// sampleTIFF は東京駅で撮影した写真を想定したEXIF
+func sampleTIFF(order tiffOrder) []byte {
+	b := tiffBuilder{order: order}
+	return b.build(
+		[]tiffField{b.short(tagOrientation, 6)},
+		[]tiffField{
+			b.ascii(tagDateTimeOriginal, "2024:05:01 10:20:30"),
+			b.ascii(tagOffsetTimeOriginal, "+09:00"),
+		},
+		[]tiffField{
+			b.ascii(tagGPSLatitudeRef, "N"),
+			b.rationals(tagGPSLatitude, [2]uint32{35, 1}, [2]uint32{40, 1}, [2]uint32{5244, 100}),
+			b.ascii(tagGPSLongitudeRef, "E"),
+			b.rationals(tagGPSLongitude, [2]uint32{139, 1}, [2]uint32{46, 1}, [2]uint32{180, 100}),
+		},
+	)
+}
+
+func assertSampleMetadata(t *testing.T, meta *Metadata) {
+	t.Helper()
+	if meta.Orientation != 6 {
+		t.Errorf("Orientation = %d, want 6", meta.Orientation)
+	}
+	wantTime := time.Date(2024, 5, 1, 10, 20, 30, 0, time.FixedZone("", 9*60*60))
+	if !meta.CapturedAt.Equal(wantTime) {
+		t.Errorf("CapturedAt = %v, want %v", meta.CapturedAt, wantTime)
+	}
+	if !meta.HasGPS {
+		t.Fatalf("HasGPS = false, want true")
+	}
+	wantLat := 35 + 40.0/60 + 52.44/3600
+	wantLon := 139 + 46.0/60 + 1.8/3600
+	if math.Abs(meta.Latitude-wantLat) > 1e-9 || math.Abs(meta.Longitude-wantLon) > 1e-9 {
+		t.Errorf("position = (%v, %v), want (%v, %v)", meta.Latitude, meta.Longitude, wantLat, wantLon)
+	}
+}
+
+func TestReadMetadata(t *testing.T) {
+	for _, order := range []tiffOrder{binary.LittleEndian, binary.BigEndian} {
+		tiff := sampleTIFF(order)
+		tests := []struct {
+			name string
+			data []byte
+		}{
+			{"JPEG", sampleJPEG(t, jpegSegment(0xE1, append([]byte("Exif\x00\x00"), tiff...)))},
+			{"PNG", samplePNG(t, pngChunk("eXIf", tiff))},
+			{"WebP", sampleWebP(riffChunk("EXIF", tiff))},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name+"/"+order.String(), func(t *testing.T) {
+				assertSampleMetadata(t, ReadMetadata(tt.data))
+			})
+		}
+	}
+}
+
+func TestReadMetadataSouthWest(t *testing.T) {
+	b := tiffBuilder{order: binary.LittleEndian}
+	tiff := b.build(nil, nil, []tiffField{
+		b.ascii(tagGPSLatitudeRef, "S"),
+		b.rationals(tagGPSLatitude, [2]uint32{33, 1}, [2]uint32{52, 1}, [2]uint32{0, 1}),
+		b.ascii(tagGPSLongitudeRef, "W"),
+		b.rationals(tagGPSLongitude, [2]uint32{70, 1}, [2]uint32{30, 1}, [2]uint32{0, 1}),
+	})
+	meta := ReadMetadata(sampleJPEG(t, jpegSegment(0xE1, append([]byte("Exif\x00\x00"), tiff...))))
+	if !meta.HasGPS || meta.Latitude != -(33+52.0/60) || meta.Longitude != -70.5 {
+		t.Errorf("got (%v, %v, %v), want (true, %v, -70.5)", meta.HasGPS, meta.Latitude, meta.Longitude, -(33 + 52.0/60))
+	}
+	if meta.Orientation != 1 || !meta.CapturedAt.IsZero() {
+		t.Errorf("got Orientation %d and CapturedAt %v, want the defaults", meta.Orientation, meta.CapturedAt)
+	}
+}
+
+func TestReadMetadataWithoutEXIF(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"JPEG":    sampleJPEG(t),
+		"PNG":     samplePNG(t),
+		"WebP":    sampleWebP(),
+		"unknown": []byte("GIF89a"),
+		"empty":   nil,
+	} {
+		meta := ReadMetadata(data)
+		if meta.HasGPS || meta.Orientation != 1 || !meta.CapturedAt.IsZero() {
+			t.Errorf("%s: ReadMetadata = %+v, want the defaults", name, meta)
+		}
+	}
+}
+
+func TestReadMetadataZeroDenominator(t *testing.T) {
+	b := tiffBuilder{order: binary.LittleEndian}
+	tiff := b.build(nil, nil, []tiffField{
+		b.ascii(tagGPSLatitudeRef, "N"),
+		b.rationals(tagGPSLatitude, [2]uint32{35, 1}, [2]uint32{40, 0}, [2]uint32{0, 1}),
+		b.ascii(tagGPSLongitudeRef, "E"),
+		b.rationals(tagGPSLongitude, [2]uint32{139, 1}, [2]uint32{46, 1}, [2]uint32{0, 1}),
+	})
+	meta := ReadMetadata(sampleJPEG(t, jpegSegment(0xE1, append([]byte("Exif\x00\x00"), tiff...))))
+	if meta.HasGPS {
+		t.Errorf("HasGPS = true for a zero denominator")
+	}
+}
+
+// 壊れたファイルでもpanicせず、読み取れた範囲の情報を返す
+func TestReadMetadataTruncated(t *testing.T) {
+	tiff := sampleTIFF(binary.BigEndian)
+	for _, data := range [][]byte{
+		sampleJPEG(t, jpegSegment(0xE1, append([]byte("Exif\x00\x00"), tiff...))),
+		samplePNG(t, pngChunk("eXIf", tiff)),
+		sampleWebP(riffChunk("EXIF", tiff)),
+	} {
+		for n := range data {
+			ReadMetadata(data[:n])
+		}
+	}
+	for n := range tiff {
+		parseTIFF(tiff[:n], &Metadata{})
+	}
+}
+
+func TestParseExifTime(t *testing.T) {
+	tests := []struct {
+		value, offset string
+		want          time.Time
+	}{
+		{"2024:05:01 10:20:30", "+09:00", time.Date(2024, 5, 1, 1, 20, 30, 0, time.UTC)},
+		{"2024:05:01 10:20:30", "", time.Date(2024, 5, 1, 10, 20, 30, 0, time.UTC)},
+		{"2024:05:01 10:20:30", "invalid", time.Date(2024, 5, 1, 10, 20, 30, 0, time.UTC)},
+		{"0000:00:00 00:00:00", "", time.Time{}},
+		{"", "+09:00", time.Time{}},
+	}
+	for _, tt := range tests {
+		if got := parseExifTime(tt.value, tt.offset); !got.Equal(tt.want) {
+			t.Errorf("parseExifTime(%q, %q) = %v, want %v", tt.value, tt.offset, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// labeledImage は画素ごとに異なる色（赤の値が文字の番号）を持つ画像を作る
+func labeledImage(rows []string) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, len(rows[0]), len(rows)))
+	for y, row := range rows {
+		for x, label := range row {
+			img.Set(x, y, color.RGBA{R: uint8(label), A: 255})
+		}
+	}
+	return img
+}
+
+func imageLabels(img image.Image) []string {
+	b := img.Bounds()
+	var rows []string
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		var row []byte
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, _, _, _ := img.At(x, y).RGBA()
+			row = append(row, byte(r>>8))
+		}
+		rows = append(rows, string(row))
+	}
+	return rows
+}
+
+func TestOrient(t *testing.T) {
+	// 保存された画素の並び（3x2）を、EXIFのOrientationに従って表示する向きにしたもの
+	src := []string{
+		"abc",
+		"def",
+	}
+	tests := []struct {
+		orientation int
+		want        []string
+	}{
+		{0, []string{"abc", "def"}},
+		{1, []string{"abc", "def"}},
+		{2, []string{"cba", "fed"}},
+		{3, []string{"fed", "cba"}},
+		{4, []string{"def", "abc"}},
+		{5, []string{"ad", "be", "cf"}},
+		{6, []string{"da", "eb", "fc"}},
+		{7, []string{"fc", "eb", "da"}},
+		{8, []string{"cf", "be", "ad"}},
+		{9, []string{"abc", "def"}},
+	}
+	for _, tt := range tests {
+		got := imageLabels(Orient(labeledImage(src), tt.orientation))
+		if len(got) != len(tt.want) {
+			t.Errorf("Orient(%d) = %q, want %q", tt.orientation, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("Orient(%d) = %q, want %q", tt.orientation, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+// 原点が(0, 0)でない画像（SubImageなど）も正しく扱う
+func TestOrientSubImage(t *testing.T) {
+	img := labeledImage([]string{
+		"xxxx",
+		"xabc",
+		"xdef",
+	}).(*image.RGBA).SubImage(image.Rect(1, 1, 4, 3))
+	got := imageLabels(Orient(img, 6))
+	want := []string{"da", "eb", "fc"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Orient(6) = %q, want %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// imageFormat はメタデータの除去に対応する画像形式
+type imageFormat int
+
+const (
+	formatUnknown imageFormat = iota
+	formatJPEG
+	formatPNG
+	formatWebP
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+func detectFormat(data []byte) imageFormat {
+	switch {
+	case len(data) >= 3 && data[0] == 0xFF && data[1] == 0xD8 && data[2] == 0xFF:
+		return formatJPEG
+	case bytes.HasPrefix(data, pngSignature):
+		return formatPNG
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return formatWebP
+	}
+	return formatUnknown
+}
+
+// ContentType は画像のMIMEタイプを返す
+func ContentType(data []byte) string {
+	switch detectFormat(data) {
+	case formatJPEG:
+		return "image/jpeg"
+	case formatPNG:
+		return "image/png"
+	case formatWebP:
+		return "image/webp"
+	}
+	return "application/octet-stream"
+}
+
+// StripMetadata はEXIF・XMP・コメントなどのメタデータを取り除いた画像を返す
+// 画素データは再エンコードせずにそのまま残す
+func StripMetadata(data []byte) ([]byte, error) {
+	switch detectFormat(data) {
+	case formatJPEG:
+		return stripJPEG(data)
+	case formatPNG:
+		return stripPNG(data)
+	case formatWebP:
+		return stripWebP(data)
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+// chunk は画像ファイル内のセグメント（チャンク）
+type chunk struct {
+	marker     byte   // JPEGのマーカー
+	name       string // PNG・WebPのチャンク種別
+	start, end int    // ヘッダーを含むファイル内の範囲
+	payload    []byte
+}
+
+// jpegSegments はJPEGのSOS（画像データの開始）より前のセグメントと、SOSの位置を返す
+func jpegSegments(data []byte) ([]chunk, int) {
+	var segments []chunk
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return segments, -1
+		}
+		marker := data[pos+1]
+		if marker == 0xFF {
+			// フィルバイト
+			pos++
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 {
+			return segments, pos
+		}
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			pos += 2
+			continue
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return segments, -1
+		}
+		segments = append(segments, chunk{marker: marker, start: pos, end: end, payload: data[pos+4 : end]})
+		pos = end
+	}
+	return segments, -1
+}
+
+// jpegEnd はSOSの位置から画像データを読み進め、EOI（画像の終了）の直後の位置を返す（見つからない場合は-1）
+func jpegEnd(data []byte, pos int) int {
+	for pos+2 <= len(data) {
+		if data[pos] != 0xFF {
+			return -1
+		}
+		marker := data[pos+1]
+		if marker == 0xFF {
+			// フィルバイト
+			pos++
+			continue
+		}
+		if marker == 0xD9 {
+			return pos + 2
+		}
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			pos += 2
+			continue
+		}
+		if pos+4 > len(data) {
+			return -1
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return -1
+		}
+		pos = end
+		if marker != 0xDA {
+			// プログレッシブJPEGではスキャンの間にDHTなどのセグメントが入る
+			continue
+		}
+		// 符号化されたデータは、スタッフィング（FF00）とリスタートマーカー（RST）以外のマーカーの手前まで続く
+		for pos+1 < len(data) && (data[pos] != 0xFF || data[pos+1] == 0x00 || (data[pos+1] >= 0xD0 && data[pos+1] <= 0xD7)) {
+			pos++
+		}
+	}
+	return -1
+}
+
+// stripJPEG はAPP1（EXIF・XMP）・APP13（IPTC）・COM（コメント）を取り除く
+// APP0（JFIF）・APP2（ICCプロファイル）・APP14（Adobe）は色の再現に必要なため残す
+// EOIの後ろにはMPFの深度・プレビューなど、撮影位置を含むEXIFを持つ別のJPEGが続くことがあるため、EOIまでで切り詰める
+func stripJPEG(data []byte) ([]byte, error) {
+	segments, sos := jpegSegments(data)
+	if sos < 0 {
+		return nil, ErrUnsupportedFormat
+	}
+	end := jpegEnd(data, sos)
+	if end < 0 {
+		return nil, ErrUnsupportedFormat
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+	for _, seg := range segments {
+		switch seg.marker {
+		case 0xE1, 0xED, 0xFE:
+			continue
+		}
+		out.Write(data[seg.start:seg.end])
+	}
+	out.Write(data[sos:end])
+	return out.Bytes(), nil
+}
+
+// pngChunks はPNGのチャンクを返す
+func pngChunks(data []byte) []chunk {
+	var chunks []chunk
+	pos := len(pngSignature)
+	for pos+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		end := pos + 12 + length
+		if end > len(data) {
+			break
+		}
+		chunks = append(chunks, chunk{name: string(data[pos+4 : pos+8]), start: pos, end: end, payload: data[pos+8 : pos+8+length]})
+		pos = end
+	}
+	return chunks
+}
+
+// stripPNG はeXIf・テキスト（tEXt・zTXt・iTXt）・tIMEのチャンクを取り除く
+func stripPNG(data []byte) ([]byte, error) {
+	chunks := pngChunks(data)
+	if len(chunks) == 0 {
+		return nil, ErrUnsupportedFormat
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(pngSignature)
+	for _, c := range chunks {
+		switch c.name {
+		case "eXIf", "tEXt", "zTXt", "iTXt", "tIME":
+			continue
+		}
+		out.Write(data[c.start:c.end])
+	}
+	return out.Bytes(), nil
+}
+
+// webpChunks はWebP（RIFF）のチャンクを返す
+func webpChunks(data []byte) []chunk {
+	var chunks []chunk
+	pos := 12
+	for pos+8 <= len(data) {
+		length := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		end := pos + 8 + length
+		if end > len(data) {
+			break
+		}
+		payload := data[pos+8 : end]
+		// チャンクは2バイト境界に揃えられる
+		if length%2 == 1 && end < len(data) {
+			end++
+		}
+		chunks = append(chunks, chunk{name: string(data[pos : pos+4]), start: pos, end: end, payload: payload})
+		pos = end
+	}
+	return chunks
+}
+
+// VP8Xチャンクのフラグ
+const (
+	webpFlagEXIF = 0x08
+	webpFlagXMP  = 0x04
+)
+
+// stripWebP はEXIF・XMPのチャンクを取り除き、VP8Xのフラグを更新する
+func stripWebP(data []byte) ([]byte, error) {
+	chunks := webpChunks(data)
+	if len(chunks) == 0 {
+		return nil, ErrUnsupportedFormat
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:12])
+	for _, c := range chunks {
+		switch c.name {
+		case "EXIF", "XMP ":
+			continue
+		case "VP8X":
+			vp8x := append([]byte{}, data[c.start:c.end]...)
+			if len(vp8x) > 8 {
+				vp8x[8] &^= webpFlagEXIF | webpFlagXMP
+			}
+			out.Write(vp8x)
+			continue
+		}
+		out.Write(data[c.start:c.end])
+	}
+	result := out.Bytes()
+	binary.LittleEndian.PutUint32(result[4:], uint32(len(result)-8))
+	return result, nil
+}
@@ -0,0 +1,333 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"slices"
+	"testing"
+)
+
+// sampleImage はテスト用の4x3の画像
+func sampleImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 3))
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 4; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 60), G: uint8(y * 100), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func jpegSegment(marker byte, payload []byte) []byte {
+	segment := []byte{0xFF, marker}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// sampleJPEG はSOIの直後にsegmentsを挿入したJPEGを返す
+func sampleJPEG(t *testing.T, segments ...[]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, sampleImage(), nil); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+	data := append([]byte{}, encoded[:2]...)
+	for _, segment := range segments {
+		data = append(data, segment...)
+	}
+	return append(data, encoded[2:]...)
+}
+
+func pngChunk(name string, payload []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(payload)))
+	chunk = append(chunk, name...)
+	chunk = append(chunk, payload...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+// samplePNG はIHDRの直後にchunksを挿入したPNGを返す
+func samplePNG(t *testing.T, chunks ...[]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, sampleImage()); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+	ihdrEnd := len(pngSignature) + 12 + 13
+	data := append([]byte{}, encoded[:ihdrEnd]...)
+	for _, chunk := range chunks {
+		data = append(data, chunk...)
+	}
+	return append(data, encoded[ihdrEnd:]...)
+}
+
+func riffChunk(name string, payload []byte) []byte {
+	chunk := append([]byte(name), binary.LittleEndian.AppendUint32(nil, uint32(len(payload)))...)
+	chunk = append(chunk, payload...)
+	if len(payload)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+// sampleWebP はVP8X・ICCP・画像データ（奇数長のVP8L）の後にchunksを並べたWebPを返す
+// 画像データは中身を検証しないため、デコードできる必要はない
+func sampleWebP(chunks ...[]byte) []byte {
+	vp8x := make([]byte, 10)
+	vp8x[0] = 0x20 | webpFlagEXIF | webpFlagXMP // ICC・EXIF・XMPあり
+	body := []byte("WEBP")
+	body = append(body, riffChunk("VP8X", vp8x)...)
+	body = append(body, riffChunk("ICCP", []byte("icc-profile"))...)
+	body = append(body, riffChunk("VP8L", []byte{0x2F, 1, 2, 3, 4})...)
+	for _, chunk := range chunks {
+		body = append(body, chunk...)
+	}
+	data := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	return append(data, body...)
+}
+
+func jpegMarkers(data []byte) []byte {
+	segments, _ := jpegSegments(data)
+	var markers []byte
+	for _, segment := range segments {
+		markers = append(markers, segment.marker)
+	}
+	return markers
+}
+
+func chunkNames(chunks []chunk) []string {
+	var names []string
+	for _, c := range chunks {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+func assertSameImage(t *testing.T, got, want []byte) {
+	t.Helper()
+	gotImage, err := Decode(got)
+	if err != nil {
+		t.Fatalf("stripped image cannot be decoded: %v", err)
+	}
+	wantImage, err := Decode(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotImage.Bounds() != wantImage.Bounds() {
+		t.Fatalf("bounds = %v, want %v", gotImage.Bounds(), wantImage.Bounds())
+	}
+	b := wantImage.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if gotImage.At(x, y) != wantImage.At(x, y) {
+				t.Fatalf("pixel (%d, %d) = %v, want %v", x, y, gotImage.At(x, y), wantImage.At(x, y))
+			}
+		}
+	}
+}
+
+func TestStripJPEG(t *testing.T) {
+	exif := jpegSegment(0xE1, append([]byte("Exif\x00\x00"), sampleTIFF(binary.LittleEndian)...))
+	xmp := jpegSegment(0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta/>"))
+	photoshop := jpegSegment(0xED, []byte("Photoshop 3.0\x008BIM"))
+	comment := jpegSegment(0xFE, []byte("taken at home"))
+	icc := jpegSegment(0xE2, []byte("ICC_PROFILE\x00\x01\x01profile"))
+	jfif := jpegSegment(0xE0, []byte("JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00"))
+	adobe := jpegSegment(0xEE, []byte("Adobe\x00\x64\x00\x00\x00\x00\x01"))
+	data := sampleJPEG(t, jfif, exif, xmp, icc, photoshop, comment, adobe)
+
+	stripped, err := StripMetadata(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	markers := jpegMarkers(stripped)
+	for _, removed := range []byte{0xE1, 0xED, 0xFE} {
+		if slices.Contains(markers, removed) {
+			t.Errorf("marker %#x was not removed: %x", removed, markers)
+		}
+	}
+	for _, kept := range [][]byte{jfif, icc, adobe} {
+		if !bytes.Contains(stripped, kept) {
+			t.Errorf("segment %#x was not kept", kept[1])
+		}
+	}
+	if want := len(data) - len(exif) - len(xmp) - len(photoshop) - len(comment); len(stripped) != want {
+		t.Errorf("len(stripped) = %d, want %d", len(stripped), want)
+	}
+	_, sos := jpegSegments(data)
+	_, strippedSOS := jpegSegments(stripped)
+	if !bytes.Equal(stripped[strippedSOS:], data[sos:]) {
+		t.Errorf("image data after SOS was changed")
+	}
+	if meta := ReadMetadata(stripped); meta.HasGPS || meta.Orientation != 1 {
+		t.Errorf("metadata remains after stripping: %+v", meta)
+	}
+	assertSameImage(t, stripped, data)
+}
+
+// EOIの後ろに続くJPEG（MPFの深度・プレビューなど）は、そのEXIFごと取り除く
+func TestStripJPEGTrailingImage(t *testing.T) {
+	exif := jpegSegment(0xE1, append([]byte("Exif\x00\x00"), sampleTIFF(binary.LittleEndian)...))
+	primary := sampleJPEG(t, exif)
+	data := append(append([]byte{}, primary...), sampleJPEG(t, exif)...)
+
+	stripped, err := StripMetadata(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := StripMetadata(primary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stripped, want) {
+		t.Errorf("len(stripped) = %d, want the primary image only (%d bytes)", len(stripped), len(want))
+	}
+	if bytes.Contains(stripped, []byte("Exif\x00\x00")) {
+		t.Errorf("EXIF of the trailing image remains")
+	}
+	if !bytes.HasSuffix(stripped, []byte{0xFF, 0xD9}) {
+		t.Errorf("stripped image does not end with EOI")
+	}
+	assertSameImage(t, stripped, primary)
+}
+
+func TestJPEGEnd(t *testing.T) {
+	sos := jpegSegment(0xDA, []byte{0x01, 0x01, 0x00, 0x00, 0x3F, 0x00})
+	dht := jpegSegment(0xC4, []byte{0x00})
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"stuffed bytes and restart markers", concat(sos, []byte{0x12, 0xFF, 0x00, 0x34, 0xFF, 0xD0, 0x56}, []byte{0xFF, 0xD9}, []byte{0xFF, 0xD8}), len(sos) + 9},
+		{"segments between scans", concat(sos, []byte{0x12}, dht, sos, []byte{0x34}, []byte{0xFF, 0xFF, 0xD9}), 2*len(sos) + len(dht) + 5},
+		{"missing EOI", concat(sos, []byte{0x12, 0xFF, 0x00}), -1},
+		{"truncated segment", sos[:4], -1},
+	}
+	for _, tt := range tests {
+		if got := jpegEnd(tt.data, 0); got != tt.want {
+			t.Errorf("%s: jpegEnd() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func concat(parts ...[]byte) []byte {
+	var data []byte
+	for _, part := range parts {
+		data = append(data, part...)
+	}
+	return data
+}
+
+func TestStripPNG(t *testing.T) {
+	data := samplePNG(t,
+		pngChunk("iCCP", []byte("icc\x00\x00profile")),
+		pngChunk("eXIf", sampleTIFF(binary.BigEndian)),
+		pngChunk("tEXt", []byte("Comment\x00taken at home")),
+		pngChunk("zTXt", []byte("Comment\x00\x00x")),
+		pngChunk("iTXt", []byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00<x:xmpmeta/>")),
+		pngChunk("tIME", []byte{0x07, 0xE8, 5, 1, 10, 20, 30}),
+		pngChunk("sRGB", []byte{0}),
+	)
+
+	stripped, err := StripMetadata(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := chunkNames(pngChunks(stripped))
+	if names[0] != "IHDR" || names[1] != "iCCP" || names[2] != "sRGB" || names[len(names)-1] != "IEND" {
+		t.Errorf("chunks = %v, want IHDR, iCCP, sRGB, ..., IEND", names)
+	}
+	for _, removed := range []string{"eXIf", "tEXt", "zTXt", "iTXt", "tIME"} {
+		if slices.Contains(names, removed) {
+			t.Errorf("chunk %s was not removed: %v", removed, names)
+		}
+	}
+	if meta := ReadMetadata(stripped); meta.HasGPS || meta.Orientation != 1 {
+		t.Errorf("metadata remains after stripping: %+v", meta)
+	}
+	assertSameImage(t, stripped, data)
+}
+
+func TestStripWebP(t *testing.T) {
+	data := sampleWebP(
+		riffChunk("EXIF", sampleTIFF(binary.LittleEndian)),
+		riffChunk("XMP ", []byte("<x:xmpmeta/>")),
+	)
+
+	stripped, err := StripMetadata(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := webpChunks(stripped)
+	if names := chunkNames(chunks); !slices.Equal(names, []string{"VP8X", "ICCP", "VP8L"}) {
+		t.Errorf("chunks = %v, want [VP8X ICCP VP8L]", names)
+	}
+	if flags := chunks[0].payload[0]; flags != 0x20 {
+		t.Errorf("VP8X flags = %#x, want only the ICC flag (0x20)", flags)
+	}
+	if size := binary.LittleEndian.Uint32(stripped[4:]); int(size) != len(stripped)-8 {
+		t.Errorf("RIFF size = %d, want %d", size, len(stripped)-8)
+	}
+	// VP8Xより後のチャンクは奇数長の埋め草を含めてそのまま残す
+	vp8xEnd := 12 + 8 + 10
+	if !bytes.Equal(stripped[vp8xEnd:], data[vp8xEnd:len(stripped)]) {
+		t.Errorf("kept chunks were changed")
+	}
+	if meta := ReadMetadata(stripped); meta.HasGPS || meta.Orientation != 1 {
+		t.Errorf("metadata remains after stripping: %+v", meta)
+	}
+}
+
+func TestStripMetadataUnsupported(t *testing.T) {
+	jpegWithoutSOS := []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x04, 0x00, 0x00}
+	for name, data := range map[string][]byte{
+		"GIF":              []byte("GIF89a\x01\x00\x01\x00"),
+		"empty":            nil,
+		"JPEG without SOS": jpegWithoutSOS,
+		"PNG signature":    pngSignature,
+		"WebP header":      []byte("RIFF\x04\x00\x00\x00WEBP"),
+	} {
+		if _, err := StripMetadata(data); !errors.Is(err, ErrUnsupportedFormat) {
+			t.Errorf("%s: StripMetadata error = %v, want ErrUnsupportedFormat", name, err)
+		}
+	}
+}
+
+// 壊れたファイルでもpanicしない
+func TestStripMetadataTruncated(t *testing.T) {
+	exif := sampleTIFF(binary.LittleEndian)
+	for _, data := range [][]byte{
+		sampleJPEG(t, jpegSegment(0xE1, append([]byte("Exif\x00\x00"), exif...))),
+		samplePNG(t, pngChunk("eXIf", exif)),
+		sampleWebP(riffChunk("EXIF", exif)),
+	} {
+		for n := range data {
+			_, _ = StripMetadata(data[:n])
+		}
+	}
+}
+
+func TestContentType(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want string
+	}{
+		{sampleJPEG(t), "image/jpeg"},
+		{samplePNG(t), "image/png"},
+		{sampleWebP(), "image/webp"},
+		{[]byte("GIF89a"), "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		if got := ContentType(tt.data); got != tt.want {
+			t.Errorf("ContentType(%q...) = %q, want %q", tt.data[:4], got, tt.want)
+		}
+	}
+}
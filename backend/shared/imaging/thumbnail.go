@@ -0,0 +1,59 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+
+	// 対応する入力形式のデコーダーを登録する
+	_ "image/gif"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+
+	"golang.org/x/image/draw"
+)
+
+// ErrUnsupportedFormat はデコードできない画像形式（HEICなど）の場合に返す
+var ErrUnsupportedFormat = errors.New("unsupported image format")
+
+// thumbnailQuality はサムネイルのJPEG品質
+const thumbnailQuality = 80
+
+// Decode は画像データをデコードする
+func Decode(data []byte) (image.Image, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if errors.Is(err, image.ErrFormat) {
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return img, nil
+}
+
+// Resize は縦横比を保ったまま幅がwidth以下になるよう縮小する（拡大はしない）
+func Resize(img image.Image, width int) image.Image {
+	bounds := img.Bounds()
+	if bounds.Dx() <= width {
+		return img
+	}
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+// Thumbnail は幅widthに縮小したJPEGのサムネイルを生成する
+func Thumbnail(img image.Image, width int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, Resize(img, width), &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	return buf.Bytes(), nil
+}
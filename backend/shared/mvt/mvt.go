@@ -0,0 +1,216 @@
+// Package mvt は地点をMapbox Vector Tile（仕様2.1のprotobuf）に書き出す
+// 障害物の地図表示に必要な点のフィーチャーのみを扱い、線と面は扱わない
+package mvt
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+)
+
+// DefaultExtent はタイル内の座標の範囲（タイルの一辺を4096分割する）
+const DefaultExtent = 4096
+
+// Layer はタイルのレイヤー
+type Layer struct {
+	Name     string
+	Extent   uint32 // 0の場合はDefaultExtent
+	Features []Feature
+}
+
+// Feature は点のフィーチャー
+// X・Yはタイルの左上を原点とするタイル内の座標で、近くのタイルにはみ出した点を描けるよう範囲外の値も許す
+type Feature struct {
+	ID         uint64
+	X, Y       int
+	Properties map[string]any // string・int・bool・float64のいずれか
+}
+
+// Bounds はタイルの範囲（緯度・経度）
+type Bounds struct {
+	MinLat float64
+	MinLon float64
+	MaxLat float64
+	MaxLon float64
+}
+
+// TileBounds はズームレベルzのタイル(x, y)の範囲を、タイルの一辺に対するbufferの割合だけ広げて返す
+func TileBounds(z, x, y int, buffer float64) Bounds {
+	n := math.Exp2(float64(z))
+	return Bounds{
+		MinLat: tileLat(float64(y)+1+buffer, n),
+		MinLon: (float64(x)-buffer)/n*360 - 180,
+		MaxLat: tileLat(float64(y)-buffer, n),
+		MaxLon: (float64(x)+1+buffer)/n*360 - 180,
+	}
+}
+
+func tileLat(y, n float64) float64 {
+	return math.Atan(math.Sinh(math.Pi*(1-2*y/n))) * 180 / math.Pi
+}
+
+// Project は地点（緯度・経度）をWebメルカトルで投影し、タイル(x, y)内の座標を返す
+func Project(lat, lon float64, z, x, y int, extent uint32) (int, int) {
+	n := math.Exp2(float64(z))
+	sinLat := math.Sin(lat * math.Pi / 180)
+	tileX := (lon + 180) / 360 * n
+	tileY := (0.5 - math.Log((1+sinLat)/(1-sinLat))/(4*math.Pi)) * n
+	return int(math.Floor((tileX - float64(x)) * float64(extent))), int(math.Floor((tileY - float64(y)) * float64(extent)))
+}
+
+// protobufのフィールド番号（vector_tile.proto）
+const (
+	tileLayers = 3
+
+	layerName     = 1
+	layerFeatures = 2
+	layerKeys     = 3
+	layerValues   = 4
+	layerExtent   = 5
+	layerVersion  = 15
+
+	featureID       = 1
+	featureTags     = 2
+	featureType     = 3
+	featureGeometry = 4
+
+	valueString = 1
+	valueDouble = 3
+	valueUint   = 5
+	valueSint   = 6
+	valueBool   = 7
+)
+
+// geomTypePoint はフィーチャーの種類の点
+const geomTypePoint = 1
+
+// commandMoveTo は点を1つ置くジオメトリのコマンド（MoveTo、回数1）
+const commandMoveTo = 1 | 1<<3
+
+// Encode はレイヤーをタイルに書き出す（フィーチャーのないレイヤーは省く）
+func Encode(layers ...Layer) []byte {
+	var tile []byte
+	for _, layer := range layers {
+		if len(layer.Features) == 0 {
+			continue
+		}
+		tile = appendBytes(tile, tileLayers, encodeLayer(layer))
+	}
+	return tile
+}
+
+func encodeLayer(layer Layer) []byte {
+	extent := layer.Extent
+	if extent == 0 {
+		extent = DefaultExtent
+	}
+
+	// 属性の名前と値はレイヤー内で共有し、フィーチャーからは添字で参照する
+	keys := map[string]int{}
+	values := map[any]int{}
+	var keyList []string
+	var valueList []any
+	var features [][]byte
+	for _, feature := range layer.Features {
+		names := make([]string, 0, len(feature.Properties))
+		for name := range feature.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var tags []uint64
+		for _, name := range names {
+			value := feature.Properties[name]
+			if i, ok := value.(int); ok {
+				value = int64(i)
+			}
+			keyIndex, ok := keys[name]
+			if !ok {
+				keyIndex = len(keyList)
+				keys[name] = keyIndex
+				keyList = append(keyList, name)
+			}
+			valueIndex, ok := values[value]
+			if !ok {
+				valueIndex = len(valueList)
+				values[value] = valueIndex
+				valueList = append(valueList, value)
+			}
+			tags = append(tags, uint64(keyIndex), uint64(valueIndex))
+		}
+
+		var data []byte
+		data = appendVarint(data, featureID, feature.ID)
+		if len(tags) > 0 {
+			data = appendPacked(data, featureTags, tags)
+		}
+		data = appendVarint(data, featureType, geomTypePoint)
+		data = appendPacked(data, featureGeometry, []uint64{commandMoveTo, zigzag(feature.X), zigzag(feature.Y)})
+		features = append(features, data)
+	}
+
+	var data []byte
+	data = appendVarint(data, layerVersion, 2)
+	data = appendBytes(data, layerName, []byte(layer.Name))
+	for _, feature := range features {
+		data = appendBytes(data, layerFeatures, feature)
+	}
+	for _, key := range keyList {
+		data = appendBytes(data, layerKeys, []byte(key))
+	}
+	for _, value := range valueList {
+		data = appendBytes(data, layerValues, encodeValue(value))
+	}
+	data = appendVarint(data, layerExtent, uint64(extent))
+	return data
+}
+
+func encodeValue(value any) []byte {
+	switch v := value.(type) {
+	case string:
+		return appendBytes(nil, valueString, []byte(v))
+	case int64:
+		if v < 0 {
+			return appendVarint(nil, valueSint, zigzag(int(v)))
+		}
+		return appendVarint(nil, valueUint, uint64(v))
+	case bool:
+		var b uint64
+		if v {
+			b = 1
+		}
+		return appendVarint(nil, valueBool, b)
+	case float64:
+		data := appendKey(nil, valueDouble, 1)
+		return binary.LittleEndian.AppendUint64(data, math.Float64bits(v))
+	}
+	return nil
+}
+
+func appendKey(data []byte, field int, wireType int) []byte {
+	return binary.AppendUvarint(data, uint64(field)<<3|uint64(wireType))
+}
+
+func appendVarint(data []byte, field int, value uint64) []byte {
+	data = appendKey(data, field, 0)
+	return binary.AppendUvarint(data, value)
+}
+
+func appendBytes(data []byte, field int, value []byte) []byte {
+	data = appendKey(data, field, 2)
+	data = binary.AppendUvarint(data, uint64(len(value)))
+	return append(data, value...)
+}
+
+func appendPacked(data []byte, field int, values []uint64) []byte {
+	var packed []byte
+	for _, value := range values {
+		packed = binary.AppendUvarint(packed, value)
+	}
+	return appendBytes(data, field, packed)
+}
+
+// zigzag は符号付きの整数を、絶対値の小さい値ほど短いvarintになるよう変換する
+func zigzag(value int) uint64 {
+	return uint64(value<<1) ^ uint64(value>>63)
+}
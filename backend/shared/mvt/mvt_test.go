@@ -0,0 +1,338 @@
+package mvt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// 以下はvector_tile.protoに従ってタイルを読む、テスト用の独立したデコーダー
+// パッケージの書き出し処理を使わずに読むことで、仕様どおりに書き出せているかを確かめる
+
+type decodedLayer struct {
+	Version  uint64
+	Name     string
+	Extent   uint64
+	Features []decodedFeature
+}
+
+type decodedFeature struct {
+	ID         uint64
+	Type       uint64
+	Points     [][2]int
+	Properties map[string]any
+}
+
+type protoField struct {
+	number  int
+	varint  uint64
+	fixed64 uint64
+	bytes   []byte
+}
+
+func readFields(t *testing.T, data []byte) []protoField {
+	t.Helper()
+	var fields []protoField
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			t.Fatalf("invalid field key")
+		}
+		data = data[n:]
+		field := protoField{number: int(key >> 3)}
+		switch key & 7 {
+		case 0:
+			field.varint, n = binary.Uvarint(data)
+			if n <= 0 {
+				t.Fatalf("invalid varint in field %d", field.number)
+			}
+			data = data[n:]
+		case 1:
+			if len(data) < 8 {
+				t.Fatalf("truncated fixed64 in field %d", field.number)
+			}
+			field.fixed64 = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+		case 2:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || length > uint64(len(data)-n) {
+				t.Fatalf("invalid length in field %d", field.number)
+			}
+			field.bytes = data[n : n+int(length)]
+			data = data[n+int(length):]
+		default:
+			t.Fatalf("unexpected wire type %d in field %d", key&7, field.number)
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+func readPacked(t *testing.T, data []byte) []uint64 {
+	t.Helper()
+	var values []uint64
+	for len(data) > 0 {
+		value, n := binary.Uvarint(data)
+		if n <= 0 {
+			t.Fatalf("invalid packed varint")
+		}
+		values = append(values, value)
+		data = data[n:]
+	}
+	return values
+}
+
+func unzigzag(value uint64) int {
+	return int(int64(value>>1) ^ -int64(value&1))
+}
+
+func decodeTile(t *testing.T, tile []byte) []decodedLayer {
+	t.Helper()
+	var layers []decodedLayer
+	for _, field := range readFields(t, tile) {
+		if field.number != 3 {
+			t.Fatalf("unexpected tile field %d", field.number)
+		}
+		layers = append(layers, decodeLayer(t, field.bytes))
+	}
+	return layers
+}
+
+func decodeLayer(t *testing.T, data []byte) decodedLayer {
+	t.Helper()
+	layer := decodedLayer{Extent: 4096}
+	var keys []string
+	var values []any
+	var features [][]byte
+	for _, field := range readFields(t, data) {
+		switch field.number {
+		case 1:
+			layer.Name = string(field.bytes)
+		case 2:
+			features = append(features, field.bytes)
+		case 3:
+			keys = append(keys, string(field.bytes))
+		case 4:
+			values = append(values, decodeValue(t, field.bytes))
+		case 5:
+			layer.Extent = field.varint
+		case 15:
+			layer.Version = field.varint
+		default:
+			t.Fatalf("unexpected layer field %d", field.number)
+		}
+	}
+	for _, data := range features {
+		layer.Features = append(layer.Features, decodeFeature(t, data, keys, values))
+	}
+	return layer
+}
+
+func decodeValue(t *testing.T, data []byte) any {
+	t.Helper()
+	fields := readFields(t, data)
+	if len(fields) != 1 {
+		t.Fatalf("value has %d fields, want exactly 1", len(fields))
+	}
+	switch field := fields[0]; field.number {
+	case 1:
+		return string(field.bytes)
+	case 3:
+		return math.Float64frombits(field.fixed64)
+	case 5:
+		return int64(field.varint)
+	case 6:
+		return int64(unzigzag(field.varint))
+	case 7:
+		return field.varint != 0
+	default:
+		t.Fatalf("unexpected value field %d", field.number)
+	}
+	return nil
+}
+
+func decodeFeature(t *testing.T, data []byte, keys []string, values []any) decodedFeature {
+	t.Helper()
+	var feature decodedFeature
+	for _, field := range readFields(t, data) {
+		switch field.number {
+		case 1:
+			feature.ID = field.varint
+		case 2:
+			tags := readPacked(t, field.bytes)
+			if len(tags)%2 != 0 {
+				t.Fatalf("odd number of tags")
+			}
+			feature.Properties = map[string]any{}
+			for i := 0; i < len(tags); i += 2 {
+				if tags[i] >= uint64(len(keys)) || tags[i+1] >= uint64(len(values)) {
+					t.Fatalf("tag index out of range")
+				}
+				feature.Properties[keys[tags[i]]] = values[tags[i+1]]
+			}
+		case 3:
+			feature.Type = field.varint
+		case 4:
+			feature.Points = decodePoints(t, readPacked(t, field.bytes))
+		default:
+			t.Fatalf("unexpected feature field %d", field.number)
+		}
+	}
+	return feature
+}
+
+// decodePoints は点のジオメトリ（MoveToのみ）を読み、カーソルを動かしながら座標を返す
+func decodePoints(t *testing.T, geometry []uint64) [][2]int {
+	t.Helper()
+	var points [][2]int
+	var x, y int
+	for len(geometry) > 0 {
+		command, count := geometry[0]&7, int(geometry[0]>>3)
+		if command != 1 {
+			t.Fatalf("unexpected geometry command %d", command)
+		}
+		geometry = geometry[1:]
+		if len(geometry) < 2*count {
+			t.Fatalf("truncated geometry")
+		}
+		for i := 0; i < count; i++ {
+			x += unzigzag(geometry[2*i])
+			y += unzigzag(geometry[2*i+1])
+			points = append(points, [2]int{x, y})
+		}
+		geometry = geometry[2*count:]
+	}
+	return points
+}
+
+func TestEncode(t *testing.T) {
+	tile := Encode(
+		Layer{
+			Name: "obstacles",
+			Features: []Feature{
+				{ID: 12, X: 100, Y: 4000, Properties: map[string]any{"type": "step", "danger_level": 3, "resolved": false}},
+				{ID: 13, X: -20, Y: 4200, Properties: map[string]any{"type": "step", "danger_level": -1, "height": 2.5}},
+				{ID: 14, X: 0, Y: 0},
+			},
+		},
+		Layer{Name: "empty"},
+		Layer{
+			Name:     "clusters",
+			Extent:   512,
+			Features: []Feature{{ID: 1, X: 256, Y: 256, Properties: map[string]any{"count": 42, "label": "42"}}},
+		},
+	)
+
+	got := decodeTile(t, tile)
+	want := []decodedLayer{
+		{
+			Version: 2,
+			Name:    "obstacles",
+			Extent:  4096,
+			Features: []decodedFeature{
+				{ID: 12, Type: 1, Points: [][2]int{{100, 4000}}, Properties: map[string]any{"type": "step", "danger_level": int64(3), "resolved": false}},
+				{ID: 13, Type: 1, Points: [][2]int{{-20, 4200}}, Properties: map[string]any{"type": "step", "danger_level": int64(-1), "height": 2.5}},
+				{ID: 14, Type: 1, Points: [][2]int{{0, 0}}},
+			},
+		},
+		{
+			Version:  2,
+			Name:     "clusters",
+			Extent:   512,
+			Features: []decodedFeature{{ID: 1, Type: 1, Points: [][2]int{{256, 256}}, Properties: map[string]any{"count": int64(42), "label": "42"}}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decoded tile = %+v, want %+v", got, want)
+	}
+}
+
+// 同じ名前と値はレイヤー内で1つだけ書き出す
+func TestEncodeSharesKeysAndValues(t *testing.T) {
+	tile := Encode(Layer{Name: "obstacles", Features: []Feature{
+		{ID: 1, Properties: map[string]any{"type": "step", "danger_level": 3}},
+		{ID: 2, Properties: map[string]any{"type": "step", "danger_level": 3}},
+		{ID: 3, Properties: map[string]any{"type": "slope", "danger_level": 3}},
+	}})
+	var keys, values int
+	for _, field := range readFields(t, readFields(t, tile)[0].bytes) {
+		switch field.number {
+		case 3:
+			keys++
+		case 4:
+			values++
+		}
+	}
+	if keys != 2 || values != 3 {
+		t.Errorf("got %d keys and %d values, want 2 and 3", keys, values)
+	}
+}
+
+func TestEncodeEmpty(t *testing.T) {
+	if tile := Encode(Layer{Name: "obstacles"}); len(tile) != 0 {
+		t.Errorf("Encode() = %x, want an empty tile", tile)
+	}
+}
+
+func assertBounds(t *testing.T, got, want Bounds) {
+	t.Helper()
+	if math.Abs(got.MinLat-want.MinLat) > 1e-6 || math.Abs(got.MinLon-want.MinLon) > 1e-6 ||
+		math.Abs(got.MaxLat-want.MaxLat) > 1e-6 || math.Abs(got.MaxLon-want.MaxLon) > 1e-6 {
+		t.Errorf("bounds = %+v, want %+v", got, want)
+	}
+}
+
+func TestTileBounds(t *testing.T) {
+	const maxLat = 85.0511287798066
+	assertBounds(t, TileBounds(0, 0, 0, 0), Bounds{MinLat: -maxLat, MinLon: -180, MaxLat: maxLat, MaxLon: 180})
+	assertBounds(t, TileBounds(1, 1, 0, 0), Bounds{MinLat: 0, MinLon: 0, MaxLat: maxLat, MaxLon: 180})
+	assertBounds(t, TileBounds(1, 0, 1, 0), Bounds{MinLat: -maxLat, MinLon: -180, MaxLat: 0, MaxLon: 0})
+	// bufferはタイルの一辺に対する割合で、経度はそのまま、緯度はメルカトル上で広げる
+	assertBounds(t, TileBounds(1, 1, 0, 0.5), Bounds{MinLat: -66.51326044311186, MinLon: -90, MaxLat: 88.97061836630759, MaxLon: 270})
+}
+
+func TestProject(t *testing.T) {
+	// 東京駅を含むズームレベル16のタイル
+	const z, x, y = 16, 58211, 25806
+	const lat, lon = 35.681236, 139.767125
+	px, py := Project(lat, lon, z, x, y, DefaultExtent)
+	if px < 0 || px >= DefaultExtent || py < 0 || py >= DefaultExtent {
+		t.Fatalf("Project() = (%d, %d), want a point inside the tile", px, py)
+	}
+
+	// タイルの角は(0, 0)と(extent, extent)に投影する（浮動小数点の誤差で1ずれることを許容する）
+	bounds := TileBounds(z, x, y, 0)
+	for _, tt := range []struct {
+		lat, lon float64
+		want     [2]int
+	}{
+		{bounds.MaxLat, bounds.MinLon, [2]int{0, 0}},
+		{bounds.MinLat, bounds.MaxLon, [2]int{DefaultExtent, DefaultExtent}},
+	} {
+		px, py := Project(tt.lat, tt.lon, z, x, y, DefaultExtent)
+		if abs(px-tt.want[0]) > 1 || abs(py-tt.want[1]) > 1 {
+			t.Errorf("Project(%v, %v) = (%d, %d), want %v", tt.lat, tt.lon, px, py, tt.want)
+		}
+	}
+
+	// 隣のタイルの点は範囲外の座標になる
+	px, py = Project(lat, lon, z, x+1, y, DefaultExtent)
+	if px >= 0 || py < 0 || py >= DefaultExtent {
+		t.Errorf("Project() for the next tile = (%d, %d), want a negative x", px, py)
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func ExampleEncode() {
+	tile := Encode(Layer{Name: "obstacles", Features: []Feature{{ID: 1, X: 1, Y: 2}}})
+	fmt.Printf("%x\n", tile)
+	// Output: 1a1b78020a096f62737461636c65731209080118012203090204288020
+}
@@ -0,0 +1,107 @@
+package osm
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// Change はosmChange（.osc）ファイルの変更内容
+// 新しい要素のIDは負の値にする（JOSMで開くとアップロード時に採番される）
+type Change struct {
+	Generator string
+	Create    []*Node
+	Modify    []*Way
+}
+
+type xmlChange struct {
+	XMLName   xml.Name     `xml:"osmChange"`
+	Version   string       `xml:"version,attr"`
+	Generator string       `xml:"generator,attr,omitempty"`
+	Create    *xmlElements `xml:"create"`
+	Modify    *xmlElements `xml:"modify"`
+}
+
+type xmlElements struct {
+	Nodes []xmlNode `xml:"node"`
+	Ways  []xmlWay  `xml:"way"`
+}
+
+type xmlNode struct {
+	ID      int64    `xml:"id,attr"`
+	Version int      `xml:"version,attr"`
+	Lat     string   `xml:"lat,attr"`
+	Lon     string   `xml:"lon,attr"`
+	Tags    []xmlTag `xml:"tag"`
+}
+
+type xmlWay struct {
+	ID      int64    `xml:"id,attr"`
+	Version int      `xml:"version,attr"`
+	Nds     []xmlNd  `xml:"nd"`
+	Tags    []xmlTag `xml:"tag"`
+}
+
+type xmlNd struct {
+	Ref int64 `xml:"ref,attr"`
+}
+
+type xmlTag struct {
+	Key   string `xml:"k,attr"`
+	Value string `xml:"v,attr"`
+}
+
+// WriteChange はosmChangeのXMLを書き出す
+// 変更する要素はOSMの差し替えの単位のため、既存のタグと構成ノードをすべて含めておく
+func WriteChange(w io.Writer, change Change) error {
+	doc := xmlChange{Version: "0.6", Generator: change.Generator}
+	if len(change.Create) > 0 {
+		doc.Create = &xmlElements{}
+		for _, node := range change.Create {
+			doc.Create.Nodes = append(doc.Create.Nodes, xmlNode{
+				ID:      node.ID,
+				Version: node.Version,
+				Lat:     strconv.FormatFloat(node.Lat, 'f', 7, 64),
+				Lon:     strconv.FormatFloat(node.Lon, 'f', 7, 64),
+				Tags:    xmlTags(node.Tags),
+			})
+		}
+	}
+	if len(change.Modify) > 0 {
+		doc.Modify = &xmlElements{}
+		for _, way := range change.Modify {
+			xw := xmlWay{ID: way.ID, Version: way.Version, Tags: xmlTags(way.Tags)}
+			for _, ref := range way.NodeIDs {
+				xw.Nds = append(xw.Nds, xmlNd{Ref: ref})
+			}
+			doc.Modify.Ways = append(doc.Modify.Ways, xw)
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("failed to write osm change: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// xmlTags はタグをキーの順に並べる
+func xmlTags(tags map[string]string) []xmlTag {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	result := make([]xmlTag, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, xmlTag{Key: key, Value: tags[key]})
+	}
+	return result
+}
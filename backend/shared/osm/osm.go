@@ -0,0 +1,75 @@
+// Package osm はOpenStreetMapの抽出ファイル（.osmのXML・.osm.pbf）を読む
+// 障害物の取り込みに必要なノードとウェイのみを扱い、リレーションや編集者の情報は読み飛ばす
+package osm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// ファイル形式
+const (
+	FormatXML = "xml"
+	FormatPBF = "pbf"
+)
+
+// Node はOSMのノード
+type Node struct {
+	ID      int64
+	Version int // 変更ファイルで更新する場合に必要（読み込んだファイルにない場合は0）
+	Lat     float64
+	Lon     float64
+	Tags    map[string]string
+}
+
+// Way はOSMのウェイ
+type Way struct {
+	ID      int64
+	Version int
+	NodeIDs []int64
+	Tags    map[string]string
+}
+
+// Bounds は抽出した範囲
+type Bounds struct {
+	MinLat float64
+	MinLon float64
+	MaxLat float64
+	MaxLon float64
+}
+
+// Contains は地点（[緯度, 経度]）が範囲内にあるかを返す
+func (b Bounds) Contains(position [2]float64) bool {
+	return position[0] >= b.MinLat && position[0] <= b.MaxLat && position[1] >= b.MinLon && position[1] <= b.MaxLon
+}
+
+// Handler はファイルを先頭から読みながら呼び出す処理（不要な要素はnilにする）
+// OSMのファイルはノード・ウェイの順に並んでいるため、ウェイの処理では全ノードを読み終えている
+type Handler struct {
+	Bounds func(Bounds)
+	Node   func(*Node) error
+	Way    func(*Way) error
+}
+
+// Read はformatの抽出ファイルを読む（formatが空の場合は内容から判別する）
+func Read(r io.Reader, format string, h Handler) error {
+	switch format {
+	case FormatXML:
+		return ReadXML(r, h)
+	case FormatPBF:
+		return ReadPBF(r, h)
+	case "":
+	default:
+		return fmt.Errorf("unsupported osm format: %q", format)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read osm file: %w", err)
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '<' {
+		return ReadXML(bytes.NewReader(data), h)
+	}
+	return ReadPBF(bytes.NewReader(data), h)
+}
@@ -0,0 +1,539 @@
+package osm
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// PBFのブロックの大きさの上限（仕様で定められた値）
+const (
+	maxBlobHeaderSize = 64 << 10
+	maxBlobSize       = 32 << 20
+)
+
+// pbfSupportedFeatures は読み込める必須機能（OSMHeaderのrequired_features）
+var pbfSupportedFeatures = map[string]bool{
+	"OsmSchema-V0.6": true,
+	"DenseNodes":     true,
+}
+
+// ReadPBF はOSM PBF（.osm.pbf）を読む
+// 形式は https://wiki.openstreetmap.org/wiki/PBF_Format を参照
+func ReadPBF(r io.Reader, h Handler) error {
+	for {
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("failed to read pbf blob header size: %w", err)
+		}
+		if size > maxBlobHeaderSize {
+			return fmt.Errorf("pbf blob header too large: %d bytes", size)
+		}
+		header := make([]byte, size)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return fmt.Errorf("failed to read pbf blob header: %w", err)
+		}
+		blobType, dataSize, err := parseBlobHeader(header)
+		if err != nil {
+			return err
+		}
+		if dataSize > maxBlobSize {
+			return fmt.Errorf("pbf blob too large: %d bytes", dataSize)
+		}
+		blob := make([]byte, dataSize)
+		if _, err := io.ReadFull(r, blob); err != nil {
+			return fmt.Errorf("failed to read pbf blob: %w", err)
+		}
+
+		switch blobType {
+		case "OSMHeader":
+			data, err := decodeBlob(blob)
+			if err != nil {
+				return err
+			}
+			if err := readHeaderBlock(data, h); err != nil {
+				return err
+			}
+		case "OSMData":
+			if h.Node == nil && h.Way == nil {
+				continue
+			}
+			data, err := decodeBlob(blob)
+			if err != nil {
+				return err
+			}
+			if err := readPrimitiveBlock(data, h); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func parseBlobHeader(data []byte) (string, int, error) {
+	var blobType string
+	dataSize := -1
+	err := eachField(data, func(field int, value protoValue) error {
+		switch field {
+		case 1:
+			blobType = string(value.bytes)
+		case 3:
+			dataSize = int(value.varint)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid pbf blob header: %w", err)
+	}
+	if blobType == "" || dataSize < 0 {
+		return "", 0, fmt.Errorf("invalid pbf blob header")
+	}
+	return blobType, dataSize, nil
+}
+
+// decodeBlob はBlobの中身を取り出す（無圧縮とzlibのみ対応）
+func decodeBlob(data []byte) ([]byte, error) {
+	var raw, zlibData []byte
+	var rawSize int
+	var compression string
+	err := eachField(data, func(field int, value protoValue) error {
+		switch field {
+		case 1:
+			raw = value.bytes
+		case 2:
+			rawSize = int(value.varint)
+		case 3:
+			zlibData = value.bytes
+		case 4:
+			compression = "lzma"
+		case 6:
+			compression = "lz4"
+		case 7:
+			compression = "zstd"
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid pbf blob: %w", err)
+	}
+	switch {
+	case raw != nil:
+		return raw, nil
+	case zlibData != nil:
+		if rawSize > maxBlobSize {
+			return nil, fmt.Errorf("pbf blob too large: %d bytes", rawSize)
+		}
+		reader, err := zlib.NewReader(bytes.NewReader(zlibData))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress pbf blob: %w", err)
+		}
+		defer reader.Close()
+		decoded := bytes.NewBuffer(make([]byte, 0, rawSize))
+		if _, err := io.Copy(decoded, io.LimitReader(reader, maxBlobSize+1)); err != nil {
+			return nil, fmt.Errorf("failed to decompress pbf blob: %w", err)
+		}
+		if decoded.Len() > maxBlobSize {
+			return nil, fmt.Errorf("pbf blob too large")
+		}
+		return decoded.Bytes(), nil
+	case compression != "":
+		return nil, fmt.Errorf("unsupported pbf compression: %s", compression)
+	}
+	return nil, fmt.Errorf("empty pbf blob")
+}
+
+func readHeaderBlock(data []byte, h Handler) error {
+	return eachField(data, func(field int, value protoValue) error {
+		switch field {
+		case 1: // bbox（ナノ度）
+			if h.Bounds == nil {
+				return nil
+			}
+			var left, right, top, bottom int64
+			err := eachField(value.bytes, func(field int, value protoValue) error {
+				switch field {
+				case 1:
+					left = value.sint64()
+				case 2:
+					right = value.sint64()
+				case 3:
+					top = value.sint64()
+				case 4:
+					bottom = value.sint64()
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("invalid pbf bbox: %w", err)
+			}
+			h.Bounds(Bounds{
+				MinLat: float64(bottom) * 1e-9,
+				MinLon: float64(left) * 1e-9,
+				MaxLat: float64(top) * 1e-9,
+				MaxLon: float64(right) * 1e-9,
+			})
+		case 4: // required_features
+			if feature := string(value.bytes); !pbfSupportedFeatures[feature] {
+				return fmt.Errorf("unsupported pbf feature: %s", feature)
+			}
+		}
+		return nil
+	})
+}
+
+// primitiveBlock はPrimitiveBlockの座標の変換に使う値と文字列表
+type primitiveBlock struct {
+	strings     []string
+	granularity int64
+	latOffset   int64
+	lonOffset   int64
+}
+
+func (b *primitiveBlock) lat(value int64) float64 {
+	return float64(b.latOffset+b.granularity*value) * 1e-9
+}
+
+func (b *primitiveBlock) lon(value int64) float64 {
+	return float64(b.lonOffset+b.granularity*value) * 1e-9
+}
+
+func (b *primitiveBlock) tag(tags map[string]string, key, value uint64) (map[string]string, error) {
+	if key >= uint64(len(b.strings)) || value >= uint64(len(b.strings)) {
+		return nil, fmt.Errorf("pbf string index out of range")
+	}
+	return setTag(tags, b.strings[key], b.strings[value]), nil
+}
+
+func (b *primitiveBlock) tags(keys, values []uint64) (map[string]string, error) {
+	if len(keys) != len(values) {
+		return nil, fmt.Errorf("mismatched pbf tag keys and values")
+	}
+	var tags map[string]string
+	var err error
+	for i := range keys {
+		if tags, err = b.tag(tags, keys[i], values[i]); err != nil {
+			return nil, err
+		}
+	}
+	return tags, nil
+}
+
+func readPrimitiveBlock(data []byte, h Handler) error {
+	block := primitiveBlock{granularity: 100}
+	var groups [][]byte
+	err := eachField(data, func(field int, value protoValue) error {
+		switch field {
+		case 1:
+			return eachField(value.bytes, func(field int, value protoValue) error {
+				if field == 1 {
+					block.strings = append(block.strings, string(value.bytes))
+				}
+				return nil
+			})
+		case 2:
+			groups = append(groups, value.bytes)
+		case 17:
+			block.granularity = int64(value.varint)
+		case 19:
+			block.latOffset = int64(value.varint)
+		case 20:
+			block.lonOffset = int64(value.varint)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("invalid pbf primitive block: %w", err)
+	}
+
+	// 文字列表と座標の変換に使う値はグループより後に書かれていることがあるため、読み終えてからグループを読む
+	for _, group := range groups {
+		err := eachField(group, func(field int, value protoValue) error {
+			switch field {
+			case 1:
+				if h.Node != nil {
+					return block.readNode(value.bytes, h.Node)
+				}
+			case 2:
+				if h.Node != nil {
+					return block.readDenseNodes(value.bytes, h.Node)
+				}
+			case 3:
+				if h.Way != nil {
+					return block.readWay(value.bytes, h.Way)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *primitiveBlock) readNode(data []byte, fn func(*Node) error) error {
+	var id, lat, lon int64
+	var keys, values []uint64
+	version, visible := 0, true
+	err := eachField(data, func(field int, value protoValue) error {
+		var err error
+		switch field {
+		case 1:
+			id = value.sint64()
+		case 2:
+			keys, err = value.appendPacked(keys)
+		case 3:
+			values, err = value.appendPacked(values)
+		case 4:
+			version, visible = parseInfo(value.bytes)
+		case 8:
+			lat = value.sint64()
+		case 9:
+			lon = value.sint64()
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("invalid pbf node: %w", err)
+	}
+	if !visible {
+		return nil
+	}
+	tags, err := b.tags(keys, values)
+	if err != nil {
+		return err
+	}
+	return fn(&Node{ID: id, Version: version, Lat: b.lat(lat), Lon: b.lon(lon), Tags: tags})
+}
+
+func (b *primitiveBlock) readDenseNodes(data []byte, fn func(*Node) error) error {
+	var ids, lats, lons, keysVals []uint64
+	var versions []int
+	var visible []bool
+	err := eachField(data, func(field int, value protoValue) error {
+		var err error
+		switch field {
+		case 1:
+			ids, err = value.appendPacked(ids)
+		case 5:
+			versions, visible, err = parseDenseInfo(value.bytes)
+		case 8:
+			lats, err = value.appendPacked(lats)
+		case 9:
+			lons, err = value.appendPacked(lons)
+		case 10:
+			keysVals, err = value.appendPacked(keysVals)
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("invalid pbf dense nodes: %w", err)
+	}
+	if len(lats) != len(ids) || len(lons) != len(ids) {
+		return fmt.Errorf("invalid pbf dense nodes: mismatched lengths")
+	}
+
+	// ID・緯度・経度は前の値との差分で、タグは「キー, 値, …, 0」の並びで格納されている
+	var id, lat, lon int64
+	next := 0
+	for i := range ids {
+		id += zigzag(ids[i])
+		lat += zigzag(lats[i])
+		lon += zigzag(lons[i])
+
+		var tags map[string]string
+		for next < len(keysVals) && keysVals[next] != 0 {
+			if next+1 >= len(keysVals) {
+				return fmt.Errorf("invalid pbf dense node tags")
+			}
+			if tags, err = b.tag(tags, keysVals[next], keysVals[next+1]); err != nil {
+				return err
+			}
+			next += 2
+		}
+		next++
+
+		if i < len(visible) && !visible[i] {
+			continue
+		}
+		node := &Node{ID: id, Lat: b.lat(lat), Lon: b.lon(lon), Tags: tags}
+		if i < len(versions) {
+			node.Version = versions[i]
+		}
+		if err := fn(node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *primitiveBlock) readWay(data []byte, fn func(*Way) error) error {
+	var id int64
+	var keys, values, refs []uint64
+	version, visible := 0, true
+	err := eachField(data, func(field int, value protoValue) error {
+		var err error
+		switch field {
+		case 1:
+			id = int64(value.varint)
+		case 2:
+			keys, err = value.appendPacked(keys)
+		case 3:
+			values, err = value.appendPacked(values)
+		case 4:
+			version, visible = parseInfo(value.bytes)
+		case 8:
+			refs, err = value.appendPacked(refs)
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("invalid pbf way: %w", err)
+	}
+	if !visible {
+		return nil
+	}
+	tags, err := b.tags(keys, values)
+	if err != nil {
+		return err
+	}
+
+	way := &Way{ID: id, Version: version, Tags: tags, NodeIDs: make([]int64, 0, len(refs))}
+	var ref int64
+	for _, delta := range refs {
+		ref += zigzag(delta)
+		way.NodeIDs = append(way.NodeIDs, ref)
+	}
+	return fn(way)
+}
+
+// parseInfo はInfoのバージョンとvisible（visibleは履歴付きのファイルのみ）を返す
+func parseInfo(info []byte) (int, bool) {
+	version, visible := 0, true
+	_ = eachField(info, func(field int, value protoValue) error {
+		switch field {
+		case 1:
+			version = int(value.varint)
+		case 6:
+			visible = value.varint != 0
+		}
+		return nil
+	})
+	return version, visible
+}
+
+// parseDenseInfo はDenseInfoのバージョンとvisibleの並びを返す（バージョンは差分ではない）
+func parseDenseInfo(denseInfo []byte) ([]int, []bool, error) {
+	var versions, visibles []uint64
+	err := eachField(denseInfo, func(field int, value protoValue) error {
+		var err error
+		switch field {
+		case 1:
+			versions, err = value.appendPacked(versions)
+		case 6:
+			visibles, err = value.appendPacked(visibles)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	version := make([]int, len(versions))
+	for i, value := range versions {
+		version[i] = int(value)
+	}
+	visible := make([]bool, len(visibles))
+	for i, value := range visibles {
+		visible[i] = value != 0
+	}
+	return version, visible, nil
+}
+
+// protoValue はProtocol Buffersの1フィールドの値
+type protoValue struct {
+	wireType int
+	varint   uint64
+	bytes    []byte
+}
+
+func (v protoValue) sint64() int64 {
+	return zigzag(v.varint)
+}
+
+// appendPacked はpackedの数値の並び（packedでない場合は1つの値）を追加する
+func (v protoValue) appendPacked(values []uint64) ([]uint64, error) {
+	if v.wireType == 0 {
+		return append(values, v.varint), nil
+	}
+	data := v.bytes
+	for len(data) > 0 {
+		value, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errInvalidVarint
+		}
+		values = append(values, value)
+		data = data[n:]
+	}
+	return values, nil
+}
+
+var errInvalidVarint = errors.New("invalid varint")
+
+// eachField はメッセージのフィールドを順に読む（数値と長さ付きの値のみ扱い、固定長の値は読み飛ばす）
+func eachField(data []byte, fn func(field int, value protoValue) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errInvalidVarint
+		}
+		data = data[n:]
+
+		value := protoValue{wireType: int(key & 7)}
+		switch value.wireType {
+		case 0:
+			value.varint, n = binary.Uvarint(data)
+			if n <= 0 {
+				return errInvalidVarint
+			}
+			data = data[n:]
+		case 1:
+			if len(data) < 8 {
+				return io.ErrUnexpectedEOF
+			}
+			data = data[8:]
+			continue
+		case 2:
+			length, n := binary.Uvarint(data)
+			if n <= 0 {
+				return errInvalidVarint
+			}
+			data = data[n:]
+			if length > uint64(len(data)) {
+				return io.ErrUnexpectedEOF
+			}
+			value.bytes = data[:length]
+			data = data[length:]
+		case 5:
+			if len(data) < 4 {
+				return io.ErrUnexpectedEOF
+			}
+			data = data[4:]
+			continue
+		default:
+			return fmt.Errorf("unsupported wire type %d", value.wireType)
+		}
+		if err := fn(int(key>>3), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func zigzag(value uint64) int64 {
+	return int64(value>>1) ^ -int64(value&1)
+}
@@ -0,0 +1,303 @@
+package osm
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// pbMessage はテスト用のProtocol Buffersのメッセージを組み立てる
+type pbMessage []byte
+
+func (m pbMessage) varint(field int, value uint64) pbMessage {
+	m = binary.AppendUvarint(m, uint64(field)<<3)
+	return binary.AppendUvarint(m, value)
+}
+
+func (m pbMessage) sint(field int, value int64) pbMessage {
+	return m.varint(field, uint64(value<<1^value>>63))
+}
+
+func (m pbMessage) bytes(field int, value []byte) pbMessage {
+	m = binary.AppendUvarint(m, uint64(field)<<3|2)
+	m = binary.AppendUvarint(m, uint64(len(value)))
+	return append(m, value...)
+}
+
+func (m pbMessage) str(field int, value string) pbMessage {
+	return m.bytes(field, []byte(value))
+}
+
+func (m pbMessage) packed(field int, values ...uint64) pbMessage {
+	var data []byte
+	for _, value := range values {
+		data = binary.AppendUvarint(data, value)
+	}
+	return m.bytes(field, data)
+}
+
+func (m pbMessage) packedSint(field int, values ...int64) pbMessage {
+	encoded := make([]uint64, len(values))
+	for i, value := range values {
+		encoded[i] = uint64(value<<1 ^ value>>63)
+	}
+	return m.packed(field, encoded...)
+}
+
+// fixed32 は読み飛ばされる固定長のフィールド
+func (m pbMessage) fixed32(field int) pbMessage {
+	m = binary.AppendUvarint(m, uint64(field)<<3|5)
+	return append(m, 0, 0, 0, 0)
+}
+
+// zlibBlob はdataをzlibで圧縮したBlob
+func zlibBlob(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var compressed bytes.Buffer
+	w := zlib.NewWriter(&compressed)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return pbMessage{}.varint(2, uint64(len(data))).bytes(3, compressed.Bytes())
+}
+
+// appendFileBlock はBlobHeaderの長さ・BlobHeader・Blobの順に書き込む
+func appendFileBlock(file []byte, blobType string, blob []byte) []byte {
+	header := pbMessage{}.str(1, blobType).varint(3, uint64(len(blob)))
+	file = binary.BigEndian.AppendUint32(file, uint32(len(header)))
+	file = append(file, header...)
+	return append(file, blob...)
+}
+
+func sampleHeaderBlock() pbMessage {
+	bbox := pbMessage{}.
+		sint(1, 139_700_000_000).
+		sint(2, 139_800_000_000).
+		sint(3, 35_700_000_000).
+		sint(4, 35_600_000_000)
+	return pbMessage{}.
+		bytes(1, bbox).
+		str(4, "OsmSchema-V0.6").
+		str(4, "DenseNodes").
+		str(5, "Sort.Type_then_ID")
+}
+
+// samplePrimitiveBlock は東京駅付近のノード4つ（うち1つは削除済み）とウェイ2つ（うち1つは削除済み）
+// グループを文字列表と座標の変換に使う値より前に書いている
+func samplePrimitiveBlock() pbMessage {
+	dense := pbMessage{}.
+		packedSint(1, 1001, 1, 1).
+		bytes(5, pbMessage{}.packed(1, 3, 1, 2).packed(6, 1, 0, 1)).
+		packedSint(8, 356800000, 10, 10).
+		packedSint(9, 1397670000, 10, 10).
+		packed(10, 1, 2, 0, 0, 3, 4, 0)
+	node := pbMessage{}.
+		sint(1, 1004).
+		packed(2, 1).
+		packed(3, 5).
+		bytes(4, pbMessage{}.varint(1, 7)).
+		sint(8, 356800100).
+		sint(9, 1397670100)
+	way := pbMessage{}.
+		varint(1, 2001).
+		packed(2, 1).
+		packed(3, 5).
+		bytes(4, pbMessage{}.varint(1, 4).fixed32(2)).
+		packedSint(8, 1001, 2, -1)
+	deletedWay := pbMessage{}.
+		varint(1, 2002).
+		bytes(4, pbMessage{}.varint(1, 5).varint(6, 0)).
+		packedSint(8, 1001, 1)
+	stringTable := pbMessage{}.
+		str(1, "").
+		str(1, "highway").
+		str(1, "crossing").
+		str(1, "kerb").
+		str(1, "lowered").
+		str(1, "footway")
+	return pbMessage{}.
+		bytes(2, pbMessage{}.bytes(2, dense)).
+		bytes(2, pbMessage{}.bytes(1, node)).
+		bytes(2, pbMessage{}.bytes(3, way).bytes(3, deletedWay)).
+		bytes(1, stringTable).
+		varint(17, 100).
+		varint(19, 1_000_000)
+}
+
+func samplePBF(t *testing.T) []byte {
+	t.Helper()
+	file := appendFileBlock(nil, "OSMHeader", zlibBlob(t, sampleHeaderBlock()))
+	return appendFileBlock(file, "OSMData", zlibBlob(t, samplePrimitiveBlock()))
+}
+
+// osmElements はHandlerで受け取った要素
+type osmElements struct {
+	bounds []Bounds
+	nodes  []*Node
+	ways   []*Way
+}
+
+func (e *osmElements) handler() Handler {
+	return Handler{
+		Bounds: func(b Bounds) { e.bounds = append(e.bounds, b) },
+		Node: func(n *Node) error {
+			e.nodes = append(e.nodes, n)
+			return nil
+		},
+		Way: func(w *Way) error {
+			e.ways = append(e.ways, w)
+			return nil
+		},
+	}
+}
+
+func assertNode(t *testing.T, got *Node, want Node) {
+	t.Helper()
+	if got.ID != want.ID || got.Version != want.Version || !reflect.DeepEqual(got.Tags, want.Tags) {
+		t.Errorf("node = %+v, want %+v", *got, want)
+	}
+	if math.Abs(got.Lat-want.Lat) > 1e-9 || math.Abs(got.Lon-want.Lon) > 1e-9 {
+		t.Errorf("node %d position = (%v, %v), want (%v, %v)", got.ID, got.Lat, got.Lon, want.Lat, want.Lon)
+	}
+}
+
+func assertSampleElements(t *testing.T, got *osmElements) {
+	t.Helper()
+	if len(got.nodes) != 3 {
+		t.Fatalf("got %d nodes, want 3", len(got.nodes))
+	}
+	assertNode(t, got.nodes[0], Node{ID: 1001, Version: 3, Lat: 35.681, Lon: 139.767, Tags: map[string]string{"highway": "crossing"}})
+	assertNode(t, got.nodes[1], Node{ID: 1003, Version: 2, Lat: 35.681002, Lon: 139.767002, Tags: map[string]string{"kerb": "lowered"}})
+	assertNode(t, got.nodes[2], Node{ID: 1004, Version: 7, Lat: 35.68101, Lon: 139.76701, Tags: map[string]string{"highway": "footway"}})
+
+	wantWays := []*Way{{ID: 2001, Version: 4, NodeIDs: []int64{1001, 1003, 1002}, Tags: map[string]string{"highway": "footway"}}}
+	if !reflect.DeepEqual(got.ways, wantWays) {
+		t.Errorf("ways = %+v, want %+v", got.ways, wantWays)
+	}
+}
+
+func TestReadPBF(t *testing.T) {
+	var got osmElements
+	if err := ReadPBF(bytes.NewReader(samplePBF(t)), got.handler()); err != nil {
+		t.Fatal(err)
+	}
+	// bboxはナノ度のため、浮動小数点の誤差を許容する
+	want := Bounds{MinLat: 35.6, MinLon: 139.7, MaxLat: 35.7, MaxLon: 139.8}
+	if len(got.bounds) != 1 {
+		t.Fatalf("got %d bounds, want 1", len(got.bounds))
+	}
+	b := got.bounds[0]
+	if math.Abs(b.MinLat-want.MinLat) > 1e-9 || math.Abs(b.MinLon-want.MinLon) > 1e-9 || math.Abs(b.MaxLat-want.MaxLat) > 1e-9 || math.Abs(b.MaxLon-want.MaxLon) > 1e-9 {
+		t.Errorf("bounds = %v, want %v", b, want)
+	}
+	assertSampleElements(t, &got)
+}
+
+func TestReadPBFUncompressed(t *testing.T) {
+	file := appendFileBlock(nil, "OSMHeader", pbMessage{}.bytes(1, sampleHeaderBlock()))
+	file = appendFileBlock(file, "OSMData", pbMessage{}.bytes(1, samplePrimitiveBlock()))
+	var got osmElements
+	if err := ReadPBF(bytes.NewReader(file), got.handler()); err != nil {
+		t.Fatal(err)
+	}
+	assertSampleElements(t, &got)
+}
+
+// ノードとウェイの処理がない場合はデータのブロックを展開しない
+func TestReadPBFBoundsOnly(t *testing.T) {
+	file := appendFileBlock(nil, "OSMHeader", zlibBlob(t, sampleHeaderBlock()))
+	file = appendFileBlock(file, "OSMData", pbMessage{}.str(4, "lzma"))
+	var bounds []Bounds
+	err := ReadPBF(bytes.NewReader(file), Handler{Bounds: func(b Bounds) { bounds = append(bounds, b) }})
+	if err != nil || len(bounds) != 1 {
+		t.Errorf("ReadPBF() = %v with %d bounds, want nil with 1 bounds", err, len(bounds))
+	}
+}
+
+func TestReadPBFErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    func(t *testing.T) []byte
+		wantErr string
+	}{
+		{
+			name: "unsupported required feature",
+			file: func(t *testing.T) []byte {
+				return appendFileBlock(nil, "OSMHeader", zlibBlob(t, pbMessage{}.str(4, "HistoricalInformation")))
+			},
+			wantErr: "unsupported pbf feature: HistoricalInformation",
+		},
+		{
+			name: "unsupported compression",
+			file: func(t *testing.T) []byte {
+				return appendFileBlock(nil, "OSMData", pbMessage{}.varint(2, 10).bytes(4, []byte("lzma")))
+			},
+			wantErr: "unsupported pbf compression: lzma",
+		},
+		{
+			name: "string index out of range",
+			file: func(t *testing.T) []byte {
+				node := pbMessage{}.sint(1, 1).packed(2, 1).packed(3, 2)
+				return appendFileBlock(nil, "OSMData", zlibBlob(t, pbMessage{}.bytes(2, pbMessage{}.bytes(1, node))))
+			},
+			wantErr: "pbf string index out of range",
+		},
+		{
+			name: "blob header too large",
+			file: func(t *testing.T) []byte {
+				return binary.BigEndian.AppendUint32(nil, maxBlobHeaderSize+1)
+			},
+			wantErr: "pbf blob header too large",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got osmElements
+			err := ReadPBF(bytes.NewReader(tt.file(t)), got.handler())
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ReadPBF() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// 途中で切れたファイルでもpanicしない
+func TestReadPBFTruncated(t *testing.T) {
+	file := samplePBF(t)
+	for n := range file {
+		var got osmElements
+		_ = ReadPBF(bytes.NewReader(file[:n]), got.handler())
+	}
+	block := samplePrimitiveBlock()
+	for n := range block {
+		var got osmElements
+		_ = readPrimitiveBlock(block[:n], got.handler())
+	}
+}
+
+func TestRead(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"pbf": samplePBF(t),
+		"xml": []byte("\n  " + sampleXML),
+	} {
+		t.Run(name, func(t *testing.T) {
+			var got osmElements
+			if err := Read(bytes.NewReader(data), "", got.handler()); err != nil {
+				t.Fatal(err)
+			}
+			if len(got.bounds) != 1 || len(got.nodes) == 0 || len(got.ways) == 0 {
+				t.Errorf("got %d bounds, %d nodes and %d ways", len(got.bounds), len(got.nodes), len(got.ways))
+			}
+		})
+	}
+	if err := Read(bytes.NewReader(nil), "o5m", Handler{}); err == nil {
+		t.Errorf("Read() with an unsupported format returned no error")
+	}
+}
@@ -0,0 +1,151 @@
+package osm
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ReadXML はOSM XML（.osm）を読む
+// JOSMで削除した要素（action="delete"）と削除済みの要素（visible="false"）は読み飛ばす
+func ReadXML(r io.Reader, h Handler) error {
+	decoder := xml.NewDecoder(r)
+	var node *Node
+	var way *Way
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse osm xml: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			attrs := map[string]string{}
+			for _, attr := range t.Attr {
+				attrs[attr.Name.Local] = attr.Value
+			}
+			switch t.Name.Local {
+			case "bounds":
+				if h.Bounds != nil {
+					bounds, err := parseXMLBounds(attrs)
+					if err != nil {
+						return err
+					}
+					h.Bounds(bounds)
+				}
+			case "node":
+				if isDeletedXMLElement(attrs) {
+					if err := decoder.Skip(); err != nil {
+						return fmt.Errorf("failed to parse osm xml: %w", err)
+					}
+					continue
+				}
+				id, err := parseXMLID(attrs)
+				if err != nil {
+					return err
+				}
+				lat, err1 := strconv.ParseFloat(attrs["lat"], 64)
+				lon, err2 := strconv.ParseFloat(attrs["lon"], 64)
+				if err1 != nil || err2 != nil {
+					return fmt.Errorf("invalid coordinates of node %d", id)
+				}
+				node = &Node{ID: id, Version: parseXMLVersion(attrs), Lat: lat, Lon: lon}
+			case "way":
+				if isDeletedXMLElement(attrs) {
+					if err := decoder.Skip(); err != nil {
+						return fmt.Errorf("failed to parse osm xml: %w", err)
+					}
+					continue
+				}
+				id, err := parseXMLID(attrs)
+				if err != nil {
+					return err
+				}
+				way = &Way{ID: id, Version: parseXMLVersion(attrs)}
+			case "relation":
+				if err := decoder.Skip(); err != nil {
+					return fmt.Errorf("failed to parse osm xml: %w", err)
+				}
+			case "nd":
+				if way != nil {
+					ref, err := strconv.ParseInt(attrs["ref"], 10, 64)
+					if err != nil {
+						return fmt.Errorf("invalid node reference of way %d: %q", way.ID, attrs["ref"])
+					}
+					way.NodeIDs = append(way.NodeIDs, ref)
+				}
+			case "tag":
+				switch {
+				case node != nil:
+					node.Tags = setTag(node.Tags, attrs["k"], attrs["v"])
+				case way != nil:
+					way.Tags = setTag(way.Tags, attrs["k"], attrs["v"])
+				}
+			}
+
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "node":
+				if node != nil && h.Node != nil {
+					if err := h.Node(node); err != nil {
+						return err
+					}
+				}
+				node = nil
+			case "way":
+				if way != nil && h.Way != nil {
+					if err := h.Way(way); err != nil {
+						return err
+					}
+				}
+				way = nil
+			}
+		}
+	}
+}
+
+func isDeletedXMLElement(attrs map[string]string) bool {
+	return attrs["action"] == "delete" || attrs["visible"] == "false"
+}
+
+func parseXMLID(attrs map[string]string) (int64, error) {
+	id, err := strconv.ParseInt(attrs["id"], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid osm id: %q", attrs["id"])
+	}
+	return id, nil
+}
+
+func parseXMLVersion(attrs map[string]string) int {
+	version, _ := strconv.Atoi(attrs["version"])
+	return version
+}
+
+func parseXMLBounds(attrs map[string]string) (Bounds, error) {
+	var bounds Bounds
+	for name, value := range map[string]*float64{
+		"minlat": &bounds.MinLat,
+		"minlon": &bounds.MinLon,
+		"maxlat": &bounds.MaxLat,
+		"maxlon": &bounds.MaxLon,
+	} {
+		parsed, err := strconv.ParseFloat(attrs[name], 64)
+		if err != nil {
+			return Bounds{}, fmt.Errorf("invalid bounds: %s=%q", name, attrs[name])
+		}
+		*value = parsed
+	}
+	return bounds, nil
+}
+
+func setTag(tags map[string]string, key, value string) map[string]string {
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	tags[key] = value
+	return tags
+}
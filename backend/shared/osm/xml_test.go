@@ -0,0 +1,120 @@
+package osm
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sampleXML はJOSMで保存した抽出ファイルを想定したOSM XML
+const sampleXML = `<?xml version="1.0" encoding="UTF-8"?>
+<osm version="0.6" generator="JOSM">
+  <bounds minlat="35.6" minlon="139.7" maxlat="35.7" maxlon="139.8"/>
+  <node id="1001" version="3" lat="35.681" lon="139.767">
+    <tag k="highway" v="crossing"/>
+  </node>
+  <node id="1002" version="1" lat="35.681001" lon="139.767001" action="delete">
+    <tag k="highway" v="street_lamp"/>
+  </node>
+  <node id="1003" version="2" lat="35.681002" lon="139.767002" visible="false"/>
+  <node id="-1" lat="35.68101" lon="139.76701">
+    <tag k="kerb" v="lowered"/>
+    <tag k="kerb" v="raised"/>
+  </node>
+  <way id="2001" version="4">
+    <nd ref="1001"/>
+    <nd ref="-1"/>
+    <tag k="highway" v="footway"/>
+  </way>
+  <way id="2002" version="5" action="delete">
+    <nd ref="1001"/>
+    <nd ref="1002"/>
+  </way>
+  <relation id="3001" version="1">
+    <member type="way" ref="2001" role="outer"/>
+    <tag k="type" v="multipolygon"/>
+  </relation>
+</osm>
+`
+
+func TestReadXML(t *testing.T) {
+	var got osmElements
+	if err := ReadXML(strings.NewReader(sampleXML), got.handler()); err != nil {
+		t.Fatal(err)
+	}
+	wantBounds := []Bounds{{MinLat: 35.6, MinLon: 139.7, MaxLat: 35.7, MaxLon: 139.8}}
+	if !reflect.DeepEqual(got.bounds, wantBounds) {
+		t.Errorf("bounds = %v, want %v", got.bounds, wantBounds)
+	}
+	wantNodes := []*Node{
+		{ID: 1001, Version: 3, Lat: 35.681, Lon: 139.767, Tags: map[string]string{"highway": "crossing"}},
+		{ID: -1, Lat: 35.68101, Lon: 139.76701, Tags: map[string]string{"kerb": "raised"}},
+	}
+	if !reflect.DeepEqual(got.nodes, wantNodes) {
+		t.Errorf("nodes = %+v, want %+v", got.nodes, wantNodes)
+	}
+	wantWays := []*Way{{ID: 2001, Version: 4, NodeIDs: []int64{1001, -1}, Tags: map[string]string{"highway": "footway"}}}
+	if !reflect.DeepEqual(got.ways, wantWays) {
+		t.Errorf("ways = %+v, want %+v", got.ways, wantWays)
+	}
+}
+
+func TestReadXMLErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		xml     string
+		wantErr string
+	}{
+		{"invalid id", `<osm><node id="x" lat="0" lon="0"/></osm>`, `invalid osm id: "x"`},
+		{"invalid coordinates", `<osm><node id="1" lat="north" lon="0"/></osm>`, "invalid coordinates of node 1"},
+		{"invalid node reference", `<osm><way id="1"><nd ref=""/></way></osm>`, `invalid node reference of way 1: ""`},
+		{"invalid bounds", `<osm><bounds minlat="0" minlon="0" maxlat="1"/></osm>`, `invalid bounds: maxlon=""`},
+		{"broken xml", `<osm><node id="1" lat="0" lon="0">`, "failed to parse osm xml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got osmElements
+			err := ReadXML(strings.NewReader(tt.xml), got.handler())
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ReadXML() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// WriteChangeで書き出した変更ファイルをReadXMLで読み戻せる
+func TestWriteChangeRoundTrip(t *testing.T) {
+	change := Change{
+		Generator: "obstacle-export",
+		Create: []*Node{
+			{ID: -1, Lat: 35.6812362, Lon: 139.7671248, Tags: map[string]string{"kerb": "raised", "barrier": "kerb"}},
+		},
+		Modify: []*Way{
+			{ID: 2001, Version: 4, NodeIDs: []int64{1001, -1}, Tags: map[string]string{"highway": "footway"}},
+		},
+	}
+	var buf bytes.Buffer
+	if err := WriteChange(&buf, change); err != nil {
+		t.Fatal(err)
+	}
+	output := buf.String()
+	if !strings.HasPrefix(output, `<?xml version="1.0" encoding="UTF-8"?>`) || !strings.Contains(output, `<osmChange version="0.6" generator="obstacle-export">`) {
+		t.Errorf("unexpected header:\n%s", output)
+	}
+	// タグはキーの順に並べる
+	if strings.Index(output, `k="barrier"`) > strings.Index(output, `k="kerb"`) {
+		t.Errorf("tags are not sorted by key:\n%s", output)
+	}
+
+	var got osmElements
+	if err := ReadXML(&buf, got.handler()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.nodes, change.Create) {
+		t.Errorf("nodes = %+v, want %+v", got.nodes, change.Create)
+	}
+	if !reflect.DeepEqual(got.ways, change.Modify) {
+		t.Errorf("ways = %+v, want %+v", got.ways, change.Modify)
+	}
+}
@@ -0,0 +1,100 @@
+// Package search は障害物の説明を全文検索するための正規化と分かち書きを行う
+// 説明の多くは日本語で単語の区切りがないため、形態素解析の代わりに文字のN-gram（1文字と2文字）を使う
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Normalize は表記の揺れを吸収した文字列を返す
+// 全角英数字・記号は半角に、英字は小文字に、カタカナはひらがなにそろえる
+func Normalize(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r >= '！' && r <= '～':
+			r -= '！' - '!'
+		case r == '　':
+			r = ' '
+		case r >= 'ァ' && r <= 'ヶ':
+			r -= 'ァ' - 'ぁ'
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// Terms は検索語を空白で区切り、正規化した語を返す
+func Terms(query string) []string {
+	return strings.Fields(Normalize(query))
+}
+
+// Runs は正規化した文字列を文字・数字の連続に分ける（記号と空白は区切りとして捨てる）
+func Runs(normalized string) [][]rune {
+	var runs [][]rune
+	var run []rune
+	for _, r := range normalized {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			run = append(run, r)
+			continue
+		}
+		if len(run) > 0 {
+			runs = append(runs, run)
+			run = nil
+		}
+	}
+	if len(run) > 0 {
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+// Tokens は索引に登録するトークンと出現回数を返す
+// 1文字の検索語にも一致するよう、2文字のトークンに加えて1文字のトークンも登録する
+func Tokens(text string) map[string]int {
+	tokens := map[string]int{}
+	for _, run := range Runs(Normalize(text)) {
+		for i := range run {
+			tokens[string(run[i])]++
+			if i+1 < len(run) {
+				tokens[string(run[i:i+2])]++
+			}
+		}
+	}
+	return tokens
+}
+
+// QueryTokens は検索語（正規化済み）を含む説明がすべて持つトークンを返す
+// 2文字以上の連続は2文字のトークンのみで探し、1文字の場合はその文字で探す
+func QueryTokens(term string) []string {
+	seen := map[string]bool{}
+	var tokens []string
+	add := func(token string) {
+		if !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+	for _, run := range Runs(term) {
+		if len(run) == 1 {
+			add(string(run))
+			continue
+		}
+		for i := 0; i+1 < len(run); i++ {
+			add(string(run[i : i+2]))
+		}
+	}
+	return tokens
+}
+
+// Contains は正規化した説明が検索語（正規化済み）の文字・数字の連続をすべて含むかを返す
+// 索引のトークンがすべて一致しても、連続した文字列として含まれるとは限らないため最後に確かめる
+func Contains(normalized, term string) bool {
+	for _, run := range Runs(term) {
+		if !strings.Contains(normalized, string(run)) {
+			return false
+		}
+	}
+	return true
+}
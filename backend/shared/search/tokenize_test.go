@@ -0,0 +1,113 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"ＡＢＣ１２３", "abc123"},
+		{"段差　あり！", "段差 あり!"},
+		{"スロープ", "すろーぷ"},
+		{"ヴィラ・ヶ丘", "ゔぃら・ゖ丘"},
+		{"Ｗｉ－Ｆｉ～", "wi-fi~"},
+		{"EV Charger", "ev charger"},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.text); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTerms(t *testing.T) {
+	got := Terms(" 段差　スロープ  ＥＶ ")
+	want := []string{"段差", "すろーぷ", "ev"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Terms() = %q, want %q", got, want)
+	}
+}
+
+func TestRuns(t *testing.T) {
+	got := Runs("例: 段差(約5cm)あり")
+	want := [][]rune{[]rune("例"), []rune("段差"), []rune("約5cm"), []rune("あり")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Runs() = %q, want %q", got, want)
+	}
+}
+
+func TestTokens(t *testing.T) {
+	got := Tokens("段差、段差あり")
+	want := map[string]int{
+		"段": 2, "差": 2, "あ": 1, "り": 1,
+		"段差": 2, "差あ": 1, "あり": 1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokens() = %v, want %v", got, want)
+	}
+}
+
+func TestQueryTokens(t *testing.T) {
+	tests := []struct {
+		term string
+		want []string
+	}{
+		{"例: 段差", []string{"例", "段差"}},
+		{"段差", []string{"段差"}},
+		{"点字ブロック", []string{"点字", "字ぶ", "ぶろ", "ろっ", "っく"}},
+		{"段差 段差", []string{"段差"}},
+		{"!?", nil},
+	}
+	for _, tt := range tests {
+		if got := QueryTokens(Normalize(tt.term)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("QueryTokens(%q) = %q, want %q", tt.term, got, tt.want)
+		}
+	}
+}
+
+func TestContains(t *testing.T) {
+	tests := []struct {
+		description string
+		term        string
+		want        bool
+	}{
+		{"例えば入口に段差がある", "例: 段差", true},
+		{"入口に段差がある", "例: 段差", false},
+		{"段がある。差が大きい", "段差", false},
+		{"点字ブロックが途切れている", "ぶろっく", true},
+		{"ＥＶ充電器", "ev", true},
+		{"何もない", "!?", true},
+	}
+	for _, tt := range tests {
+		if got := Contains(Normalize(tt.description), Normalize(tt.term)); got != tt.want {
+			t.Errorf("Contains(%q, %q) = %v, want %v", tt.description, tt.term, got, tt.want)
+		}
+	}
+}
+
+// 検索語を含む説明は、検索語のトークンをすべて索引に持つ
+func TestQueryTokensAreIndexed(t *testing.T) {
+	descriptions := []string{
+		"例えば入口に段差がある",
+		"点字ブロックが途切れている",
+		"歩道の幅が狭い（約80cm）",
+	}
+	terms := []string{"例: 段差", "段", "ブロック", "80cm", "狭い"}
+	for _, description := range descriptions {
+		tokens := Tokens(description)
+		for _, term := range terms {
+			if !Contains(Normalize(description), Normalize(term)) {
+				continue
+			}
+			for _, token := range QueryTokens(Normalize(term)) {
+				if tokens[token] == 0 {
+					t.Errorf("token %q of %q is not indexed for %q", token, term, description)
+				}
+			}
+		}
+	}
+}
@@ -2,14 +2,72 @@ package util
 
 import (
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Setting struct {
 	ObstacleTable struct {
 		TableName string
 	}
+	ObstacleRevisionTable struct {
+		TableName string
+	}
+	CounterTable struct {
+		TableName string
+	}
+	OutboxTable struct {
+		TableName string
+	}
+	ConfirmationTable struct {
+		TableName string
+	}
+	CommentTable struct {
+		TableName string
+	}
+	SearchIndexTable struct {
+		TableName string
+	}
 	ObstacleImageBucket struct {
-		BucketName string
+		BucketName     string
+		URLExpiry      time.Duration
+		MaxUploadBytes int64
+	}
+	ObstacleImage struct {
+		MaxDistanceMeters float64
+	}
+	API struct {
+		RequireIfMatch bool
+	}
+	Trash struct {
+		Retention time.Duration
+	}
+	Outbox struct {
+		MaxAttempts int
+	}
+	Confirmation struct {
+		HalfLife time.Duration
+	}
+	Comment struct {
+		Moderators []string
+	}
+	Duplicate struct {
+		RadiusMeters float64
+	}
+	Import struct {
+		MaxRows int
+	}
+	Export struct {
+		MaxInlineBytes int
+		URLExpiry      time.Duration
+	}
+	Tile struct {
+		MaxAge time.Duration
+	}
+	ImageGC struct {
+		GracePeriod time.Duration
+		Quarantine  bool
 	}
 }
 
@@ -23,11 +81,133 @@ func GetSetting() *Setting {
 		setting.ObstacleTable.TableName = "dev-obstacle-table" // Default for local development
 	}
 
+	// Get Obstacle revision table name from environment
+	setting.ObstacleRevisionTable.TableName = os.Getenv("OBSTACLE_REVISION_TABLE_NAME")
+	if setting.ObstacleRevisionTable.TableName == "" {
+		setting.ObstacleRevisionTable.TableName = "dev-obstacle-revision-table" // Default for local development
+	}
+
+	// Get counter table name from environment
+	setting.CounterTable.TableName = os.Getenv("COUNTER_TABLE_NAME")
+	if setting.CounterTable.TableName == "" {
+		setting.CounterTable.TableName = "dev-counter-table" // Default for local development
+	}
+
+	// Get outbox table name from environment
+	setting.OutboxTable.TableName = os.Getenv("OBSTACLE_OUTBOX_TABLE_NAME")
+	if setting.OutboxTable.TableName == "" {
+		setting.OutboxTable.TableName = "dev-obstacle-outbox-table" // Default for local development
+	}
+
+	// Get confirmation table name from environment
+	setting.ConfirmationTable.TableName = os.Getenv("OBSTACLE_CONFIRMATION_TABLE_NAME")
+	if setting.ConfirmationTable.TableName == "" {
+		setting.ConfirmationTable.TableName = "dev-obstacle-confirmation-table" // Default for local development
+	}
+
+	// Get comment table name from environment
+	setting.CommentTable.TableName = os.Getenv("OBSTACLE_COMMENT_TABLE_NAME")
+	if setting.CommentTable.TableName == "" {
+		setting.CommentTable.TableName = "dev-obstacle-comment-table" // Default for local development
+	}
+
+	// Get search index table name from environment
+	setting.SearchIndexTable.TableName = os.Getenv("OBSTACLE_SEARCH_INDEX_TABLE_NAME")
+	if setting.SearchIndexTable.TableName == "" {
+		setting.SearchIndexTable.TableName = "dev-obstacle-search-index-table" // Default for local development
+	}
+
 	// Get Obstacle image bucket name from environment
 	setting.ObstacleImageBucket.BucketName = os.Getenv("OBSTACLE_IMAGE_BUCKET_NAME")
 	if setting.ObstacleImageBucket.BucketName == "" {
 		setting.ObstacleImageBucket.BucketName = "dev-obstacle-image-bucket" // Default for local development
 	}
 
+	// 画像の閲覧用プリサインドURLの有効期限（分）
+	setting.ObstacleImageBucket.URLExpiry = 15 * time.Minute
+	if minutes, err := strconv.Atoi(os.Getenv("OBSTACLE_IMAGE_URL_EXPIRY_MINUTES")); err == nil && minutes > 0 {
+		setting.ObstacleImageBucket.URLExpiry = time.Duration(minutes) * time.Minute
+	}
+
+	// アップロードできる画像の最大サイズ（MB）
+	setting.ObstacleImageBucket.MaxUploadBytes = 15 << 20
+	if mb, err := strconv.Atoi(os.Getenv("OBSTACLE_IMAGE_MAX_UPLOAD_MB")); err == nil && mb > 0 {
+		setting.ObstacleImageBucket.MaxUploadBytes = int64(mb) << 20
+	}
+
+	// 写真の撮影位置が障害物から離れていると判定する距離（メートル）
+	setting.ObstacleImage.MaxDistanceMeters = 100
+	if meters, err := strconv.ParseFloat(os.Getenv("OBSTACLE_IMAGE_MAX_DISTANCE_METERS"), 64); err == nil && meters > 0 {
+		setting.ObstacleImage.MaxDistanceMeters = meters
+	}
+
+	// 登録時に同じ種類の障害物を重複の候補として探す半径（メートル）
+	setting.Duplicate.RadiusMeters = 20
+	if meters, err := strconv.ParseFloat(os.Getenv("OBSTACLE_DUPLICATE_RADIUS_METERS"), 64); err == nil && meters > 0 {
+		setting.Duplicate.RadiusMeters = meters
+	}
+
+	// APIから一度に一括登録できる行数（道路へのスナップをAPI Gatewayのタイムアウト内に終えられる数）
+	setting.Import.MaxRows = 500
+	if rows, err := strconv.Atoi(os.Getenv("OBSTACLE_IMPORT_MAX_ROWS")); err == nil && rows > 0 {
+		setting.Import.MaxRows = rows
+	}
+
+	// エクスポートをレスポンスで直接返す最大サイズ（MB）。超える場合はS3に保存してダウンロードURLを返す
+	// Lambdaのレスポンスの上限（6MB）より小さくする
+	setting.Export.MaxInlineBytes = 5 << 20
+	if mb, err := strconv.Atoi(os.Getenv("OBSTACLE_EXPORT_MAX_INLINE_MB")); err == nil && mb > 0 {
+		setting.Export.MaxInlineBytes = mb << 20
+	}
+
+	// エクスポートのダウンロード用プリサインドURLの有効期限（分）
+	setting.Export.URLExpiry = 60 * time.Minute
+	if minutes, err := strconv.Atoi(os.Getenv("OBSTACLE_EXPORT_URL_EXPIRY_MINUTES")); err == nil && minutes > 0 {
+		setting.Export.URLExpiry = time.Duration(minutes) * time.Minute
+	}
+
+	// 障害物のベクトルタイルをブラウザやCDNにキャッシュさせる期間（秒）
+	setting.Tile.MaxAge = 60 * time.Second
+	if seconds, err := strconv.Atoi(os.Getenv("OBSTACLE_TILE_MAX_AGE_SECONDS")); err == nil && seconds >= 0 {
+		setting.Tile.MaxAge = time.Duration(seconds) * time.Second
+	}
+
+	// If-Matchヘッダーを必須にするかどうか（未設定の場合は指定があれば検証する）
+	setting.API.RequireIfMatch = os.Getenv("REQUIRE_IF_MATCH") == "true"
+
+	// ゴミ箱の障害物を完全削除するまでの保持期間（日数）
+	setting.Trash.Retention = 30 * 24 * time.Hour
+	if days, err := strconv.Atoi(os.Getenv("OBSTACLE_TRASH_RETENTION_DAYS")); err == nil && days > 0 {
+		setting.Trash.Retention = time.Duration(days) * 24 * time.Hour
+	}
+
+	// S3の削除などの後処理を諦めてデッドレターにするまでの試行回数
+	setting.Outbox.MaxAttempts = 8
+	if attempts, err := strconv.Atoi(os.Getenv("OBSTACLE_OUTBOX_MAX_ATTEMPTS")); err == nil && attempts > 0 {
+		setting.Outbox.MaxAttempts = attempts
+	}
+
+	// 確認の新しさによる信頼度が半分になるまでの期間（日数）
+	setting.Confirmation.HalfLife = 30 * 24 * time.Hour
+	if days, err := strconv.Atoi(os.Getenv("OBSTACLE_CONFIRMATION_HALF_LIFE_DAYS")); err == nil && days > 0 {
+		setting.Confirmation.HalfLife = time.Duration(days) * 24 * time.Hour
+	}
+
+	// 他の利用者のコメントを削除できるモデレーター（認証済みの利用者ID（Cognitoのsub）をカンマ区切りで指定する）
+	for _, moderator := range strings.Split(os.Getenv("OBSTACLE_COMMENT_MODERATORS"), ",") {
+		if moderator = strings.TrimSpace(moderator); moderator != "" {
+			setting.Comment.Moderators = append(setting.Comment.Moderators, moderator)
+		}
+	}
+
+	// 参照されていない画像を削除するまでの猶予期間（時間）。アップロード直後で登録前の画像を対象外にする
+	setting.ImageGC.GracePeriod = 24 * time.Hour
+	if hours, err := strconv.Atoi(os.Getenv("OBSTACLE_IMAGE_GC_GRACE_HOURS")); err == nil && hours > 0 {
+		setting.ImageGC.GracePeriod = time.Duration(hours) * time.Hour
+	}
+
+	// 参照されていない画像を削除せずに隔離用のプレフィックスへ移動するかどうか（deleteを指定した場合のみ削除する）
+	setting.ImageGC.Quarantine = os.Getenv("OBSTACLE_IMAGE_GC_MODE") != "delete"
+
 	return setting
 }
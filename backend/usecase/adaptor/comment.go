@@ -0,0 +1,29 @@
+package adaptor
+
+import (
+	"webhook/domain/db"
+	"webhook/usecase/output"
+)
+
+func FromDBComment(comment *db.ObstacleComment) output.ObstacleComment {
+	return output.ObstacleComment{
+		ID:                 comment.CommentID,
+		ObstacleID:         comment.ObstacleID,
+		Author:             comment.Author,
+		Body:               comment.Body,
+		CreatedAt:          comment.CreatedAt,
+		UpdatedAt:          comment.UpdatedAt,
+		EditedAt:           comment.EditedAt,
+		Deleted:            comment.IsDeleted(),
+		DeletedAt:          comment.DeletedAt,
+		RemovedByModerator: comment.RemovedByModerator,
+	}
+}
+
+func FromDBComments(comments []db.ObstacleComment) []output.ObstacleComment {
+	apiComments := make([]output.ObstacleComment, 0, len(comments))
+	for i := range comments {
+		apiComments = append(apiComments, FromDBComment(&comments[i]))
+	}
+	return apiComments
+}
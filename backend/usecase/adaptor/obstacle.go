@@ -1,38 +1,183 @@
 package adaptor
 
 import (
+	"time"
+
 	"webhook/domain/db"
+	"webhook/shared/util"
+	"webhook/usecase/input"
 	"webhook/usecase/output"
 )
 
 // Convert from API model to DB model
 func ToDBObstacle(obstacle output.Obstacle) *db.Obstacle {
 	return &db.Obstacle{
-		ID:          obstacle.ID,
-		Position:    obstacle.Position,
-		Type:        obstacle.Type,
-		Description: obstacle.Description,
-		DangerLevel: obstacle.DangerLevel,
-		Nodes:       obstacle.Nodes,
+		ID:              obstacle.ID,
+		Position:        obstacle.Position,
+		Type:            obstacle.Type,
+		Description:     obstacle.Description,
+		DangerLevel:     obstacle.DangerLevel,
+		Nodes:           obstacle.Nodes,
 		NearestDistance: obstacle.NearestDistance,
-		NoNearbyRoad:  obstacle.NoNearbyRoad,
-		ImageS3Key:  obstacle.ImageS3Key,
-		CreatedAt:   obstacle.CreatedAt,
+		NoNearbyRoad:    obstacle.NoNearbyRoad,
+		Images:          toDBImages(obstacle.Images),
+		Status:          db.ObstacleStatus(obstacle.Status),
+		CreatedAt:       obstacle.CreatedAt,
+		UpdatedAt:       obstacle.UpdatedAt,
+		Version:         obstacle.Version,
+		DeletedAt:       obstacle.DeletedAt,
 	}
 }
 
 // Convert from DB model to API model
 func FromDBObstacle(dbObstacle *db.Obstacle) output.Obstacle {
+	images := FromDBImages(dbObstacle.Images)
+	// image_s3_keyには先頭（表示順が最初）の画像を返す
+	coverImageS3Key := dbObstacle.ImageS3Key
+	if len(images) > 0 {
+		coverImageS3Key = images[0].S3Key
+	}
+
 	return output.Obstacle{
-		ID:          dbObstacle.ID,
-		Position:    dbObstacle.Position,
-		Type:        dbObstacle.Type,
-		Description: dbObstacle.Description,
-		DangerLevel: dbObstacle.DangerLevel,
-		Nodes:       dbObstacle.Nodes,
+		ID:              dbObstacle.ID,
+		Position:        dbObstacle.Position,
+		Type:            dbObstacle.Type,
+		Description:     dbObstacle.Description,
+		DangerLevel:     dbObstacle.DangerLevel,
+		Nodes:           dbObstacle.Nodes,
 		NearestDistance: dbObstacle.NearestDistance,
-		NoNearbyRoad:  dbObstacle.NoNearbyRoad,
-		ImageS3Key:  dbObstacle.ImageS3Key,
-		CreatedAt:   dbObstacle.CreatedAt,
+		NoNearbyRoad:    dbObstacle.NoNearbyRoad,
+		ImageS3Key:      coverImageS3Key,
+		Images:          images,
+		Status:          string(dbObstacle.CurrentStatus()),
+		Transitions:     fromDBTransitions(dbObstacle.Transitions),
+		CreatedAt:       dbObstacle.CreatedAt,
+		UpdatedAt:       dbObstacle.UpdatedAt,
+		Version:         dbObstacle.Version,
+		DeletedAt:       dbObstacle.DeletedAt,
+		Confirmations: output.ObstacleConfirmations{
+			StillThere:      dbObstacle.StillThereCount,
+			Gone:            dbObstacle.GoneCount,
+			LastConfirmedAt: dbObstacle.LastConfirmedAt,
+			LastGoneAt:      dbObstacle.LastGoneAt,
+		},
+		Confidence: dbObstacle.ConfirmationWeight(time.Now(), util.GetSetting().Confirmation.HalfLife),
+		MergedInto: dbObstacle.MergedInto,
+		MergedFrom: dbObstacle.MergedFrom,
+		ExternalID: dbObstacle.ExternalID,
+	}
+}
+
+func FromDBObstacles(dbObstacles []db.Obstacle) []output.Obstacle {
+	apiObstacles := make([]output.Obstacle, 0, len(dbObstacles))
+	for i := range dbObstacles {
+		apiObstacles = append(apiObstacles, FromDBObstacle(&dbObstacles[i]))
+	}
+	return apiObstacles
+}
+
+// Convert from DB revision to API revision
+func FromDBRevision(dbRevision *db.ObstacleRevision) output.ObstacleRevision {
+	revision := output.ObstacleRevision{
+		ObstacleID: dbRevision.ObstacleID,
+		RevisionID: dbRevision.RevisionID,
+		Actor:      dbRevision.Actor,
+		Source:     dbRevision.Source,
+		CreatedAt:  dbRevision.CreatedAt,
+	}
+	if dbRevision.Before != nil {
+		before := FromDBObstacle(dbRevision.Before)
+		revision.Before = &before
+	}
+	if dbRevision.After != nil {
+		after := FromDBObstacle(dbRevision.After)
+		revision.After = &after
+	}
+	return revision
+}
+
+// Convert from DB images to API images
+func FromDBImages(images []db.ObstacleImage) []output.ObstacleImage {
+	var result []output.ObstacleImage
+	for _, image := range images {
+		result = append(result, FromDBImage(&image))
+	}
+	return result
+}
+
+// Convert from DB image to API image
+func FromDBImage(image *db.ObstacleImage) output.ObstacleImage {
+	return output.ObstacleImage{
+		ID:         image.ID,
+		S3Key:      image.S3Key,
+		Caption:    image.Caption,
+		Uploader:   image.Uploader,
+		TakenAt:    image.TakenAt,
+		SortOrder:  image.SortOrder,
+		CreatedAt:  image.CreatedAt,
+		Thumbnails: fromDBImageVariants(image.Variants),
+		Metadata:   fromDBImageMetadata(image.Metadata),
+		ViewS3Key:  image.ViewS3Key(),
+	}
+}
+
+func fromDBImageMetadata(metadata *db.ObstacleImageMetadata) *output.ObstacleImageMetadata {
+	if metadata == nil {
+		return nil
+	}
+	return &output.ObstacleImageMetadata{
+		CapturedAt:      metadata.CapturedAt,
+		DistanceMeters:  metadata.DistanceMeters,
+		FarFromObstacle: metadata.FarFromObstacle,
+	}
+}
+
+func fromDBImageVariants(variants []db.ObstacleImageVariant) []output.ObstacleImageThumbnail {
+	var thumbnails []output.ObstacleImageThumbnail
+	for _, variant := range variants {
+		thumbnails = append(thumbnails, output.ObstacleImageThumbnail{
+			Width: variant.Width,
+			S3Key: variant.S3Key,
+		})
+	}
+	return thumbnails
+}
+
+func toDBImages(images []output.ObstacleImage) []db.ObstacleImage {
+	var result []db.ObstacleImage
+	for _, image := range images {
+		result = append(result, db.ObstacleImage{
+			ID:        image.ID,
+			S3Key:     image.S3Key,
+			Caption:   image.Caption,
+			Uploader:  image.Uploader,
+			TakenAt:   image.TakenAt,
+			SortOrder: image.SortOrder,
+			CreatedAt: image.CreatedAt,
+		})
+	}
+	return result
+}
+
+func fromDBTransitions(transitions []db.ObstacleTransition) []output.ObstacleTransition {
+	var result []output.ObstacleTransition
+	for _, t := range transitions {
+		result = append(result, output.ObstacleTransition{
+			From:       string(t.From),
+			To:         string(t.To),
+			Actor:      t.Actor,
+			OnBehalfOf: t.OnBehalfOf,
+			Comment:    t.Comment,
+			CreatedAt:  t.CreatedAt,
+		})
+	}
+	return result
+}
+
+// Convert from usecase audit to DB audit
+func ToDBAudit(audit input.Audit) db.Audit {
+	return db.Audit{
+		Actor:  audit.Actor,
+		Source: audit.Source,
 	}
 }
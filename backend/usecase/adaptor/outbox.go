@@ -0,0 +1,18 @@
+package adaptor
+
+import (
+	"webhook/domain/db"
+	"webhook/usecase/output"
+)
+
+func FromDBOutboxEntry(entry *db.OutboxEntry) output.OutboxEntry {
+	return output.OutboxEntry{
+		ID:         entry.ID,
+		Kind:       string(entry.Kind),
+		ObstacleID: entry.ObstacleID,
+		S3Keys:     entry.S3Keys,
+		Attempts:   entry.Attempts,
+		LastError:  entry.LastError,
+		CreatedAt:  entry.CreatedAt,
+	}
+}
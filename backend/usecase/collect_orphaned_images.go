@@ -0,0 +1,77 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"webhook/domain/db"
+	"webhook/domain/s3"
+	"webhook/usecase/input"
+	"webhook/usecase/output"
+)
+
+// imageGCPrefixes は参照されていない画像を探すS3キーのプレフィックス（元画像・処理済みの画像・サムネイル）
+var imageGCPrefixes = []string{"obstacles/", "processed/", "thumbnails/"}
+
+// quarantinePrefix は参照されていない画像の移動先のプレフィックス
+// バケットのライフサイクルルールで一定期間後に削除される
+const quarantinePrefix = "quarantine/"
+
+// CollectOrphanedImages deletes or quarantines images in the bucket that are not referenced by any obstacle
+// and are older than the grace period
+func CollectOrphanedImages(ctx context.Context, input input.ImageGC) (*output.CollectOrphanedImagesResponse, int, error) {
+	obstacleRepo, err := db.NewObstacleRepo(ctx)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	s3Repo, err := s3.NewS3Repo()
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	// 障害物の走査中に登録された画像を誤って対象にしないよう、先に候補を集めてから参照を確認する
+	threshold := time.Now().Add(-input.GracePeriod)
+	response := &output.CollectOrphanedImagesResponse{OrphanedKeys: []string{}, CollectedKeys: []string{}, FailedKeys: []string{}}
+	var candidates []string
+	for _, prefix := range imageGCPrefixes {
+		err := s3Repo.ListObjects(prefix, func(obj s3.Object) error {
+			response.ScannedCount++
+			if obj.LastModified.Before(threshold) {
+				candidates = append(candidates, obj.Key)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+	}
+
+	referenced, statusCode, err := obstacleRepo.ListImageKeys(ctx)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	for _, key := range candidates {
+		if referenced[key] {
+			continue
+		}
+		response.OrphanedKeys = append(response.OrphanedKeys, key)
+		if input.DryRun {
+			continue
+		}
+
+		if input.Quarantine {
+			err = s3Repo.MoveObject(key, quarantinePrefix+key)
+		} else {
+			err = s3Repo.DeleteObject(key)
+		}
+		if err != nil {
+			response.FailedKeys = append(response.FailedKeys, key)
+			continue
+		}
+		response.CollectedKeys = append(response.CollectedKeys, key)
+	}
+
+	return response, http.StatusOK, nil
+}
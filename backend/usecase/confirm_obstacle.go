@@ -0,0 +1,87 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"webhook/domain/db"
+	"webhook/usecase/adaptor"
+	"webhook/usecase/input"
+	"webhook/usecase/output"
+)
+
+// ConfirmObstacle records whether a user still sees an obstacle. Each user keeps one confirmation per obstacle;
+// confirming again with a different answer replaces the earlier one and the same answer is not counted twice
+func ConfirmObstacle(ctx context.Context, input input.ObstacleConfirm) (*output.ObstacleConfirmation, int, error) {
+	id, err := strconv.Atoi(input.ID)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if input.Actor == "" {
+		return nil, http.StatusBadRequest, fmt.Errorf("actor is required")
+	}
+	vote := db.ConfirmationVote(input.Vote)
+	if !vote.IsValid() {
+		return nil, http.StatusBadRequest, fmt.Errorf("unknown vote: %q", input.Vote)
+	}
+
+	confirmationRepo, err := db.NewConfirmationRepo(ctx)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	statusCode, err := saveConfirmation(ctx, confirmationRepo, id, input.Actor, vote)
+	if err != nil {
+		return nil, statusCode, err
+	}
+	if statusCode == http.StatusNotFound {
+		return nil, http.StatusNotFound, nil
+	}
+
+	ob, getStatusCode, err := getActiveObstacle(ctx, input.ID)
+	if err != nil || ob == nil {
+		return nil, getStatusCode, err
+	}
+	apiObstacle := adaptor.FromDBObstacle(ob)
+	return &output.ObstacleConfirmation{
+		ObstacleID:    id,
+		UserID:        input.Actor,
+		Vote:          string(vote),
+		Confirmations: apiObstacle.Confirmations,
+		Confidence:    apiObstacle.Confidence,
+	}, statusCode, nil
+}
+
+// saveConfirmation は利用者の確認を記録し、初めての確認の場合は201、それ以外は200を返す
+// 前回と同じ確認結果の場合は記録しない。障害物が存在しないかゴミ箱にある場合はエラーなしで404を返す
+func saveConfirmation(ctx context.Context, confirmationRepo *db.ConfirmationRepo, obstacleID int, userID string, vote db.ConfirmationVote) (int, error) {
+	previous, statusCode, err := confirmationRepo.Get(ctx, obstacleID, userID)
+	if err != nil {
+		return statusCode, err
+	}
+	if previous != nil && previous.Vote == vote {
+		return http.StatusOK, nil
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	confirmation := &db.ObstacleConfirmation{
+		ObstacleID: obstacleID,
+		UserID:     userID,
+		Vote:       vote,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if previous != nil {
+		confirmation.CreatedAt = previous.CreatedAt
+	}
+	statusCode, err = confirmationRepo.Save(ctx, confirmation, previous)
+	if err != nil || statusCode == http.StatusNotFound {
+		return statusCode, err
+	}
+	if previous == nil {
+		return http.StatusCreated, nil
+	}
+	return http.StatusOK, nil
+}
@@ -0,0 +1,87 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"webhook/domain/s3"
+	"webhook/shared/util"
+	"webhook/usecase/input"
+	"webhook/usecase/output"
+)
+
+// allowedImageContentTypes はアップロードを許可する画像のMIMEタイプと保存時の拡張子
+var allowedImageContentTypes = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/webp": "webp",
+}
+
+// CreateImageUpload checks that the obstacle exists and returns a presigned POST for uploading an image to a
+// server-generated key, limited to the allowed content types and the maximum upload size
+func CreateImageUpload(ctx context.Context, input input.ObstacleImageUpload) (*output.ImageUpload, int, error) {
+	contentType, ext, err := imageContentType(input.ContentType, input.Filename)
+	if err != nil {
+		return nil, http.StatusUnsupportedMediaType, err
+	}
+
+	ob, statusCode, err := getActiveObstacle(ctx, input.ID)
+	if err != nil || ob == nil {
+		return nil, statusCode, err
+	}
+
+	imageID, err := newImageID()
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	s3Key := newImageKey(ob.ID, imageID, ext)
+
+	s3Repo, err := s3.NewS3Repo()
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	maxBytes := util.GetSetting().ObstacleImageBucket.MaxUploadBytes
+	post, err := s3Repo.GeneratePresignedPOST(s3Key, contentType, maxBytes)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return &output.ImageUpload{
+		URL:        post.URL,
+		Fields:     post.Fields,
+		ImageS3Key: s3Key,
+		MaxBytes:   maxBytes,
+	}, http.StatusOK, nil
+}
+
+// imageContentType はアップロードする画像のMIMEタイプと拡張子を返す
+// MIMEタイプの指定がない場合はファイル名の拡張子から判定する
+func imageContentType(contentType, filename string) (string, string, error) {
+	if contentType == "" {
+		contentType = mime.TypeByExtension(strings.ToLower(path.Ext(filename)))
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", "", fmt.Errorf("unsupported content type: %q", contentType)
+	}
+	ext, ok := allowedImageContentTypes[mediaType]
+	if !ok {
+		return "", "", fmt.Errorf("unsupported content type: %q (allowed: image/jpeg, image/png, image/webp)", mediaType)
+	}
+	return mediaType, ext, nil
+}
+
+// newImageKey は画像のS3キーを生成する（クライアントのファイル名は使わない）
+func newImageKey(obstacleID int, imageID, ext string) string {
+	return fmt.Sprintf("%s%s.%s", obstacleImageKeyPrefix(obstacleID), imageID, ext)
+}
+
+// obstacleImageKeyPrefix は障害物の画像を保存するS3キーのプレフィックス
+func obstacleImageKeyPrefix(obstacleID int) string {
+	return "obstacles/" + strconv.Itoa(obstacleID) + "/"
+}
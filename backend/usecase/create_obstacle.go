@@ -2,43 +2,193 @@ package usecase
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"webhook/domain/db"
+	"webhook/shared/util"
 	"webhook/usecase/adaptor"
 	"webhook/usecase/input"
 	"webhook/usecase/output"
 )
 
-// CreateObstacle creates a new obstacle
+// DuplicateObstacleError は近くに同じ種類の障害物が登録済みのため登録しなかったことを表す
+type DuplicateObstacleError struct {
+	Candidates []output.Obstacle // 近い順
+}
+
+func (e *DuplicateObstacleError) Error() string {
+	ids := make([]string, 0, len(e.Candidates))
+	for _, candidate := range e.Candidates {
+		ids = append(ids, fmt.Sprint(candidate.ID))
+	}
+	return fmt.Sprintf("similar obstacle already reported nearby: %s", strings.Join(ids, ", "))
+}
+
+// CreateObstacle creates a new obstacle.
+// If an open obstacle of the same type has already been reported nearby, the new report is handled
+// according to input.OnDuplicate: rejected with the candidates, recorded as a confirmation of the nearest
+// candidate, or created anyway. Clients that do not specify OnDuplicate keep creating the obstacle as before
 func CreateObstacle(ctx context.Context, input input.ObstacleCreate) (*output.Obstacle, int, error) {
-	// Generate a new ID
-	// In a real application, you might use an auto-increment strategy or UUID
-	id := int(time.Now().UnixNano() % 1000000)
+	obstacleRepo, err := db.NewObstacleRepo(ctx)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	duplicate, statusCode, err := handleDuplicateReport(ctx, obstacleRepo, input)
+	if err != nil || duplicate != nil {
+		return duplicate, statusCode, err
+	}
+
+	// Allocate a new ID from the atomic counter
+	counterRepo, err := db.NewCounterRepo(ctx)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	id, statusCode, err := counterRepo.NextObstacleID(ctx)
+	if err != nil {
+		return nil, statusCode, err
+	}
 
 	// Create a new obstacle
 	obstacle := db.Obstacle{
-		ID:          id,
-		Position:    input.Position,
-		Type:        input.Type,
-		Description: input.Description,
-		DangerLevel: input.DangerLevel,
-		Nodes:       input.Nodes,
+		ID:              id,
+		Position:        input.Position,
+		Type:            input.Type,
+		Description:     input.Description,
+		DangerLevel:     input.DangerLevel,
+		Nodes:           input.Nodes,
 		NearestDistance: input.NearestDistance,
-		NoNearbyRoad:  input.NoNearbyRoad,
-		CreatedAt:   time.Now().Format(time.RFC3339),
+		NoNearbyRoad:    input.NoNearbyRoad,
+		Status:          db.ObstacleStatusReported,
+		CreatedAt:       time.Now().Format(time.RFC3339),
 	}
 
-	obstacleRepo, err := db.NewObstacleRepo(ctx)
+	// Create never overwrites an existing obstacle, even if the counter was reset
+	statusCode, err = obstacleRepo.Create(ctx, &obstacle, adaptor.ToDBAudit(input.Audit))
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	apiObstacle := adaptor.FromDBObstacle(&obstacle)
+	return &apiObstacle, http.StatusCreated, nil
+}
+
+// handleDuplicateReport は近くに同じ種類の障害物が登録済みの場合に、report.OnDuplicateに従って報告を扱う
+// 新しい障害物として登録を続ける場合はnilを返す
+func handleDuplicateReport(ctx context.Context, obstacleRepo *db.ObstacleRepo, report input.ObstacleCreate) (*output.Obstacle, int, error) {
+	switch report.OnDuplicate {
+	case "", input.DuplicatePolicyForce:
+		return nil, http.StatusOK, nil
+	case input.DuplicatePolicyReject, input.DuplicatePolicyConfirm:
+	default:
+		return nil, http.StatusBadRequest, fmt.Errorf("unknown onDuplicate: %q", report.OnDuplicate)
+	}
+
+	duplicates, statusCode, err := findDuplicateObstacles(ctx, obstacleRepo, report.Type, report.Position)
+	if err != nil || len(duplicates) == 0 {
+		return nil, statusCode, err
+	}
+	if report.OnDuplicate == input.DuplicatePolicyConfirm {
+		return confirmDuplicateObstacle(ctx, report, &duplicates[0])
+	}
+
+	apiDuplicates := adaptor.FromDBObstacles(duplicates)
+	if err := withImageURLs(apiDuplicates); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return nil, http.StatusConflict, &DuplicateObstacleError{Candidates: apiDuplicates}
+}
+
+// findDuplicateObstacles は設定された半径内にある同じ種類の未解決の障害物を近い順に返す
+// 位置から検索するGSIで、半径を囲む範囲のセルのみを読む
+func findDuplicateObstacles(ctx context.Context, obstacleRepo *db.ObstacleRepo, obstacleType int, position [2]float64) ([]db.Obstacle, int, error) {
+	radiusKm := util.GetSetting().Duplicate.RadiusMeters / 1000
+	minLat, minLon, maxLat, maxLon := radiusBounds(position, radiusKm)
+	var duplicates []db.Obstacle
+	distances := map[int]float64{}
+	for ob, err := range obstacleRepo.Within(ctx, minLat, minLon, maxLat, maxLon) {
+		if errors.Is(err, db.ErrAreaTooLarge) {
+			return nil, http.StatusInternalServerError, fmt.Errorf("duplicate radius is too large: %w", err)
+		}
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		if ob.IsDeleted() || ob.Type != obstacleType || !isOpenStatus(ob.CurrentStatus()) {
+			continue
+		}
+		distance := calculateDistance(ob.Position, position)
+		if distance <= radiusKm {
+			duplicates = append(duplicates, *ob)
+			distances[ob.ID] = distance
+		}
+	}
+	sort.SliceStable(duplicates, func(i, j int) bool {
+		return distances[duplicates[i].ID] < distances[duplicates[j].ID]
+	})
+	return duplicates, http.StatusOK, nil
+}
+
+// radiusBounds は地点から半径（キロメートル）の円を囲む範囲（南端・西端・北端・東端）を返す
+func radiusBounds(center [2]float64, radiusKm float64) (float64, float64, float64, float64) {
+	const kmPerDegree = 6371 * math.Pi / 180 // 緯度1度あたりの距離（キロメートル）
+	latDelta := radiusKm / kmPerDegree
+	lonDelta := radiusKm / (kmPerDegree * math.Max(math.Cos(center[0]*math.Pi/180), 0.01))
+	return center[0] - latDelta, center[1] - lonDelta, center[0] + latDelta, center[1] + lonDelta
+}
+
+// isOpenStatus は障害物が解消・却下されていない（重複の候補になる）状態かを返す
+func isOpenStatus(status db.ObstacleStatus) bool {
+	return status != db.ObstacleStatusResolved && status != db.ObstacleStatusRejected
+}
+
+// confirmDuplicateObstacle は新しい報告を既存の障害物への「まだある」という確認として記録する
+// 報告の説明はコメントとして残し、既存の障害物を返す
+func confirmDuplicateObstacle(ctx context.Context, report input.ObstacleCreate, duplicate *db.Obstacle) (*output.Obstacle, int, error) {
+	if report.Actor == "" {
+		return nil, http.StatusBadRequest, fmt.Errorf("actor is required to confirm obstacle %d", duplicate.ID)
+	}
+
+	confirmationRepo, err := db.NewConfirmationRepo(ctx)
 	if err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
-	statusCode, err := obstacleRepo.CreateOrUpdate(ctx, &obstacle)
+	statusCode, err := saveConfirmation(ctx, confirmationRepo, duplicate.ID, report.Actor, db.ConfirmationStillThere)
 	if err != nil {
 		return nil, statusCode, err
 	}
+	if statusCode == http.StatusNotFound {
+		return nil, http.StatusConflict, fmt.Errorf("obstacle %d was removed while confirming it", duplicate.ID)
+	}
 
-	apiObstacle := adaptor.FromDBObstacle(&obstacle)
-	return &apiObstacle, http.StatusCreated, nil
+	if body, err := commentBody(report.Description); err == nil {
+		comment, err := newObstacleComment(duplicate.ID, report.Actor, body)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		commentRepo, err := db.NewCommentRepo(ctx)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		if statusCode, err := commentRepo.Create(ctx, comment); err != nil {
+			return nil, statusCode, err
+		}
+	}
+
+	ob, statusCode, err := getActiveObstacle(ctx, fmt.Sprint(duplicate.ID))
+	if err != nil {
+		return nil, statusCode, err
+	}
+	if ob == nil {
+		return nil, http.StatusConflict, fmt.Errorf("obstacle %d was removed while confirming it", duplicate.ID)
+	}
+	apiObstacle := adaptor.FromDBObstacle(ob)
+	if err := withImageURL(&apiObstacle); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return &apiObstacle, http.StatusOK, nil
 }
@@ -0,0 +1,100 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"webhook/domain/db"
+	"webhook/shared/util"
+	"webhook/usecase/adaptor"
+	"webhook/usecase/input"
+	"webhook/usecase/output"
+)
+
+// maxCommentLength はコメント本文の最大文字数
+const maxCommentLength = 2000
+
+// CreateObstacleComment posts a comment to the thread of an obstacle.
+// The author is the actor of the request
+func CreateObstacleComment(ctx context.Context, input input.ObstacleCommentCreate) (*output.ObstacleComment, int, error) {
+	if input.Actor == "" {
+		return nil, http.StatusBadRequest, fmt.Errorf("actor is required")
+	}
+	body, err := commentBody(input.Body)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	ob, statusCode, err := getActiveObstacle(ctx, input.ID)
+	if err != nil || ob == nil {
+		return nil, statusCode, err
+	}
+
+	comment, err := newObstacleComment(ob.ID, input.Actor, body)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	commentRepo, err := db.NewCommentRepo(ctx)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	statusCode, err = commentRepo.Create(ctx, comment)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	apiComment := adaptor.FromDBComment(comment)
+	return &apiComment, http.StatusCreated, nil
+}
+
+// commentBody はコメント本文の前後の空白を取り除き、長さを検証する
+func commentBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", fmt.Errorf("body is required")
+	}
+	if utf8.RuneCountInString(body) > maxCommentLength {
+		return "", fmt.Errorf("body must be at most %d characters", maxCommentLength)
+	}
+	return body, nil
+}
+
+// newObstacleComment は現在日時で投稿するコメントを作成する
+func newObstacleComment(obstacleID int, author, body string) (*db.ObstacleComment, error) {
+	now := time.Now()
+	commentID, err := newCommentID(now)
+	if err != nil {
+		return nil, err
+	}
+	return &db.ObstacleComment{
+		ObstacleID: obstacleID,
+		CommentID:  commentID,
+		Author:     author,
+		Body:       body,
+		CreatedAt:  now.Format(time.RFC3339),
+		UpdatedAt:  now.Format(time.RFC3339),
+	}, nil
+}
+
+// newCommentID は投稿日時の順に並ぶコメントIDを生成する
+// 同時刻の投稿が重ならないよう、日時の後にランダムな値を付ける
+func newCommentID(now time.Time) (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate comment id: %w", err)
+	}
+	return now.UTC().Format("20060102T150405.000000Z") + "-" + hex.EncodeToString(b), nil
+}
+
+// isCommentModerator は操作者が他の利用者のコメントを削除できるかを返す
+func isCommentModerator(actor string) bool {
+	return actor != "" && slices.Contains(util.GetSetting().Comment.Moderators, actor)
+}
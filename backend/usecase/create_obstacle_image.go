@@ -0,0 +1,91 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"webhook/domain/db"
+	"webhook/domain/s3"
+	"webhook/shared/util"
+	"webhook/usecase/adaptor"
+	"webhook/usecase/input"
+	"webhook/usecase/output"
+)
+
+// CreateObstacleImage adds an image record to an obstacle and returns a presigned POST for uploading it
+func CreateObstacleImage(ctx context.Context, input input.ObstacleImageCreate) (*output.ObstacleImageUpload, int, error) {
+	id, err := strconv.Atoi(input.ID)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	obstacleRepo, err := db.NewObstacleRepo(ctx)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	ob, statusCode, err := obstacleRepo.Get(ctx, id)
+	if err != nil {
+		return nil, statusCode, err
+	}
+	if ob == nil || ob.IsDeleted() {
+		return nil, http.StatusNotFound, nil
+	}
+	if statusCode, err := checkExpectedVersion(ob, input.ExpectedVersion); err != nil {
+		return nil, statusCode, err
+	}
+
+	contentType, ext, err := imageContentType(input.ContentType, input.Filename)
+	if err != nil {
+		return nil, http.StatusUnsupportedMediaType, err
+	}
+	imageID, err := newImageID()
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	now := time.Now().Format(time.RFC3339)
+	image := db.ObstacleImage{
+		ID:        imageID,
+		S3Key:     newImageKey(id, imageID, ext),
+		Caption:   input.Caption,
+		Uploader:  input.Actor,
+		TakenAt:   input.TakenAt,
+		SortOrder: ob.NextImageSortOrder(),
+		CreatedAt: now,
+	}
+
+	s3Repo, err := s3.NewS3Repo()
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	post, err := s3Repo.GeneratePresignedPOST(image.S3Key, contentType, util.GetSetting().ObstacleImageBucket.MaxUploadBytes)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	ob.Images = append(ob.Images, image)
+	ob.UpdatedAt = now
+	statusCode, err = obstacleRepo.CreateOrUpdate(ctx, ob, adaptor.ToDBAudit(input.Audit))
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	return &output.ObstacleImageUpload{
+		Image:  adaptor.FromDBImage(&image),
+		URL:    post.URL,
+		Fields: post.Fields,
+	}, http.StatusCreated, nil
+}
+
+// newImageID は画像IDをランダムに生成する
+func newImageID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate image id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -2,15 +2,18 @@ package usecase
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"webhook/domain/db"
-	"webhook/domain/s3"
+	"webhook/usecase/adaptor"
 	"webhook/usecase/input"
 )
 
-// DeleteObstacle deletes an obstacle by ID
+// DeleteObstacle moves an obstacle to the trash
+// The obstacle and its image are removed permanently by PurgeDeletedObstacles after the retention period
 func DeleteObstacle(ctx context.Context, input input.ObstacleDelete) (int, error) {
 	id, err := strconv.Atoi(input.ID)
 	if err != nil {
@@ -21,15 +24,21 @@ func DeleteObstacle(ctx context.Context, input input.ObstacleDelete) (int, error
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
-	ob, _, err := obstacleRepo.Get(ctx, id)
-	if err == nil && ob != nil && ob.ImageS3Key != "" {
-		s3Repo, err := s3.NewS3Repo()
-		if err != nil {
-			return http.StatusInternalServerError, err
-		}
-		_ = s3Repo.DeleteObject(ob.ImageS3Key)
+	ob, statusCode, err := obstacleRepo.Get(ctx, id)
+	if err != nil {
+		return statusCode, err
+	}
+	if ob == nil || ob.IsDeleted() {
+		return http.StatusNotFound, fmt.Errorf("obstacle %d not found", id)
 	}
-	statusCode, err := obstacleRepo.Delete(ctx, id)
+	if statusCode, err := checkExpectedVersion(ob, input.ExpectedVersion); err != nil {
+		return statusCode, err
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	ob.DeletedAt = now
+	ob.UpdatedAt = now
+	statusCode, err = obstacleRepo.CreateOrUpdate(ctx, ob, adaptor.ToDBAudit(input.Audit))
 	if err != nil {
 		return statusCode, err
 	}
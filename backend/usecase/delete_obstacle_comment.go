@@ -0,0 +1,60 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"webhook/domain/db"
+	"webhook/usecase/input"
+)
+
+// DeleteObstacleComment deletes a comment. The author can delete their own comment and moderators can remove any comment.
+// The comment stays in the thread without its body so the conversation keeps its order
+func DeleteObstacleComment(ctx context.Context, input input.ObstacleCommentDelete) (int, error) {
+	if input.Actor == "" {
+		return http.StatusBadRequest, fmt.Errorf("actor is required")
+	}
+
+	ob, statusCode, err := getActiveObstacle(ctx, input.ID)
+	if err != nil {
+		return statusCode, err
+	}
+	if ob == nil {
+		return http.StatusNotFound, fmt.Errorf("obstacle %s not found", input.ID)
+	}
+
+	commentRepo, err := db.NewCommentRepo(ctx)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	comment, statusCode, err := commentRepo.Get(ctx, ob.ID, input.CommentID)
+	if err != nil {
+		return statusCode, err
+	}
+	if comment == nil {
+		return http.StatusNotFound, fmt.Errorf("comment %s not found", input.CommentID)
+	}
+
+	isAuthor := comment.Author == input.Actor
+	if !isAuthor && !isCommentModerator(input.Actor) {
+		return http.StatusForbidden, fmt.Errorf("only the author or a moderator can delete the comment")
+	}
+	if comment.IsDeleted() {
+		return http.StatusNoContent, nil
+	}
+
+	previousUpdatedAt := comment.UpdatedAt
+	now := time.Now().Format(time.RFC3339)
+	comment.Body = ""
+	comment.DeletedAt = now
+	comment.DeletedBy = input.Actor
+	comment.RemovedByModerator = !isAuthor
+	comment.UpdatedAt = now
+	statusCode, err = commentRepo.Update(ctx, comment, previousUpdatedAt)
+	if err != nil {
+		return statusCode, err
+	}
+	return http.StatusNoContent, nil
+}
@@ -0,0 +1,62 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"webhook/domain/db"
+	"webhook/usecase/adaptor"
+	"webhook/usecase/input"
+)
+
+// DeleteObstacleImage removes an image from an obstacle and deletes it from S3
+func DeleteObstacleImage(ctx context.Context, input input.ObstacleImageDelete) (int, error) {
+	id, err := strconv.Atoi(input.ID)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	obstacleRepo, err := db.NewObstacleRepo(ctx)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	ob, statusCode, err := obstacleRepo.Get(ctx, id)
+	if err != nil {
+		return statusCode, err
+	}
+	if ob == nil || ob.IsDeleted() {
+		return http.StatusNotFound, fmt.Errorf("obstacle %d not found", id)
+	}
+	if statusCode, err := checkExpectedVersion(ob, input.ExpectedVersion); err != nil {
+		return statusCode, err
+	}
+
+	image := ob.FindImage(input.ImageID)
+	if image == nil {
+		return http.StatusNotFound, fmt.Errorf("image %s not found", input.ImageID)
+	}
+	// S3の画像は更新と同じトランザクションでoutboxに記録し、更新の確定後に削除する
+	outbox, err := db.NewDeleteS3ObjectsEntry(ob.ID, image.S3Keys())
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	var images []db.ObstacleImage
+	for _, img := range ob.Images {
+		if img.ID != input.ImageID {
+			images = append(images, img)
+		}
+	}
+	ob.Images = images
+	ob.UpdatedAt = time.Now().Format(time.RFC3339)
+	statusCode, err = obstacleRepo.CreateOrUpdateWithOutbox(ctx, ob, adaptor.ToDBAudit(input.Audit), outbox)
+	if err != nil {
+		return statusCode, err
+	}
+	runOutbox(ctx, outbox)
+
+	return http.StatusNoContent, nil
+}
@@ -0,0 +1,95 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"webhook/domain/db"
+	"webhook/domain/s3"
+	"webhook/shared/util"
+	"webhook/usecase/input"
+	"webhook/usecase/output"
+)
+
+// exportKeyPrefix はレスポンスで返せない大きさのエクスポートを保存するS3キーのプレフィックス
+// 画像の掃除の対象外で、バケットのライフサイクルルールで削除する
+const exportKeyPrefix = "exports/"
+
+// ExportObstacles writes every obstacle matching input.Filter as GeoJSON, CSV or KML, in the filter's sort order.
+// The whole table is scanned page by page, so the export is complete regardless of its size.
+// Files too large for a Lambda response are stored in S3 and a presigned download URL is returned instead
+func ExportObstacles(ctx context.Context, input input.ObstacleExport) (*output.ObstacleExport, int, error) {
+	if input.Format == "" {
+		input.Format = exportFormatGeoJSON
+	}
+	format, ok := exportFormats[input.Format]
+	if !ok {
+		return nil, http.StatusBadRequest, fmt.Errorf("unsupported format: %q (geojson, csv or kml)", input.Format)
+	}
+
+	filter, err := newObstacleFilter(input.Filter)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	obstacleRepo, err := db.NewObstacleRepo(ctx)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	var obstacles []*db.Obstacle
+	for obstacle, err := range obstacleRepo.All(ctx) {
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		if filter.matches(obstacle) {
+			obstacles = append(obstacles, obstacle)
+		}
+	}
+	if filter.sort != "" {
+		filter.sortObstacles(obstacles)
+	}
+
+	var body bytes.Buffer
+	writer := format.NewWriter(&body)
+	for _, obstacle := range obstacles {
+		if err := writer.Write(obstacle); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	now := time.Now().UTC()
+	export := &output.ObstacleExport{
+		Count:       len(obstacles),
+		ContentType: format.ContentType,
+		FileName:    fmt.Sprintf("obstacles-%s.%s", now.Format("20060102T150405Z"), format.Extension),
+	}
+	setting := util.GetSetting().Export
+	if body.Len() <= setting.MaxInlineBytes {
+		export.Body = body.Bytes()
+		return export, http.StatusOK, nil
+	}
+
+	// 同時に行われたエクスポートが上書きし合わないよう、日時で分ける
+	key := exportKeyPrefix + now.Format("20060102T150405.000000000Z") + "/" + export.FileName
+	s3Repo, err := s3.NewS3Repo()
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if err := s3Repo.PutDownload(key, body.Bytes(), format.ContentType, export.FileName); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	s3Repo.URLExpiry = setting.URLExpiry
+	export.URL, err = s3Repo.GeneratePresignedGETURL(key)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	export.ExpiresAt = now.Add(setting.URLExpiry).Format(time.RFC3339)
+	return export, http.StatusOK, nil
+}
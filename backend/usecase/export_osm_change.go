@@ -0,0 +1,339 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"webhook/domain/db"
+	"webhook/domain/osmapi"
+	"webhook/shared/osm"
+	"webhook/usecase/input"
+	"webhook/usecase/output"
+)
+
+// OpenStreetMapへの変更の提案先
+const (
+	osmTargetWay     = "way"     // 障害物がある道路区間のウェイにタグを追加する
+	osmTargetBarrier = "barrier" // 障害物がある道路区間に障壁のノードを挿入する
+	osmTargetNode    = "node"    // 道路とつながらないノードを追加する
+)
+
+// osmMaxTagLength はOSMのタグの値の最大文字数
+const osmMaxTagLength = 255
+
+// osmChangeGenerator はosmChangeのgenerator属性
+const osmChangeGenerator = "osrm-obstacle-management"
+
+// osmProposal は障害物の種類から決めたOpenStreetMapへの変更の提案
+type osmProposal struct {
+	Target string
+	Tags   map[string]string
+}
+
+// proposeOSMTags は障害物の種類に対応するOSMのタグを返す（道路の要素として表せない種類の場合はfalse）
+// 勾配の値や向きは報告からわからないため、急な坂は確認を促すfixmeのみを提案する
+func proposeOSMTags(obstacle *db.Obstacle) (osmProposal, bool) {
+	switch obstacle.Type {
+	case db.ObstacleTypeStairs:
+		return osmProposal{osmTargetWay, map[string]string{"highway": "steps"}}, true
+	case db.ObstacleTypeNarrowRoad:
+		return osmProposal{osmTargetWay, map[string]string{"narrow": "yes"}}, true
+	case db.ObstacleTypeSteepSlope:
+		return osmProposal{osmTargetWay, map[string]string{}}, true
+	case db.ObstacleTypeVendingMachine:
+		return osmProposal{osmTargetNode, map[string]string{"amenity": "vending_machine"}}, true
+	case db.ObstacleTypeOther:
+		return osmProposal{osmTargetBarrier, map[string]string{"barrier": "yes"}}, true
+	}
+	return osmProposal{}, false
+}
+
+// osmChangeBuilder は障害物ごとの提案をosmChangeの変更内容にまとめる
+type osmChangeBuilder struct {
+	repo      osmapi.OSMAPIRepo
+	nodes     map[int64]*osm.Node  // 取得済みのノード
+	waysOf    map[int64][]*osm.Way // ノードを含むウェイ（取得済み）
+	modified  map[int64]*osm.Way   // 変更するウェイ（提案を反映済み）
+	order     []int64              // 変更するウェイの順序
+	created   []*osm.Node
+	fractions map[int64]float64 // 挿入したノードの道路区間上の位置（IDの小さいノードからの割合）
+}
+
+func newOSMChangeBuilder(repo osmapi.OSMAPIRepo) *osmChangeBuilder {
+	return &osmChangeBuilder{
+		repo:      repo,
+		nodes:     map[int64]*osm.Node{},
+		waysOf:    map[int64][]*osm.Way{},
+		modified:  map[int64]*osm.Way{},
+		fractions: map[int64]float64{},
+	}
+}
+
+// ExportOSMChange builds an osmChange (.osc) file that proposes OpenStreetMap tags for verified obstacles.
+// Tags are added to the way of the road segment in Obstacle.Nodes, barriers are inserted into that segment as
+// new nodes, and every proposal carries a fixme with the report so that local mappers review it in JOSM before
+// uploading. Obstacles imported from OpenStreetMap are skipped since they are already mapped
+func ExportOSMChange(ctx context.Context, input input.OSMChangeExport) (*output.OSMChangeExport, int, error) {
+	obstacleRepo, err := db.NewObstacleRepo(ctx)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	var obstacles []*db.Obstacle
+	for obstacle, err := range obstacleRepo.All(ctx) {
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		if len(input.IDs) > 0 && !slices.Contains(input.IDs, obstacle.ID) {
+			continue
+		}
+		if obstacle.IsDeleted() || obstacle.CurrentStatus() != db.ObstacleStatusVerified ||
+			strings.HasPrefix(obstacle.ExternalID, osmExternalIDPrefix) {
+			continue
+		}
+		obstacles = append(obstacles, obstacle)
+	}
+	sort.Slice(obstacles, func(i, j int) bool { return obstacles[i].ID < obstacles[j].ID })
+
+	builder := newOSMChangeBuilder(osmapi.NewOSMAPIRepo())
+	response := &output.OSMChangeExport{Proposals: []output.OSMChangeProposal{}}
+	for _, obstacle := range obstacles {
+		proposal := output.OSMChangeProposal{ObstacleID: obstacle.ID}
+		element, tags, err := builder.add(ctx, obstacle)
+		if err != nil {
+			proposal.Skipped = err.Error()
+			response.Skipped++
+		} else {
+			proposal.Element = element
+			proposal.Tags = tags
+		}
+		response.Proposals = append(response.Proposals, proposal)
+	}
+
+	change := builder.change()
+	response.Created = len(change.Create)
+	response.Modified = len(change.Modify)
+	var body bytes.Buffer
+	if err := osm.WriteChange(&body, change); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	response.Body = body.Bytes()
+	return response, http.StatusOK, nil
+}
+
+// add は障害物の提案を変更内容に加え、変更する要素と提案したタグを返す（提案できない場合は理由をエラーで返す）
+func (b *osmChangeBuilder) add(ctx context.Context, obstacle *db.Obstacle) (string, map[string]string, error) {
+	proposal, ok := proposeOSMTags(obstacle)
+	if !ok {
+		return "", nil, fmt.Errorf("obstacle type %d cannot be mapped on a road", obstacle.Type)
+	}
+	fixme := fmt.Sprintf("障害物報告%d: %s", obstacle.ID, obstacle.Description)
+
+	if proposal.Target == osmTargetNode {
+		node := b.newNode(obstacle.Position, proposal.Tags, fixme)
+		b.created = append(b.created, node)
+		return fmt.Sprintf("node/%d", node.ID), node.Tags, nil
+	}
+
+	if len(obstacle.Nodes) < 2 {
+		return "", nil, fmt.Errorf("no road segment is recorded")
+	}
+	// 同じ区間に挿入するノードの位置を比べられるよう、IDの小さいノードを始点にする
+	from, to := min(obstacle.Nodes[0], obstacle.Nodes[1]), max(obstacle.Nodes[0], obstacle.Nodes[1])
+	way, err := b.segmentWay(ctx, from, to)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if proposal.Target == osmTargetWay {
+		tags := map[string]string{}
+		for key, value := range proposal.Tags {
+			if way.Tags[key] != value {
+				tags[key] = value
+			}
+		}
+		if len(proposal.Tags) > 0 && len(tags) == 0 {
+			return "", nil, fmt.Errorf("way %d is already tagged", way.ID)
+		}
+		for key, value := range tags {
+			way.Tags = setOSMTag(way.Tags, key, value)
+		}
+		way.Tags = setOSMTag(way.Tags, "fixme", appendOSMFixme(way.Tags["fixme"], fixme))
+		b.markModified(way)
+		tags["fixme"] = fixme
+		return fmt.Sprintf("way/%d", way.ID), tags, nil
+	}
+
+	// 障壁は道路区間上の障害物に最も近い位置にノードとして挿入する
+	nodes, err := b.getNodes(ctx, from, to)
+	if err != nil {
+		return "", nil, err
+	}
+	fraction, position := projectOnSegment(obstacle.Position,
+		[2]float64{nodes[0].Lat, nodes[0].Lon}, [2]float64{nodes[1].Lat, nodes[1].Lon})
+	node := b.newNode(position, proposal.Tags, fixme)
+	if !b.insertNode(way, from, to, node.ID, fraction) {
+		return "", nil, fmt.Errorf("nodes %d and %d are not adjacent on way %d", from, to, way.ID)
+	}
+	b.created = append(b.created, node)
+	b.fractions[node.ID] = fraction
+	b.markModified(way)
+	return fmt.Sprintf("node/%d", node.ID), node.Tags, nil
+}
+
+func (b *osmChangeBuilder) newNode(position [2]float64, tags map[string]string, fixme string) *osm.Node {
+	node := &osm.Node{
+		ID:   -int64(len(b.created) + 1),
+		Lat:  position[0],
+		Lon:  position[1],
+		Tags: map[string]string{"fixme": truncateOSMTag(fixme)},
+	}
+	for key, value := range tags {
+		node.Tags[key] = value
+	}
+	return node
+}
+
+// segmentWay はノードfromとtoを隣り合う構成ノードに持つウェイを返す（変更中のウェイは変更後の内容を返す）
+func (b *osmChangeBuilder) segmentWay(ctx context.Context, from, to int64) (*osm.Way, error) {
+	ways, ok := b.waysOf[from]
+	if !ok {
+		var err error
+		ways, err = b.repo.GetWaysOfNode(ctx, from)
+		if err != nil {
+			return nil, err
+		}
+		b.waysOf[from] = ways
+	}
+	for _, way := range ways {
+		if modified, ok := b.modified[way.ID]; ok {
+			way = modified
+		}
+		if _, _, ok := segmentIndexes(way, from, to); ok {
+			return way, nil
+		}
+	}
+	return nil, fmt.Errorf("no way connects nodes %d and %d", from, to)
+}
+
+func (b *osmChangeBuilder) getNodes(ctx context.Context, ids ...int64) ([]*osm.Node, error) {
+	var missing []int64
+	for _, id := range ids {
+		if _, ok := b.nodes[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		nodes, err := b.repo.GetNodes(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range nodes {
+			b.nodes[node.ID] = node
+		}
+	}
+
+	result := make([]*osm.Node, 0, len(ids))
+	for _, id := range ids {
+		node, ok := b.nodes[id]
+		if !ok {
+			return nil, fmt.Errorf("node %d not found", id)
+		}
+		result = append(result, node)
+	}
+	return result, nil
+}
+
+// segmentIndexes はウェイ上で隣り合うノードfromとtoの位置を返す（挿入したノードは間にあってもよい）
+func segmentIndexes(way *osm.Way, from, to int64) (int, int, bool) {
+	for i, id := range way.NodeIDs {
+		if id != from && id != to {
+			continue
+		}
+		other := to
+		if id == to {
+			other = from
+		}
+		j := i + 1
+		for j < len(way.NodeIDs) && way.NodeIDs[j] < 0 {
+			j++
+		}
+		if j < len(way.NodeIDs) && way.NodeIDs[j] == other {
+			return i, j, true
+		}
+	}
+	return 0, 0, false
+}
+
+// insertNode はノードを道路区間に挿入する（同じ区間に挿入したノードとは区間上の位置の順に並べる）
+func (b *osmChangeBuilder) insertNode(way *osm.Way, from, to, id int64, fraction float64) bool {
+	i, j, ok := segmentIndexes(way, from, to)
+	if !ok {
+		return false
+	}
+	forward := way.NodeIDs[i] == from
+	position := i + 1
+	for position < j && (b.fractions[way.NodeIDs[position]] < fraction) == forward {
+		position++
+	}
+	way.NodeIDs = slices.Insert(way.NodeIDs, position, id)
+	return true
+}
+
+func (b *osmChangeBuilder) markModified(way *osm.Way) {
+	if _, ok := b.modified[way.ID]; !ok {
+		b.modified[way.ID] = way
+		b.order = append(b.order, way.ID)
+	}
+}
+
+func (b *osmChangeBuilder) change() osm.Change {
+	change := osm.Change{Generator: osmChangeGenerator, Create: b.created}
+	for _, id := range b.order {
+		change.Modify = append(change.Modify, b.modified[id])
+	}
+	return change
+}
+
+// projectOnSegment は地点に最も近い道路区間上の位置を、区間の始点からの割合と座標で返す
+// 既存のノードと重ならないよう、区間の両端の近くには置かない
+func projectOnSegment(position, from, to [2]float64) (float64, [2]float64) {
+	// 短い区間のため、経度を緯度に応じて縮めた平面として計算する
+	scale := math.Cos(from[0] * math.Pi / 180)
+	dx, dy := (to[1]-from[1])*scale, to[0]-from[0]
+	fraction := 0.5
+	if length := dx*dx + dy*dy; length > 0 {
+		fraction = ((position[1]-from[1])*scale*dx + (position[0]-from[0])*dy) / length
+	}
+	fraction = math.Min(math.Max(fraction, 0.05), 0.95)
+	return fraction, [2]float64{from[0] + (to[0]-from[0])*fraction, from[1] + (to[1]-from[1])*fraction}
+}
+
+func setOSMTag(tags map[string]string, key, value string) map[string]string {
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	tags[key] = value
+	return tags
+}
+
+// appendOSMFixme は同じウェイへの複数の報告をfixmeにまとめる
+func appendOSMFixme(current, fixme string) string {
+	if current == "" {
+		return truncateOSMTag(fixme)
+	}
+	return truncateOSMTag(current + "; " + fixme)
+}
+
+func truncateOSMTag(value string) string {
+	runes := []rune(value)
+	if len(runes) <= osmMaxTagLength {
+		return value
+	}
+	return string(runes[:osmMaxTagLength-1]) + "…"
+}
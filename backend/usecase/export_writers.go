@@ -0,0 +1,289 @@
+package usecase
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"webhook/domain/db"
+)
+
+// エクスポートで出力できるファイル形式
+const (
+	exportFormatGeoJSON = "geojson"
+	exportFormatCSV     = "csv"
+	exportFormatKML     = "kml"
+)
+
+// exportFormat はファイル形式ごとのContent-Type・拡張子と書き出し処理
+type exportFormat struct {
+	ContentType string
+	Extension   string
+	NewWriter   func(w io.Writer) obstacleExportWriter
+}
+
+var exportFormats = map[string]exportFormat{
+	exportFormatGeoJSON: {
+		ContentType: "application/geo+json",
+		Extension:   "geojson",
+		NewWriter:   newGeoJSONExportWriter,
+	},
+	exportFormatCSV: {
+		ContentType: "text/csv; charset=utf-8",
+		Extension:   "csv",
+		NewWriter:   newCSVExportWriter,
+	},
+	exportFormatKML: {
+		ContentType: "application/vnd.google-earth.kml+xml",
+		Extension:   "kml",
+		NewWriter:   newKMLExportWriter,
+	},
+}
+
+// obstacleExportWriter は障害物を1件ずつファイルに書き出す
+// 全件を読み込まずに書き出せるよう、ヘッダーは最初のWrite、フッターはCloseで書き出す
+type obstacleExportWriter interface {
+	Write(obstacle *db.Obstacle) error
+	Close() error
+}
+
+// exportRecord はエクスポートする障害物の属性
+// 列名は一括登録の列名とそろえ、エクスポートしたファイルをそのまま一括登録できるようにする
+type exportRecord struct {
+	ID              int     `json:"id"`
+	ExternalID      string  `json:"external_id,omitempty"`
+	Type            int     `json:"type"`
+	DangerLevel     int     `json:"danger_level"`
+	Description     string  `json:"description"`
+	Status          string  `json:"status"`
+	NoNearbyRoad    bool    `json:"no_nearby_road"`
+	NearestDistance float64 `json:"nearest_distance"`
+	Nodes           []int64 `json:"nodes"`
+	ImageCount      int     `json:"image_count"`
+	StillThere      int     `json:"still_there"`
+	Gone            int     `json:"gone"`
+	CreatedAt       string  `json:"created_at"`
+	UpdatedAt       string  `json:"updated_at,omitempty"`
+	DeletedAt       string  `json:"deleted_at,omitempty"`
+	Version         int     `json:"version"`
+}
+
+// exportColumns はCSVの列とKMLのExtendedDataの名前（緯度・経度を除く）
+var exportColumns = []string{
+	"id", "external_id", "type", "danger_level", "description", "status", "no_nearby_road", "nearest_distance",
+	"nodes", "image_count", "still_there", "gone", "created_at", "updated_at", "deleted_at", "version",
+}
+
+func newExportRecord(obstacle *db.Obstacle) exportRecord {
+	nodes := obstacle.Nodes
+	if nodes == nil {
+		nodes = []int64{}
+	}
+	return exportRecord{
+		ID:              obstacle.ID,
+		ExternalID:      obstacle.ExternalID,
+		Type:            obstacle.Type,
+		DangerLevel:     obstacle.DangerLevel,
+		Description:     obstacle.Description,
+		Status:          string(obstacle.CurrentStatus()),
+		NoNearbyRoad:    obstacle.NoNearbyRoad,
+		NearestDistance: obstacle.NearestDistance,
+		Nodes:           nodes,
+		ImageCount:      len(obstacle.Images),
+		StillThere:      obstacle.StillThereCount,
+		Gone:            obstacle.GoneCount,
+		CreatedAt:       obstacle.CreatedAt,
+		UpdatedAt:       obstacle.UpdatedAt,
+		DeletedAt:       obstacle.DeletedAt,
+		Version:         obstacle.Version,
+	}
+}
+
+// values はexportColumnsの順に属性を文字列にする（ノードIDは空白区切り）
+func (r exportRecord) values() []string {
+	nodes := make([]string, 0, len(r.Nodes))
+	for _, node := range r.Nodes {
+		nodes = append(nodes, strconv.FormatInt(node, 10))
+	}
+	return []string{
+		strconv.Itoa(r.ID),
+		r.ExternalID,
+		strconv.Itoa(r.Type),
+		strconv.Itoa(r.DangerLevel),
+		r.Description,
+		r.Status,
+		strconv.FormatBool(r.NoNearbyRoad),
+		strconv.FormatFloat(r.NearestDistance, 'f', -1, 64),
+		strings.Join(nodes, " "),
+		strconv.Itoa(r.ImageCount),
+		strconv.Itoa(r.StillThere),
+		strconv.Itoa(r.Gone),
+		r.CreatedAt,
+		r.UpdatedAt,
+		r.DeletedAt,
+		strconv.Itoa(r.Version),
+	}
+}
+
+// geoJSONExportWriter はFeatureCollectionを書き出す
+type geoJSONExportWriter struct {
+	w     io.Writer
+	count int
+}
+
+func newGeoJSONExportWriter(w io.Writer) obstacleExportWriter {
+	return &geoJSONExportWriter{w: w}
+}
+
+func (e *geoJSONExportWriter) Write(obstacle *db.Obstacle) error {
+	feature := struct {
+		Type       string       `json:"type"`
+		ID         int          `json:"id"`
+		Geometry   geoJSONPoint `json:"geometry"`
+		Properties exportRecord `json:"properties"`
+	}{
+		Type: "Feature",
+		ID:   obstacle.ID,
+		Geometry: geoJSONPoint{
+			Type:        "Point",
+			Coordinates: []float64{obstacle.Position[1], obstacle.Position[0]},
+		},
+		Properties: newExportRecord(obstacle),
+	}
+	data, err := json.Marshal(feature)
+	if err != nil {
+		return fmt.Errorf("failed to marshal obstacle %d: %w", obstacle.ID, err)
+	}
+
+	separator := ",\n"
+	if e.count == 0 {
+		separator = `{"type":"FeatureCollection","features":[` + "\n"
+	}
+	e.count++
+	if _, err := io.WriteString(e.w, separator); err != nil {
+		return err
+	}
+	_, err = e.w.Write(data)
+	return err
+}
+
+func (e *geoJSONExportWriter) Close() error {
+	if e.count == 0 {
+		_, err := io.WriteString(e.w, `{"type":"FeatureCollection","features":[]}`+"\n")
+		return err
+	}
+	_, err := io.WriteString(e.w, "\n]}\n")
+	return err
+}
+
+// csvExportWriter はlat・lonとexportColumnsの列を持つCSVを書き出す
+type csvExportWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func newCSVExportWriter(w io.Writer) obstacleExportWriter {
+	return &csvExportWriter{w: csv.NewWriter(w)}
+}
+
+func (e *csvExportWriter) writeHeader() error {
+	if e.headerWritten {
+		return nil
+	}
+	e.headerWritten = true
+	return e.w.Write(append([]string{"lat", "lon"}, exportColumns...))
+}
+
+func (e *csvExportWriter) Write(obstacle *db.Obstacle) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	position := []string{
+		strconv.FormatFloat(obstacle.Position[0], 'f', -1, 64),
+		strconv.FormatFloat(obstacle.Position[1], 'f', -1, 64),
+	}
+	return e.w.Write(append(position, newExportRecord(obstacle).values()...))
+}
+
+func (e *csvExportWriter) Close() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// kmlExportWriter は障害物ごとのPlacemarkを持つKMLを書き出す
+type kmlExportWriter struct {
+	w             io.Writer
+	headerWritten bool
+}
+
+type kmlPlacemark struct {
+	XMLName      xml.Name  `xml:"Placemark"`
+	ID           string    `xml:"id,attr"`
+	Name         string    `xml:"name"`
+	Description  string    `xml:"description,omitempty"`
+	ExtendedData []kmlData `xml:"ExtendedData>Data"`
+	Coordinates  string    `xml:"Point>coordinates"`
+}
+
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+func newKMLExportWriter(w io.Writer) obstacleExportWriter {
+	return &kmlExportWriter{w: w}
+}
+
+func (e *kmlExportWriter) writeHeader() error {
+	if e.headerWritten {
+		return nil
+	}
+	e.headerWritten = true
+	_, err := io.WriteString(e.w, xml.Header+`<kml xmlns="http://www.opengis.net/kml/2.2">`+"\n<Document>\n<name>obstacles</name>\n")
+	return err
+}
+
+func (e *kmlExportWriter) Write(obstacle *db.Obstacle) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+
+	values := newExportRecord(obstacle).values()
+	placemark := kmlPlacemark{
+		ID:          fmt.Sprintf("obstacle-%d", obstacle.ID),
+		Name:        strconv.Itoa(obstacle.ID),
+		Description: obstacle.Description,
+		// KMLの座標は「経度,緯度」の順
+		Coordinates: strconv.FormatFloat(obstacle.Position[1], 'f', -1, 64) + "," + strconv.FormatFloat(obstacle.Position[0], 'f', -1, 64),
+	}
+	for i, column := range exportColumns {
+		if values[i] != "" {
+			placemark.ExtendedData = append(placemark.ExtendedData, kmlData{Name: column, Value: values[i]})
+		}
+	}
+
+	data, err := xml.Marshal(placemark)
+	if err != nil {
+		return fmt.Errorf("failed to marshal obstacle %d: %w", obstacle.ID, err)
+	}
+	if _, err := e.w.Write(data); err != nil {
+		return err
+	}
+	_, err = io.WriteString(e.w, "\n")
+	return err
+}
+
+func (e *kmlExportWriter) Close() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(e.w, "</Document>\n</kml>\n")
+	return err
+}
@@ -28,10 +28,13 @@ func GetObstacleByID(ctx context.Context, input input.ObstacleGetByID) (*output.
 	}
 
 	// Check if the obstacle was found
-	if obstacle == nil {
+	if obstacle == nil || obstacle.IsDeleted() {
 		return nil, http.StatusNotFound, nil
 	}
 
 	apiObstacle := adaptor.FromDBObstacle(obstacle)
+	if err := withImageURL(&apiObstacle); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
 	return &apiObstacle, http.StatusOK, nil
 }
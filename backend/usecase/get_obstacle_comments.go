@@ -0,0 +1,30 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+
+	"webhook/domain/db"
+	"webhook/usecase/adaptor"
+	"webhook/usecase/input"
+	"webhook/usecase/output"
+)
+
+// GetObstacleComments returns the comment thread of an obstacle, oldest first.
+// Deleted comments stay in the thread without their body
+func GetObstacleComments(ctx context.Context, input input.ObstacleCommentGet) (*output.ListObstacleCommentResponse, int, error) {
+	ob, statusCode, err := getActiveObstacle(ctx, input.ID)
+	if err != nil || ob == nil {
+		return nil, statusCode, err
+	}
+
+	commentRepo, err := db.NewCommentRepo(ctx)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	comments, statusCode, err := commentRepo.List(ctx, ob.ID)
+	if err != nil {
+		return nil, statusCode, err
+	}
+	return &output.ListObstacleCommentResponse{Items: adaptor.FromDBComments(comments)}, http.StatusOK, nil
+}
@@ -0,0 +1,76 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"webhook/domain/db"
+	"webhook/usecase/adaptor"
+	"webhook/usecase/input"
+	"webhook/usecase/output"
+)
+
+// GetObstacleHistory retrieves the revision history of an obstacle, newest first.
+// The history of obstacles merged into it is included
+func GetObstacleHistory(ctx context.Context, input input.ObstacleHistory) (*output.ListObstacleRevisionResponse, int, error) {
+	id, err := strconv.Atoi(input.ID)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	obstacleRepo, err := db.NewObstacleRepo(ctx)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	revisionRepo, err := db.NewRevisionRepo(ctx)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	var revisions []db.ObstacleRevision
+	// 統合された障害物がさらに統合先になっている場合もたどる
+	visited := map[int]bool{}
+	pending := []int{id}
+	for len(pending) > 0 {
+		obstacleID := pending[0]
+		pending = pending[1:]
+		if visited[obstacleID] {
+			continue
+		}
+		visited[obstacleID] = true
+
+		obstacleRevisions, statusCode, err := revisionRepo.List(ctx, obstacleID)
+		if err != nil {
+			return nil, statusCode, err
+		}
+		revisions = append(revisions, *obstacleRevisions...)
+
+		ob, statusCode, err := obstacleRepo.Get(ctx, obstacleID)
+		if err != nil {
+			return nil, statusCode, err
+		}
+		switch {
+		case ob != nil:
+			pending = append(pending, ob.MergedFrom...)
+		case len(*obstacleRevisions) > 0 && (*obstacleRevisions)[0].Before != nil:
+			// 完全削除された障害物は、最新の履歴（物理削除）に残る削除前の状態から統合元をたどる
+			pending = append(pending, (*obstacleRevisions)[0].Before.MergedFrom...)
+		}
+		// 履歴も本体もない場合は存在しない障害物
+		if obstacleID == id && ob == nil && len(*obstacleRevisions) == 0 {
+			return nil, http.StatusNotFound, nil
+		}
+	}
+	sort.SliceStable(revisions, func(i, j int) bool {
+		return revisions[i].RevisionID > revisions[j].RevisionID
+	})
+
+	apiRevisions := []output.ObstacleRevision{}
+	for _, revision := range revisions {
+		apiRevisions = append(apiRevisions, adaptor.FromDBRevision(&revision))
+	}
+
+	return &output.ListObstacleRevisionResponse{Items: apiRevisions}, http.StatusOK, nil
+}
@@ -0,0 +1,68 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"webhook/domain/db"
+	"webhook/usecase/adaptor"
+	"webhook/usecase/input"
+	"webhook/usecase/output"
+)
+
+// GetObstacleImages retrieves the images of an obstacle in display order
+func GetObstacleImages(ctx context.Context, input input.ObstacleImageGet) (*output.ListObstacleImageResponse, int, error) {
+	ob, statusCode, err := getActiveObstacle(ctx, input.ID)
+	if err != nil || ob == nil {
+		return nil, statusCode, err
+	}
+
+	images := adaptor.FromDBImages(ob.Images)
+	if images == nil {
+		images = []output.ObstacleImage{}
+	}
+	if err := withImageURLsForImages(images); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return &output.ListObstacleImageResponse{Items: images}, http.StatusOK, nil
+}
+
+// GetObstacleImage retrieves a single image of an obstacle
+func GetObstacleImage(ctx context.Context, input input.ObstacleImageGet) (*output.ObstacleImage, int, error) {
+	ob, statusCode, err := getActiveObstacle(ctx, input.ID)
+	if err != nil || ob == nil {
+		return nil, statusCode, err
+	}
+
+	image := ob.FindImage(input.ImageID)
+	if image == nil {
+		return nil, http.StatusNotFound, nil
+	}
+	apiImages := []output.ObstacleImage{adaptor.FromDBImage(image)}
+	if err := withImageURLsForImages(apiImages); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return &apiImages[0], http.StatusOK, nil
+}
+
+// getActiveObstacle はゴミ箱にない障害物を取得する（見つからない場合はnilを返す）
+func getActiveObstacle(ctx context.Context, idStr string) (*db.Obstacle, int, error) {
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	obstacleRepo, err := db.NewObstacleRepo(ctx)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	ob, statusCode, err := obstacleRepo.Get(ctx, id)
+	if err != nil {
+		return nil, statusCode, err
+	}
+	if ob == nil || ob.IsDeleted() {
+		return nil, http.StatusNotFound, nil
+	}
+	return ob, http.StatusOK, nil
+}
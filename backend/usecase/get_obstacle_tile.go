@@ -0,0 +1,111 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"webhook/domain/db"
+	"webhook/shared/mvt"
+	"webhook/shared/osm"
+	"webhook/usecase/input"
+	"webhook/usecase/output"
+)
+
+// obstacleTileLayer はタイル内の障害物のレイヤー名
+const obstacleTileLayer = "obstacles"
+
+// 返すタイルのズームレベルの範囲
+// 最小のズームレベルのタイルでも、位置から検索するGSIへのQueryが上限に収まるようにする
+const (
+	minObstacleTileZoom = 7
+	maxObstacleTileZoom = 22
+)
+
+// obstacleTileBuffer はタイルの縁にかかるアイコンが切れないよう、隣のタイルから含める範囲（タイルの一辺に対する割合）
+const obstacleTileBuffer = 64.0 / mvt.DefaultExtent
+
+// GetObstacleTile encodes the obstacles inside the web mercator tile z/x/y as a Mapbox Vector Tile.
+// Each obstacle is a point in the "obstacles" layer with its ID and the type, danger_level, status and
+// has_image properties. Only the geohash cells covering the tile are read from the location index.
+// The list filters narrow the obstacles, and the tile's bounds replace the bbox filter
+func GetObstacleTile(ctx context.Context, input input.ObstacleTile) (*output.ObstacleTile, int, error) {
+	z, err := strconv.Atoi(input.Z)
+	if err != nil || z < minObstacleTileZoom || z > maxObstacleTileZoom {
+		return nil, http.StatusBadRequest, fmt.Errorf("z must be between %d and %d: %q", minObstacleTileZoom, maxObstacleTileZoom, input.Z)
+	}
+	x, err := parseTileIndex("x", input.X, z)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	y, err := parseTileIndex("y", input.Y, z)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	input.Filter.BBox = ""
+	input.Filter.Sort = ""
+	filter, err := newObstacleFilter(input.Filter)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	bounds := mvt.TileBounds(z, x, y, obstacleTileBuffer)
+	filter.bbox = &osm.Bounds{MinLat: bounds.MinLat, MinLon: bounds.MinLon, MaxLat: bounds.MaxLat, MaxLon: bounds.MaxLon}
+
+	obstacleRepo, err := db.NewObstacleRepo(ctx)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	layer := mvt.Layer{Name: obstacleTileLayer, Extent: mvt.DefaultExtent}
+	// セルはタイルより広いため、タイルの範囲で絞り込む
+	for obstacle, err := range obstacleRepo.Within(ctx, bounds.MinLat, bounds.MinLon, bounds.MaxLat, bounds.MaxLon) {
+		if errors.Is(err, db.ErrAreaTooLarge) {
+			return nil, http.StatusBadRequest, err
+		}
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		if !filter.matches(obstacle) {
+			continue
+		}
+		pixelX, pixelY := mvt.Project(obstacle.Position[0], obstacle.Position[1], z, x, y, layer.Extent)
+		layer.Features = append(layer.Features, mvt.Feature{
+			ID: uint64(obstacle.ID),
+			X:  pixelX,
+			Y:  pixelY,
+			Properties: map[string]any{
+				"type":         obstacle.Type,
+				"danger_level": obstacle.DangerLevel,
+				"status":       string(obstacle.CurrentStatus()),
+				"has_image":    len(obstacle.Images) > 0,
+			},
+		})
+	}
+	// 走査の順によらず同じ内容のタイルが同じETagになるよう、IDの順に並べる
+	sort.Slice(layer.Features, func(i, j int) bool {
+		return layer.Features[i].ID < layer.Features[j].ID
+	})
+
+	body := mvt.Encode(layer)
+	hash := sha256.Sum256(body)
+	return &output.ObstacleTile{
+		Count: len(layer.Features),
+		ETag:  fmt.Sprintf(`"%s"`, hex.EncodeToString(hash[:16])),
+		Body:  body,
+	}, http.StatusOK, nil
+}
+
+// parseTileIndex はズームレベルzのタイルの番号（0以上2^z未満）を読む
+func parseTileIndex(name, value string, z int) (int, error) {
+	index, err := strconv.Atoi(value)
+	if err != nil || index < 0 || index >= 1<<z {
+		return 0, fmt.Errorf("%s must be between 0 and %d at zoom %d: %q", name, 1<<z-1, z, value)
+	}
+	return index, nil
+}
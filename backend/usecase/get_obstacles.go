@@ -2,7 +2,12 @@ package usecase
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 
 	"webhook/domain/db"
 	"webhook/usecase/adaptor"
@@ -10,26 +15,136 @@ import (
 	"webhook/usecase/output"
 )
 
-// GetObstacles retrieves all obstacles
+// 一覧の1ページの件数
+const (
+	defaultObstacleListLimit = 100
+	maxObstacleListLimit     = 1000
+)
+
+// GetObstacles retrieves a page of obstacles matching the filter, in the requested sort order.
+// NextCursor is set when more obstacles may follow and is passed back as Cursor to get the next page
 func GetObstacles(ctx context.Context, input input.ObstacleGetAll) (*output.ListObstacleResponse, int, error) {
+	limit, err := parseListLimit(input.Limit)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	filter, err := newObstacleFilter(input)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
 	obstacleRepo, err := db.NewObstacleRepo(ctx)
 	if err != nil {
 		return nil, http.StatusInternalServerError, err
 	}
-	obstacles, statusCode, err := obstacleRepo.List(ctx)
+
+	var page []*db.Obstacle
+	var nextCursor string
+	var statusCode int
+	if filter.sort == "" {
+		page, nextCursor, statusCode, err = scanObstaclePage(ctx, obstacleRepo, filter, input.Cursor, limit)
+	} else {
+		page, nextCursor, statusCode, err = sortedObstaclePage(ctx, obstacleRepo, filter, input.Cursor, limit)
+	}
 	if err != nil {
 		return nil, statusCode, err
 	}
 
-	// Convert DB obstacles to API obstacles
 	var apiObstacles []output.Obstacle
-	for _, obstacle := range *obstacles {
-		apiObstacles = append(apiObstacles, adaptor.FromDBObstacle(&obstacle))
+	for _, obstacle := range page {
+		apiObstacles = append(apiObstacles, adaptor.FromDBObstacle(obstacle))
+	}
+	if err := withImageURLs(apiObstacles); err != nil {
+		return nil, http.StatusInternalServerError, err
 	}
 
 	response := &output.ListObstacleResponse{
-		Items: apiObstacles,
+		Items:      apiObstacles,
+		NextCursor: nextCursor,
 	}
 
 	return response, http.StatusOK, nil
 }
+
+// scanObstaclePage はテーブルの走査順に1ページ分の障害物を返す
+// 絞り込みで除いた障害物を読み飛ばすため、cursorはScanのページ境界ではなく最後に返した障害物を指す
+func scanObstaclePage(ctx context.Context, obstacleRepo *db.ObstacleRepo, filter *obstacleFilter, cursor string, limit int) ([]*db.Obstacle, string, int, error) {
+	var page []*db.Obstacle
+	for obstacle, err := range obstacleRepo.After(ctx, cursor) {
+		if errors.Is(err, db.ErrInvalidCursor) {
+			return nil, "", http.StatusBadRequest, err
+		}
+		if err != nil {
+			return nil, "", http.StatusInternalServerError, err
+		}
+		if !filter.matches(obstacle) {
+			continue
+		}
+		// 続きが1件でもある場合のみ次のページを返す
+		if len(page) == limit {
+			return page, page[len(page)-1].Cursor(), http.StatusOK, nil
+		}
+		page = append(page, obstacle)
+	}
+	return page, "", http.StatusOK, nil
+}
+
+// sortedObstacleCursor は並べ替えた一覧で最後に返した障害物の位置
+// 前のページの取得後に追加・変更された障害物があっても、重複や抜けが起きないよう値とIDで位置を表す
+type sortedObstacleCursor struct {
+	Sort string  `json:"sort"`
+	Key  float64 `json:"key"`
+	ID   int     `json:"id"`
+}
+
+// sortedObstaclePage は条件に一致する障害物をすべて並べ替え、cursorの次から1ページ分を返す
+func sortedObstaclePage(ctx context.Context, obstacleRepo *db.ObstacleRepo, filter *obstacleFilter, cursor string, limit int) ([]*db.Obstacle, string, int, error) {
+	var after *sortedObstacleCursor
+	if cursor != "" {
+		data, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err == nil {
+			err = json.Unmarshal(data, &after)
+		}
+		if err != nil || after == nil || after.Sort != filter.sort {
+			return nil, "", http.StatusBadRequest, db.ErrInvalidCursor
+		}
+	}
+
+	var matched []*db.Obstacle
+	for obstacle, err := range obstacleRepo.All(ctx) {
+		if err != nil {
+			return nil, "", http.StatusInternalServerError, err
+		}
+		if !filter.matches(obstacle) {
+			continue
+		}
+		if after != nil && !obstacleSortLess(after.Key, after.ID, filter.sortKey(obstacle), obstacle.ID) {
+			continue
+		}
+		matched = append(matched, obstacle)
+	}
+	filter.sortObstacles(matched)
+
+	if len(matched) <= limit {
+		return matched, "", http.StatusOK, nil
+	}
+	page := matched[:limit]
+	last := page[len(page)-1]
+	data, err := json.Marshal(sortedObstacleCursor{Sort: filter.sort, Key: filter.sortKey(last), ID: last.ID})
+	if err != nil {
+		return nil, "", http.StatusInternalServerError, err
+	}
+	return page, base64.RawURLEncoding.EncodeToString(data), http.StatusOK, nil
+}
+
+// parseListLimit は1ページの件数を読む（未指定の場合は既定の件数）
+func parseListLimit(value string) (int, error) {
+	if value == "" {
+		return defaultObstacleListLimit, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > maxObstacleListLimit {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxObstacleListLimit)
+	}
+	return limit, nil
+}
@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"math"
 	"net/http"
+	"time"
 	"webhook/domain/db"
 	"webhook/domain/valhalla"
+	"webhook/shared/util"
 	"webhook/usecase/adaptor"
 	"webhook/usecase/input"
 	"webhook/usecase/output"
@@ -14,6 +16,13 @@ import (
 
 // GetRouteWithObstacles はValhallaからルート情報を取得し、ルート上の障害物を検出して返す
 func GetRouteWithObstacles(ctx context.Context, request input.RouteWithObstacles) (*output.ValhallaRouteResponse, int, error) {
+	// 経路探索の前に検証し、不正なリクエストでValhallaを呼ばない
+	for _, value := range request.Statuses {
+		if !db.ObstacleStatus(value).IsValid() {
+			return nil, http.StatusBadRequest, fmt.Errorf("unknown status: %q", value)
+		}
+	}
+
 	// Valhallaからルート情報を取得
 	valhallaRepo := valhalla.NewValhallaRepo()
 	routeResponse, err := valhallaRepo.GetRoute(ctx, request)
@@ -26,20 +35,82 @@ func GetRouteWithObstacles(ctx context.Context, request input.RouteWithObstacles
 	if err != nil {
 		return nil, http.StatusInternalServerError, fmt.Errorf("failed to create obstacle repo: %w", err)
 	}
-	obstacles, statusCode, err := obstacleRepo.List(ctx)
-	if err != nil {
-		return nil, statusCode, fmt.Errorf("failed to get obstacles: %w", err)
+	var obstacles []db.Obstacle
+	for obstacle, err := range obstacleRepo.All(ctx) {
+		if err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("failed to get obstacles: %w", err)
+		}
+		obstacles = append(obstacles, *obstacle)
 	}
 
 	// ルート上の障害物を検出（パラメータに基づいて判定方法を切り替え）
-	routeObstacles := findObstaclesOnRoute(routeResponse, *obstacles, request.DetectionMethod, request.DistanceThreshold)
+	statuses := request.Statuses
+	if len(statuses) == 0 {
+		statuses = defaultRouteStatuses
+	}
+	candidates := filterObstaclesByConfidence(filterObstaclesByStatus(obstacles, statuses), request.MinConfidence)
+	routeObstacles := findObstaclesOnRoute(routeResponse, candidates, request.DetectionMethod, request.DistanceThreshold)
 
 	// 障害物情報をレスポンスに追加
-	routeResponse.Obstacles = convertObstaclesToOutput(routeObstacles)
+	apiObstacles := convertObstaclesToOutput(routeObstacles)
+	if err := withImageURLs(apiObstacles); err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("failed to sign image URLs: %w", err)
+	}
+	routeResponse.Obstacles = weightRouteObstacles(apiObstacles)
 
 	return routeResponse, http.StatusOK, nil
 }
 
+// defaultRouteStatuses はルート検出で既定の対象とする障害物の状態
+var defaultRouteStatuses = []string{string(db.ObstacleStatusVerified), string(db.ObstacleStatusInRepair)}
+
+// filterObstaclesByStatus は指定された状態の障害物のみを返す
+func filterObstaclesByStatus(obstacles []db.Obstacle, statuses []string) []db.Obstacle {
+	var filtered []db.Obstacle
+	for _, obstacle := range obstacles {
+		if obstacle.IsDeleted() {
+			continue
+		}
+		for _, status := range statuses {
+			if string(obstacle.CurrentStatus()) == status {
+				filtered = append(filtered, obstacle)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// filterObstaclesByConfidence は確認の新しさと賛否による信頼度が下限以上の障害物のみを返す
+// 長く確認されていない報告や「なくなった」が多い報告を検出の対象から外すために使う
+func filterObstaclesByConfidence(obstacles []db.Obstacle, minConfidence float64) []db.Obstacle {
+	if minConfidence <= 0 {
+		return obstacles
+	}
+	now := time.Now()
+	halfLife := util.GetSetting().Confirmation.HalfLife
+	var filtered []db.Obstacle
+	for _, obstacle := range obstacles {
+		if obstacle.ConfirmationWeight(now, halfLife) >= minConfidence {
+			filtered = append(filtered, obstacle)
+		}
+	}
+	return filtered
+}
+
+// weightRouteObstacles はルート上の障害物の危険度を、確認の新しさと賛否による信頼度で重み付けする
+// 「なくなった」が多い報告や長く確認されていない報告ほど、ルートの安全性の評価への影響を小さくする
+func weightRouteObstacles(obstacles []output.Obstacle) []output.RouteObstacle {
+	var weighted []output.RouteObstacle
+	for _, obstacle := range obstacles {
+		weighted = append(weighted, output.RouteObstacle{
+			Obstacle:            obstacle,
+			WeightedDangerLevel: float64(obstacle.DangerLevel) * obstacle.Confidence,
+		})
+	}
+	return weighted
+}
+
 // findObstaclesOnRoute はルート上にある障害物を検出する
 func findObstaclesOnRoute(routeResponse *output.ValhallaRouteResponse, obstacles []db.Obstacle, detectionMethod input.ObstacleDetectionMethod, distanceThreshold float64) []db.Obstacle {
 	var routeObstacles []db.Obstacle
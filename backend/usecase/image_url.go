@@ -0,0 +1,84 @@
+package usecase
+
+import (
+	"webhook/domain/s3"
+	"webhook/usecase/output"
+)
+
+// withImageURLs は障害物の画像に期限付きの閲覧用URL（プリサインドGET URL）を付与する
+func withImageURLs(obstacles []output.Obstacle) error {
+	s3Repo, err := s3.NewS3Repo()
+	if err != nil {
+		return err
+	}
+	for i := range obstacles {
+		if err := signObstacleImages(s3Repo, &obstacles[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withImageURL は1件の障害物の画像に閲覧用URLを付与する
+func withImageURL(obstacle *output.Obstacle) error {
+	s3Repo, err := s3.NewS3Repo()
+	if err != nil {
+		return err
+	}
+	return signObstacleImages(s3Repo, obstacle)
+}
+
+// withImageURLsForImages は画像の一覧に閲覧用URLを付与する
+func withImageURLsForImages(images []output.ObstacleImage) error {
+	s3Repo, err := s3.NewS3Repo()
+	if err != nil {
+		return err
+	}
+	for i := range images {
+		if err := signImage(s3Repo, &images[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func signObstacleImages(s3Repo *s3.S3Repo, obstacle *output.Obstacle) error {
+	for i := range obstacle.Images {
+		if err := signImage(s3Repo, &obstacle.Images[i]); err != nil {
+			return err
+		}
+	}
+	if len(obstacle.Images) > 0 {
+		cover := obstacle.Images[0]
+		obstacle.ImageURL = cover.URL
+		// 一覧・地図表示では元画像ではなく最小のサムネイルを使えるようにする
+		minWidth := 0
+		for _, thumbnail := range cover.Thumbnails {
+			if minWidth == 0 || thumbnail.Width < minWidth {
+				minWidth = thumbnail.Width
+				obstacle.ThumbnailURL = thumbnail.URL
+			}
+		}
+	}
+	return nil
+}
+
+// signImage は処理済みの画像とサムネイルに閲覧用URLを付与する
+// メタデータを取り除いていない画像にはURLを付与しない
+func signImage(s3Repo *s3.S3Repo, image *output.ObstacleImage) error {
+	if image.ViewS3Key != "" {
+		url, err := s3Repo.GeneratePresignedGETURL(image.ViewS3Key)
+		if err != nil {
+			return err
+		}
+		image.URL = url
+	}
+	for i := range image.Thumbnails {
+		url, err := s3Repo.GeneratePresignedGETURL(image.Thumbnails[i].S3Key)
+		if err != nil {
+			return err
+		}
+		image.Thumbnails[i].URL = url
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"webhook/domain/db"
+	"webhook/domain/osrm"
+	"webhook/usecase/adaptor"
+	"webhook/usecase/input"
+	"webhook/usecase/output"
+)
+
+// 一括登録での行ごとの処理内容
+const (
+	importActionCreate    = "create"
+	importActionUpdate    = "update"
+	importActionUnchanged = "unchanged"
+	importActionError     = "error"
+)
+
+// importSnapConcurrency は道路へのスナップを同時に問い合わせる数
+const importSnapConcurrency = 8
+
+// importPlan は1行分の登録内容
+type importPlan struct {
+	row      *importRow
+	action   string
+	existing *db.Obstacle // 外部IDが一致する登録済みの障害物
+	snapped  bool
+	nearest  *osrm.NearestRoad // 最寄りの道路（近くに道路がない場合はnil）
+	obstacle *db.Obstacle      // 登録・更新した障害物
+}
+
+// needsSnap は新規登録か位置が変わった行かを返す（位置が同じ場合は登録済みのスナップ結果を使う）
+func (p *importPlan) needsSnap() bool {
+	return p.action == importActionCreate ||
+		(p.action == importActionUpdate && p.existing.Position != p.row.Position)
+}
+
+// ImportObstacles registers obstacles from a GeoJSON FeatureCollection or a CSV.
+// Every row is validated and snapped to the nearest road on the server. Rows with an external ID update the
+// obstacle imported with the same ID instead of creating another one, so the same file can be imported again.
+// In dry-run mode nothing is written and the per-row report shows what would happen
+func ImportObstacles(ctx context.Context, input input.ObstacleImport) (*output.ImportObstaclesResponse, int, error) {
+	rows, err := parseImportRows(input.Format, input.Data)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if input.MaxRows > 0 && len(rows) > input.MaxRows {
+		return nil, http.StatusRequestEntityTooLarge, fmt.Errorf("too many rows: %d (at most %d rows per request)", len(rows), input.MaxRows)
+	}
+
+	obstacleRepo, err := db.NewObstacleRepo(ctx)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	plans, statusCode, err := planImport(ctx, obstacleRepo, rows)
+	if err != nil {
+		return nil, statusCode, err
+	}
+	snapImportRows(ctx, plans)
+
+	if !input.DryRun {
+		counterRepo, err := db.NewCounterRepo(ctx)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		audit := adaptor.ToDBAudit(input.Audit)
+		for i := range plans {
+			if err := applyImport(ctx, obstacleRepo, counterRepo, &plans[i], audit); err != nil {
+				plans[i].row.addError("%v", err)
+				plans[i].action = importActionError
+			}
+		}
+	}
+
+	response := &output.ImportObstaclesResponse{
+		DryRun: input.DryRun,
+		Total:  len(plans),
+		Rows:   make([]output.ImportObstacleRow, 0, len(plans)),
+	}
+	for i := range plans {
+		plan := &plans[i]
+		switch plan.action {
+		case importActionCreate:
+			response.Created++
+		case importActionUpdate:
+			response.Updated++
+		case importActionUnchanged:
+			response.Unchanged++
+		default:
+			response.Failed++
+		}
+		response.Rows = append(response.Rows, importRowResult(plan))
+	}
+	return response, http.StatusOK, nil
+}
+
+// planImport は行ごとに登録・更新・変更なしのいずれかを決める
+func planImport(ctx context.Context, obstacleRepo *db.ObstacleRepo, rows []importRow) ([]importPlan, int, error) {
+	plans := make([]importPlan, len(rows))
+	seen := map[string]int{}
+	for i := range rows {
+		row := &rows[i]
+		plan := &plans[i]
+		plan.row = row
+		if row.ExternalID != "" {
+			if first, ok := seen[row.ExternalID]; ok {
+				row.addError("external_id %q is also used in row %d", row.ExternalID, first)
+			} else {
+				seen[row.ExternalID] = row.Row
+			}
+		}
+		if len(row.Errors) > 0 {
+			plan.action = importActionError
+			continue
+		}
+
+		plan.action = importActionCreate
+		if row.ExternalID == "" {
+			continue
+		}
+		existing, statusCode, err := obstacleRepo.GetByExternalID(ctx, row.ExternalID)
+		if err != nil {
+			return nil, statusCode, err
+		}
+		if existing == nil {
+			continue
+		}
+		if existing.IsDeleted() {
+			row.addError("obstacle %d with external_id %q is in the trash", existing.ID, row.ExternalID)
+			plan.action = importActionError
+			continue
+		}
+		plan.existing = existing
+		plan.action = importActionUnchanged
+		if existing.Position != row.Position || existing.Type != row.Type || existing.DangerLevel != row.DangerLevel ||
+			existing.Description != row.Description || existing.NoNearbyRoad != row.NoNearbyRoad {
+			plan.action = importActionUpdate
+		}
+	}
+	return plans, http.StatusOK, nil
+}
+
+// snapImportRows は登録する位置の最寄りの道路を並行して問い合わせる
+// 問い合わせに失敗した行はエラーとして登録しない
+func snapImportRows(ctx context.Context, plans []importPlan) {
+	osrmRepo := osrm.NewOSRMRepo()
+	semaphore := make(chan struct{}, importSnapConcurrency)
+	var wg sync.WaitGroup
+	for i := range plans {
+		plan := &plans[i]
+		if !plan.needsSnap() {
+			continue
+		}
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			nearest, err := osrmRepo.Nearest(ctx, plan.row.Position)
+			if err != nil {
+				plan.row.addError("failed to snap to a road: %v", err)
+				plan.action = importActionError
+				return
+			}
+			plan.snapped = true
+			plan.nearest = nearest
+		}()
+	}
+	wg.Wait()
+}
+
+// applyImport は1行分の障害物を登録・更新する
+func applyImport(ctx context.Context, obstacleRepo *db.ObstacleRepo, counterRepo *db.CounterRepo, plan *importPlan, audit db.Audit) error {
+	row := plan.row
+	now := time.Now().Format(time.RFC3339)
+	switch plan.action {
+	case importActionCreate:
+		id, _, err := counterRepo.NextObstacleID(ctx)
+		if err != nil {
+			return err
+		}
+		obstacle := &db.Obstacle{
+			ID:         id,
+			ExternalID: row.ExternalID,
+			Status:     db.ObstacleStatusReported,
+			CreatedAt:  now,
+		}
+		applyImportRow(obstacle, plan)
+		if _, err := obstacleRepo.Create(ctx, obstacle, audit); err != nil {
+			return err
+		}
+		plan.obstacle = obstacle
+
+	case importActionUpdate:
+		obstacle := plan.existing
+		applyImportRow(obstacle, plan)
+		obstacle.UpdatedAt = now
+		if _, err := obstacleRepo.CreateOrUpdate(ctx, obstacle, audit); err != nil {
+			return err
+		}
+		plan.obstacle = obstacle
+	}
+	return nil
+}
+
+// applyImportRow は行の内容とスナップの結果を障害物に反映する
+func applyImportRow(obstacle *db.Obstacle, plan *importPlan) {
+	row := plan.row
+	obstacle.Position = row.Position
+	obstacle.Type = row.Type
+	obstacle.DangerLevel = row.DangerLevel
+	obstacle.Description = row.Description
+	obstacle.NoNearbyRoad = row.NoNearbyRoad
+	if plan.snapped {
+		obstacle.Nodes = nil
+		obstacle.NearestDistance = 0
+		if plan.nearest != nil {
+			obstacle.Nodes = plan.nearest.Nodes
+			obstacle.NearestDistance = plan.nearest.Distance
+		}
+	}
+}
+
+func importRowResult(plan *importPlan) output.ImportObstacleRow {
+	result := output.ImportObstacleRow{
+		Row:        plan.row.Row,
+		ExternalID: plan.row.ExternalID,
+		Action:     plan.action,
+		Errors:     plan.row.Errors,
+	}
+	if plan.action == importActionError {
+		return result
+	}
+
+	switch {
+	case plan.obstacle != nil:
+		result.ObstacleID = plan.obstacle.ID
+	case plan.existing != nil:
+		result.ObstacleID = plan.existing.ID
+	}
+	switch {
+	case plan.snapped && plan.nearest != nil:
+		result.Nodes = plan.nearest.Nodes
+		result.NearestDistance = &plan.nearest.Distance
+	case !plan.snapped && plan.existing != nil:
+		result.Nodes = plan.existing.Nodes
+		result.NearestDistance = &plan.existing.NearestDistance
+	}
+	return result
+}
@@ -0,0 +1,295 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"webhook/domain/db"
+	"webhook/domain/osrm"
+	"webhook/usecase/adaptor"
+	"webhook/usecase/input"
+	"webhook/usecase/output"
+)
+
+// osmExternalIDPrefix はOpenStreetMapから取り込んだ障害物の外部IDの接頭辞
+const osmExternalIDPrefix = "osm:"
+
+// OpenStreetMapからの取り込みで記録する状態遷移のコメント
+const (
+	osmImportComment = "OpenStreetMapから取り込み"
+	osmRetireComment = "OpenStreetMapの抽出ファイルからなくなったため解消"
+	osmReopenComment = "OpenStreetMapの抽出ファイルに再び含まれたため再開"
+)
+
+// OpenStreetMapからの取り込みでの障害物ごとの処理内容（一括登録の処理内容に加えて使う）
+const (
+	importActionReopen = "reopen"
+	importActionRetire = "retire"
+	importActionSkip   = "skip"
+)
+
+// ImportOSM imports accessibility-relevant features (steps, barriers, raised kerbs, steep or unpaved ways, ...)
+// from an OpenStreetMap XML or PBF extract as verified obstacles.
+// Obstacles are keyed by their OSM element, so re-importing a newer extract updates them in place. New and moved
+// obstacles are snapped to the nearest road in the same way as ImportObstacles. With input.Retire, previously
+// imported obstacles inside the extract's bounds whose feature is gone are resolved.
+// Obstacles moved to the trash or rejected by a moderator are left untouched
+func ImportOSM(ctx context.Context, input input.OSMImport) (*output.ImportOSMResponse, int, error) {
+	if input.Actor == "" {
+		return nil, http.StatusBadRequest, fmt.Errorf("actor is required")
+	}
+	features, bounds, err := readOSMFeatures(input.Format, input.Data)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	obstacleRepo, err := db.NewObstacleRepo(ctx)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	// 取り込み済みの障害物（ゴミ箱を含む）
+	imported := map[string]*db.Obstacle{}
+	for obstacle, err := range obstacleRepo.All(ctx) {
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		if strings.HasPrefix(obstacle.ExternalID, osmExternalIDPrefix) {
+			imported[obstacle.ExternalID] = obstacle
+		}
+	}
+
+	var counterRepo *db.CounterRepo
+	if !input.DryRun {
+		if counterRepo, err = db.NewCounterRepo(ctx); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+	}
+	audit := adaptor.ToDBAudit(input.Audit)
+	now := time.Now().Format(time.RFC3339)
+	response := &output.ImportOSMResponse{
+		DryRun:   input.DryRun,
+		Features: len(features),
+		Changes:  []output.ImportOSMChange{},
+	}
+	record := func(change output.ImportOSMChange) {
+		switch change.Action {
+		case importActionCreate:
+			response.Created++
+		case importActionUpdate:
+			response.Updated++
+		case importActionReopen:
+			response.Reopened++
+		case importActionUnchanged:
+			response.Unchanged++
+			return
+		case importActionRetire:
+			response.Retired++
+		case importActionSkip:
+			response.Skipped++
+		default:
+			response.Failed++
+		}
+		response.Changes = append(response.Changes, change)
+	}
+
+	seen := map[string]bool{}
+	var plans []osmPlan
+	for i := range features {
+		feature := &features[i]
+		if seen[feature.ExternalID] {
+			continue
+		}
+		seen[feature.ExternalID] = true
+
+		action, obstacle, snap := planOSMFeature(feature, imported[feature.ExternalID], input.Actor, now)
+		plans = append(plans, osmPlan{feature: feature, action: action, obstacle: obstacle, snap: snap})
+	}
+	snapOSMPlans(ctx, plans)
+
+	for i := range plans {
+		plan := &plans[i]
+		change := output.ImportOSMChange{ExternalID: plan.feature.ExternalID, Action: plan.action, Tag: plan.feature.Tag}
+		if existing := imported[plan.feature.ExternalID]; existing != nil {
+			change.ObstacleID = existing.ID
+		}
+		switch {
+		case plan.snapErr != nil:
+			change.Action = importActionError
+			change.Error = fmt.Sprintf("failed to snap to a road: %v", plan.snapErr)
+		case !input.DryRun:
+			if err := saveOSMObstacle(ctx, obstacleRepo, counterRepo, plan.action, plan.obstacle, audit); err != nil {
+				change.Action = importActionError
+				change.Error = err.Error()
+			} else if plan.obstacle != nil {
+				change.ObstacleID = plan.obstacle.ID
+			}
+		}
+		record(change)
+	}
+
+	if input.Retire {
+		externalIDs := make([]string, 0, len(imported))
+		for externalID := range imported {
+			externalIDs = append(externalIDs, externalID)
+		}
+		sort.Strings(externalIDs)
+
+		for _, externalID := range externalIDs {
+			obstacle := imported[externalID]
+			if seen[externalID] || obstacle.IsDeleted() || !bounds.Contains(obstacle.Position) ||
+				!canTransition(obstacle.CurrentStatus(), db.ObstacleStatusResolved) {
+				continue
+			}
+			obstacle.Transitions = append(obstacle.Transitions, db.ObstacleTransition{
+				From:      obstacle.CurrentStatus(),
+				To:        db.ObstacleStatusResolved,
+				Actor:     input.Actor,
+				Comment:   osmRetireComment,
+				CreatedAt: now,
+			})
+			obstacle.Status = db.ObstacleStatusResolved
+			obstacle.UpdatedAt = now
+
+			change := output.ImportOSMChange{ExternalID: externalID, Action: importActionRetire, ObstacleID: obstacle.ID}
+			if !input.DryRun {
+				if err := saveOSMObstacle(ctx, obstacleRepo, counterRepo, importActionRetire, obstacle, audit); err != nil {
+					change.Action = importActionError
+					change.Error = err.Error()
+				}
+			}
+			record(change)
+		}
+	}
+	return response, http.StatusOK, nil
+}
+
+// osmPlan は地物1つ分の処理内容
+type osmPlan struct {
+	feature  *osmFeature
+	action   string
+	obstacle *db.Obstacle // 登録・更新する障害物
+	snap     bool         // 道路へのスナップが必要か
+	snapErr  error
+}
+
+// planOSMFeature は地物に対応する障害物の処理内容と、登録・更新する障害物、道路へのスナップが必要かを返す
+func planOSMFeature(feature *osmFeature, existing *db.Obstacle, actor, now string) (string, *db.Obstacle, bool) {
+	if existing == nil {
+		return importActionCreate, &db.Obstacle{
+			Position:    feature.Position,
+			Type:        feature.Type,
+			Description: feature.Description,
+			DangerLevel: feature.DangerLevel,
+			ExternalID:  feature.ExternalID,
+			// OpenStreetMapで確認されている地物のため、確認済みとして登録する
+			Status: db.ObstacleStatusVerified,
+			Transitions: []db.ObstacleTransition{{
+				From:      db.ObstacleStatusReported,
+				To:        db.ObstacleStatusVerified,
+				Actor:     actor,
+				Comment:   osmImportComment,
+				CreatedAt: now,
+			}},
+			CreatedAt: now,
+		}, true
+	}
+	// ゴミ箱に移動・却下された障害物は管理者の判断を優先して変更しない
+	if existing.IsDeleted() || existing.CurrentStatus() == db.ObstacleStatusRejected {
+		return importActionSkip, nil, false
+	}
+
+	obstacle := existing
+	action := importActionUnchanged
+	// 位置が変わった場合に加え、以前の取り込みで道路区間の代わりにOSMの要素IDを1つだけ記録した障害物も合わせ直す
+	snap := obstacle.Position != feature.Position || len(obstacle.Nodes) == 1
+	if snap || obstacle.Type != feature.Type || obstacle.DangerLevel != feature.DangerLevel || obstacle.Description != feature.Description {
+		action = importActionUpdate
+		obstacle.Position = feature.Position
+		obstacle.Type = feature.Type
+		obstacle.DangerLevel = feature.DangerLevel
+		obstacle.Description = feature.Description
+	}
+
+	// 取り込みで解消済みにした障害物の地物が再び現れた場合のみ再開する（利用者が解消した障害物は再開しない）
+	if obstacle.CurrentStatus() == db.ObstacleStatusResolved && len(obstacle.Transitions) > 0 &&
+		obstacle.Transitions[len(obstacle.Transitions)-1].Comment == osmRetireComment {
+		action = importActionReopen
+		obstacle.Transitions = append(obstacle.Transitions,
+			db.ObstacleTransition{
+				From:      db.ObstacleStatusResolved,
+				To:        db.ObstacleStatusReported,
+				Actor:     actor,
+				Comment:   osmReopenComment,
+				CreatedAt: now,
+			},
+			db.ObstacleTransition{
+				From:      db.ObstacleStatusReported,
+				To:        db.ObstacleStatusVerified,
+				Actor:     actor,
+				Comment:   osmImportComment,
+				CreatedAt: now,
+			},
+		)
+		obstacle.Status = db.ObstacleStatusVerified
+	}
+	if action != importActionUnchanged {
+		obstacle.UpdatedAt = now
+	}
+	return action, obstacle, snap
+}
+
+// snapOSMPlans は登録・移動する障害物の最寄りの道路を並行して問い合わせ、道路区間と距離を障害物に反映する
+// 問い合わせに失敗した地物は登録しない
+func snapOSMPlans(ctx context.Context, plans []osmPlan) {
+	osrmRepo := osrm.NewOSRMRepo()
+	semaphore := make(chan struct{}, importSnapConcurrency)
+	var wg sync.WaitGroup
+	for i := range plans {
+		plan := &plans[i]
+		if !plan.snap {
+			continue
+		}
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			nearest, err := osrmRepo.Nearest(ctx, plan.obstacle.Position)
+			if err != nil {
+				plan.snapErr = err
+				return
+			}
+			plan.obstacle.Nodes = nil
+			plan.obstacle.NearestDistance = 0
+			plan.obstacle.NoNearbyRoad = nearest == nil
+			if nearest != nil {
+				plan.obstacle.Nodes = nearest.Nodes
+				plan.obstacle.NearestDistance = nearest.Distance
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// saveOSMObstacle は処理内容に従って障害物を登録・更新する
+func saveOSMObstacle(ctx context.Context, obstacleRepo *db.ObstacleRepo, counterRepo *db.CounterRepo, action string, obstacle *db.Obstacle, audit db.Audit) error {
+	switch action {
+	case importActionCreate:
+		id, _, err := counterRepo.NextObstacleID(ctx)
+		if err != nil {
+			return err
+		}
+		obstacle.ID = id
+		_, err = obstacleRepo.Create(ctx, obstacle, audit)
+		return err
+	case importActionUpdate, importActionReopen, importActionRetire:
+		_, err := obstacleRepo.CreateOrUpdate(ctx, obstacle, audit)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package usecase
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"webhook/domain/db"
+)
+
+// 一括登録で受け付けるファイル形式
+const (
+	importFormatGeoJSON = "geojson"
+	importFormatCSV     = "csv"
+)
+
+// importRow は一括登録するファイルの1行（GeoJSONの1Feature）
+type importRow struct {
+	Row          int // 1始まりの行番号（CSVはヘッダーを除く、GeoJSONはFeatureの順番）
+	ExternalID   string
+	Position     [2]float64 // [緯度, 経度]
+	Type         int
+	DangerLevel  int
+	Description  string
+	NoNearbyRoad bool
+	Errors       []string
+}
+
+func (r *importRow) addError(format string, args ...any) {
+	r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+}
+
+// importColumns は列名（プロパティ名）の別名を正規化した名前に対応付ける
+var importColumns = map[string]string{
+	"lat":            "lat",
+	"latitude":       "lat",
+	"lon":            "lon",
+	"lng":            "lon",
+	"longitude":      "lon",
+	"type":           "type",
+	"danger_level":   "danger_level",
+	"dangerlevel":    "danger_level",
+	"description":    "description",
+	"external_id":    "external_id",
+	"externalid":     "external_id",
+	"no_nearby_road": "no_nearby_road",
+	"nonearbyroad":   "no_nearby_road",
+}
+
+// parseImportRows はGeoJSONのFeatureCollectionまたはCSVを解釈する
+// ファイル全体を解釈できない場合はエラーを返し、行ごとの不備は各行のErrorsに記録する
+func parseImportRows(format string, data []byte) ([]importRow, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // Excelが付けるBOM
+	if format == "" {
+		format = importFormatCSV
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+			format = importFormatGeoJSON
+		}
+	}
+
+	var rows []importRow
+	var err error
+	switch format {
+	case importFormatGeoJSON:
+		rows, err = parseGeoJSONRows(data)
+	case importFormatCSV:
+		rows, err = parseCSVRows(data)
+	default:
+		return nil, fmt.Errorf("unsupported format: %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range rows {
+		validateImportRow(&rows[i])
+	}
+	return rows, nil
+}
+
+func parseCSVRows(data []byte) ([]importRow, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("csv is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		if column, ok := importColumns[strings.ToLower(strings.TrimSpace(name))]; ok {
+			columns[column] = i
+		}
+	}
+	for _, required := range []string{"lat", "lon", "type", "danger_level"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("csv header must include %s", required)
+		}
+	}
+
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		row := importRow{Row: len(rows) + 1}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, fmt.Errorf("failed to read csv: %w", err)
+			}
+			row.addError("%v", parseErr.Err)
+			rows = append(rows, row)
+			continue
+		}
+
+		value := func(column string) string {
+			i, ok := columns[column]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		row.Position[0] = parseImportFloat(&row, "lat", value("lat"))
+		row.Position[1] = parseImportFloat(&row, "lon", value("lon"))
+		row.Type = parseImportInt(&row, "type", value("type"))
+		row.DangerLevel = parseImportInt(&row, "danger_level", value("danger_level"))
+		row.Description = value("description")
+		row.ExternalID = value("external_id")
+		if noNearbyRoad := value("no_nearby_road"); noNearbyRoad != "" {
+			parsed, err := strconv.ParseBool(noNearbyRoad)
+			if err != nil {
+				row.addError("no_nearby_road must be true or false")
+			}
+			row.NoNearbyRoad = parsed
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	ID         any            `json:"id,omitempty"`
+	Type       string         `json:"type"`
+	Geometry   *geoJSONPoint  `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+type geoJSONPoint struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"` // [経度, 緯度]
+}
+
+func parseGeoJSONRows(data []byte) ([]importRow, error) {
+	var collection geoJSONFeatureCollection
+	if err := json.Unmarshal(data, &collection); err != nil {
+		return nil, fmt.Errorf("failed to parse geojson: %w", err)
+	}
+	if collection.Type != "FeatureCollection" {
+		return nil, fmt.Errorf("geojson must be a FeatureCollection")
+	}
+
+	rows := make([]importRow, 0, len(collection.Features))
+	for i, feature := range collection.Features {
+		row := importRow{Row: i + 1}
+		if feature.Geometry == nil || feature.Geometry.Type != "Point" || len(feature.Geometry.Coordinates) < 2 {
+			row.addError("geometry must be a Point")
+		} else {
+			row.Position = [2]float64{feature.Geometry.Coordinates[1], feature.Geometry.Coordinates[0]}
+		}
+
+		properties := map[string]any{}
+		for name, value := range feature.Properties {
+			if column, ok := importColumns[strings.ToLower(name)]; ok {
+				properties[column] = value
+			}
+		}
+		row.Type = parseImportInt(&row, "type", propertyString(properties["type"]))
+		row.DangerLevel = parseImportInt(&row, "danger_level", propertyString(properties["danger_level"]))
+		row.Description = propertyString(properties["description"])
+		row.ExternalID = propertyString(properties["external_id"])
+		if row.ExternalID == "" && feature.ID != nil {
+			row.ExternalID = propertyString(feature.ID)
+		}
+		if noNearbyRoad, ok := properties["no_nearby_road"].(bool); ok {
+			row.NoNearbyRoad = noNearbyRoad
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// propertyString はGeoJSONのプロパティの値を文字列にする（数値は整数なら小数点なしで表す）
+func propertyString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+func parseImportFloat(row *importRow, name, value string) float64 {
+	if value == "" {
+		row.addError("%s is required", name)
+		return 0
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		row.addError("%s must be a number: %q", name, value)
+	}
+	return parsed
+}
+
+func parseImportInt(row *importRow, name, value string) int {
+	if value == "" {
+		row.addError("%s is required", name)
+		return 0
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		row.addError("%s must be an integer: %q", name, value)
+	}
+	return parsed
+}
+
+// validateImportRow は値の範囲を検証する
+func validateImportRow(row *importRow) {
+	if lat := row.Position[0]; lat < -90 || lat > 90 {
+		row.addError("lat must be between -90 and 90")
+	}
+	if lon := row.Position[1]; lon < -180 || lon > 180 {
+		row.addError("lon must be between -180 and 180")
+	}
+	if row.Type < 0 || row.Type > db.MaxObstacleType {
+		row.addError("type must be between 0 and %d", db.MaxObstacleType)
+	}
+	if row.DangerLevel < 0 || row.DangerLevel > db.MaxDangerLevel {
+		row.addError("danger_level must be between 0 and %d", db.MaxDangerLevel)
+	}
+}
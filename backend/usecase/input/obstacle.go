@@ -1,44 +1,273 @@
 package input
 
+import "time"
+
+// Audit represents who changed an obstacle and through which endpoint
+type Audit struct {
+	Actor  string `json:"actor"`
+	Source string `json:"source"`
+}
+
 // ObstacleGetAll represents input parameters for getting all obstacles
-type ObstacleGetAll struct{}
+// 絞り込み条件はクエリの文字列のまま受け取り、未指定の条件では絞り込まない
+type ObstacleGetAll struct {
+	Deleted       bool     `json:"deleted"`        // trueの場合はゴミ箱の障害物を返す
+	Types         []string `json:"type"`           // 種類（複数指定時はいずれか）
+	MinDanger     string   `json:"min_danger"`     // 危険度の下限
+	MaxDanger     string   `json:"max_danger"`     // 危険度の上限
+	Statuses      []string `json:"status"`         // 状態（複数指定時はいずれか）
+	CreatedAfter  string   `json:"created_after"`  // 登録日時の下限（RFC3339または日付）
+	CreatedBefore string   `json:"created_before"` // 登録日時の上限（この日時を含まない）
+	HasImage      string   `json:"has_image"`      // trueまたはfalse
+	NoNearbyRoad  string   `json:"no_nearby_road"` // trueまたはfalse
+	BBox          string   `json:"bbox"`           // 範囲（南端の緯度,西端の経度,北端の緯度,東端の経度）
+	Near          string   `json:"near"`           // 基準の地点（緯度,経度）
+	Radius        string   `json:"radius"`         // nearからの半径（メートル）
+	Sort          string   `json:"sort"`           // created_at・danger_level・distance（未指定時はテーブルの順）
+	Limit         string   `json:"limit"`          // 1ページの件数（未指定時は既定の件数）
+	Cursor        string   `json:"cursor"`         // 前のページのnext_cursor（未指定時は先頭から）
+}
+
+// ObstacleExport represents input parameters for exporting obstacles
+type ObstacleExport struct {
+	Filter ObstacleGetAll // 一覧と同じ条件で絞り込み、並べ替える（LimitとCursorは使わない）
+	Format string         // geojson・csv・kml
+}
+
+// ObstacleSearch represents input parameters for searching obstacle descriptions
+type ObstacleSearch struct {
+	Query  string         // 検索語（空白区切りの語をすべて含む障害物を探す）
+	Filter ObstacleGetAll // 一覧と同じ条件で絞り込む（Sortの指定時は関連度の代わりに使い、Cursorは使わない）
+}
 
 // ObstacleGetByID represents input parameters for getting an obstacle by ID
 type ObstacleGetByID struct {
 	ID string `json:"id" validate:"required"`
 }
 
+// DuplicatePolicy は登録時に近くに同じ種類の障害物がある場合の扱いを表す
+type DuplicatePolicy string
+
+const (
+	DuplicatePolicyReject  DuplicatePolicy = "reject"  // 登録せずに候補を返す
+	DuplicatePolicyConfirm DuplicatePolicy = "confirm" // 登録せずに最も近い候補への確認として記録する
+	DuplicatePolicyForce   DuplicatePolicy = "force"   // 重複を確認せずに登録する
+)
+
 // ObstacleCreate represents input parameters for creating an obstacle
 type ObstacleCreate struct {
-	Position    [2]float64 `json:"position" validate:"required"`
-	Type        int        `json:"type" validate:"required"`
-	Description string     `json:"description"`
-	DangerLevel int        `json:"dangerLevel" validate:"required"`
-	Nodes       []int64    `json:"nodes" validate:"required"`
-	NearestDistance float64 `json:"nearestDistance" validate:"required"`
-	NoNearbyRoad  bool       `json:"noNearbyRoad"`
+	Audit
+	Position        [2]float64      `json:"position" validate:"required"`
+	Type            int             `json:"type" validate:"required"`
+	Description     string          `json:"description"`
+	DangerLevel     int             `json:"dangerLevel" validate:"required"`
+	Nodes           []int64         `json:"nodes" validate:"required"`
+	NearestDistance float64         `json:"nearestDistance" validate:"required"`
+	NoNearbyRoad    bool            `json:"noNearbyRoad"`
+	OnDuplicate     DuplicatePolicy `json:"onDuplicate"` // 未指定時はforce
 }
 
 // ObstacleUpdate represents input parameters for updating an obstacle
 type ObstacleUpdate struct {
-	ID          string     `json:"id" validate:"required"`
-	Position    [2]float64 `json:"position" validate:"required"`
-	Type        int        `json:"type" validate:"required"`
-	Description string     `json:"description"`
-	DangerLevel int        `json:"dangerLevel" validate:"required"`
-	Nodes       []int64    `json:"nodes" validate:"required"`
-	NearestDistance float64 `json:"nearestDistance" validate:"required"`
-	NoNearbyRoad  bool       `json:"noNearbyRoad"`
+	Audit
+	ExpectedVersion *int       `json:"expected_version"`
+	ID              string     `json:"id" validate:"required"`
+	Position        [2]float64 `json:"position" validate:"required"`
+	Type            int        `json:"type" validate:"required"`
+	Description     string     `json:"description"`
+	DangerLevel     int        `json:"dangerLevel" validate:"required"`
+	Nodes           []int64    `json:"nodes" validate:"required"`
+	NearestDistance float64    `json:"nearestDistance" validate:"required"`
+	NoNearbyRoad    bool       `json:"noNearbyRoad"`
+}
+
+// ObstaclePatch represents input parameters for partially updating an obstacle (JSON Merge Patch)
+// Nil fields are left unchanged; an empty Nodes removes the nodes
+type ObstaclePatch struct {
+	Audit
+	ExpectedVersion *int        `json:"expected_version"`
+	ID              string      `json:"id" validate:"required"`
+	Position        *[2]float64 `json:"position"`
+	Type            *int        `json:"type"`
+	Description     *string     `json:"description"`
+	DangerLevel     *int        `json:"dangerLevel"`
+	Nodes           *[]int64    `json:"nodes"`
+	NearestDistance *float64    `json:"nearestDistance"`
+	NoNearbyRoad    *bool       `json:"noNearbyRoad"`
 }
 
 // ObstacleDelete represents input parameters for deleting an obstacle
 type ObstacleDelete struct {
+	Audit
+	ExpectedVersion *int   `json:"expected_version"`
+	ID              string `json:"id" validate:"required"`
+}
+
+// ObstacleTransition represents input parameters for changing the status of an obstacle
+type ObstacleTransition struct {
+	Audit
+	ID         string `json:"id" validate:"required"`
+	Status     string `json:"status" validate:"required"`
+	OnBehalfOf string `json:"on_behalf_of"`
+	Comment    string `json:"comment"`
+}
+
+// ObstacleHistory represents input parameters for getting the revision history of an obstacle
+type ObstacleHistory struct {
 	ID string `json:"id" validate:"required"`
 }
 
+// ObstacleRevert represents input parameters for reverting an obstacle to a revision
+type ObstacleRevert struct {
+	Audit
+	ExpectedVersion *int   `json:"expected_version" validate:"required"`
+	ID              string `json:"id" validate:"required"`
+	RevisionID      int64  `json:"revision_id" validate:"required"`
+}
+
+// ObstacleRestore represents input parameters for restoring an obstacle from the trash
+type ObstacleRestore struct {
+	Audit
+	ExpectedVersion *int   `json:"expected_version"`
+	ID              string `json:"id" validate:"required"`
+}
+
+// ObstaclePurge represents input parameters for permanently deleting trashed obstacles
+type ObstaclePurge struct {
+	Retention time.Duration `json:"retention"` // ゴミ箱に移動してから完全削除するまでの期間
+}
+
+// ObstacleImageCreate represents input parameters for adding an image to an obstacle
+type ObstacleImageCreate struct {
+	Audit
+	ExpectedVersion *int   `json:"expected_version"`
+	ID              string `json:"id" validate:"required"`
+	ContentType     string `json:"content_type"`
+	Filename        string `json:"filename"`
+	Caption         string `json:"caption"`
+	TakenAt         string `json:"taken_at"`
+}
+
+// ObstacleImageGet represents input parameters for getting images of an obstacle
+// An empty ImageID lists all images
+type ObstacleImageGet struct {
+	ID      string `json:"id" validate:"required"`
+	ImageID string `json:"image_id"`
+}
+
+// ObstacleImageDelete represents input parameters for removing an image from an obstacle
+type ObstacleImageDelete struct {
+	Audit
+	ExpectedVersion *int   `json:"expected_version"`
+	ID              string `json:"id" validate:"required"`
+	ImageID         string `json:"image_id" validate:"required"`
+}
+
+// ObstacleImageReorder represents input parameters for changing the display order of images
+type ObstacleImageReorder struct {
+	Audit
+	ExpectedVersion *int     `json:"expected_version"`
+	ID              string   `json:"id" validate:"required"`
+	ImageIDs        []string `json:"image_ids" validate:"required"`
+}
+
 // 画像S3キー更新用
 // ObstacleUpdateImageS3Key represents input parameters for updating image_s3_key of an obstacle
 type ObstacleUpdateImageS3Key struct {
-	ID         string `json:"id" validate:"required"`
-	ImageS3Key string `json:"image_s3_key" validate:"required"`
+	Audit
+	ExpectedVersion *int   `json:"expected_version"`
+	ID              string `json:"id" validate:"required"`
+	ImageS3Key      string `json:"image_s3_key" validate:"required"`
+}
+
+// ObstacleImageUpload represents input parameters for generating an image upload
+type ObstacleImageUpload struct {
+	ID          string `json:"id" validate:"required"`
+	ContentType string `json:"content_type"`
+	Filename    string `json:"filename"`
+}
+
+// ImageGC represents input parameters for collecting images that are not referenced by any obstacle
+type ImageGC struct {
+	GracePeriod time.Duration `json:"grace_period"` // アップロードから対象にするまでの猶予期間
+	Quarantine  bool          `json:"quarantine"`   // 削除せずに隔離用のプレフィックスへ移動する
+	DryRun      bool          `json:"dry_run"`      // 対象の一覧だけを返し、削除・移動しない
+}
+
+// OutboxProcess represents input parameters for running pending outbox entries
+type OutboxProcess struct {
+	MaxAttempts int `json:"max_attempts"` // この回数に達した処理はデッドレターにする
+}
+
+// ObstacleConfirm represents input parameters for confirming whether an obstacle is still there
+type ObstacleConfirm struct {
+	Audit
+	ID   string `json:"id" validate:"required"`
+	Vote string `json:"vote" validate:"required"` // still_there または gone
+}
+
+// ObstacleCommentGet represents input parameters for listing the comments of an obstacle
+type ObstacleCommentGet struct {
+	ID string `json:"id" validate:"required"`
+}
+
+// ObstacleCommentCreate represents input parameters for posting a comment to an obstacle
+type ObstacleCommentCreate struct {
+	Audit
+	ID   string `json:"id" validate:"required"`
+	Body string `json:"body" validate:"required"`
+}
+
+// ObstacleCommentUpdate represents input parameters for editing a comment
+type ObstacleCommentUpdate struct {
+	Audit
+	ID        string `json:"id" validate:"required"`
+	CommentID string `json:"comment_id" validate:"required"`
+	Body      string `json:"body" validate:"required"`
+}
+
+// ObstacleCommentDelete represents input parameters for deleting a comment
+type ObstacleCommentDelete struct {
+	Audit
+	ID        string `json:"id" validate:"required"`
+	CommentID string `json:"comment_id" validate:"required"`
+}
+
+// ObstacleMerge represents input parameters for folding duplicate obstacles into one
+type ObstacleMerge struct {
+	Audit
+	ExpectedVersion *int   `json:"expected_version"` // 統合先の障害物のバージョン
+	ID              string `json:"id" validate:"required"`
+	SourceIDs       []int  `json:"source_ids" validate:"required"`
+}
+
+// ObstacleImport represents input parameters for importing obstacles from a GeoJSON FeatureCollection or a CSV
+type ObstacleImport struct {
+	Audit
+	Format  string `json:"format"`   // geojson または csv（未指定時は内容から判定する）
+	Data    []byte `json:"data"`     // ファイルの内容
+	DryRun  bool   `json:"dry_run"`  // 検証と道路へのスナップだけを行い、登録しない
+	MaxRows int    `json:"max_rows"` // 一度に登録できる行数（0の場合は制限しない）
+}
+
+// OSMImport represents input parameters for importing obstacles from an OpenStreetMap extract
+type OSMImport struct {
+	Audit
+	Format string `json:"format"`  // xml または pbf（未指定時は内容から判定する）
+	Data   []byte `json:"data"`    // 抽出ファイルの内容
+	DryRun bool   `json:"dry_run"` // 変更内容を表示するだけで登録しない
+	Retire bool   `json:"retire"`  // 抽出範囲内で抽出ファイルからなくなった地物の障害物を解消済みにする
+}
+
+// OSMChangeExport represents input parameters for exporting verified obstacles as an OpenStreetMap change file
+type OSMChangeExport struct {
+	IDs []int `json:"ids"` // 対象の障害物（未指定時は確認済みの障害物すべて）
+}
+
+// ObstacleTile represents input parameters for getting a vector tile of obstacles
+type ObstacleTile struct {
+	Z      string         // ズームレベル
+	X      string         // タイルの列
+	Y      string         // タイルの行（拡張子を除く）
+	Filter ObstacleGetAll // 一覧と同じ条件で絞り込む（BBoxはタイルの範囲に置き換え、Sort・Limit・Cursorは使わない）
 }
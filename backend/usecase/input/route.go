@@ -15,9 +15,11 @@ const (
 )
 
 type RouteWithObstacles struct {
-	Locations           []Location              `json:"locations"`
-	Language            string                  `json:"language,omitempty"`
-	Costing             string                  `json:"costing,omitempty"`
-	DetectionMethod     ObstacleDetectionMethod `json:"detection_method,omitempty"`     // 障害物検出方法
-	DistanceThreshold   float64                 `json:"distance_threshold,omitempty"`   // 距離閾値（km）
-} 
\ No newline at end of file
+	Locations         []Location              `json:"locations"`
+	Language          string                  `json:"language,omitempty"`
+	Costing           string                  `json:"costing,omitempty"`
+	DetectionMethod   ObstacleDetectionMethod `json:"detection_method,omitempty"`   // 障害物検出方法
+	DistanceThreshold float64                 `json:"distance_threshold,omitempty"` // 距離閾値（km）
+	Statuses          []string                `json:"statuses,omitempty"`           // 検出対象の状態（未指定時は確認済み・補修中）
+	MinConfidence     float64                 `json:"min_confidence,omitempty"`     // 検出対象とする信頼度の下限（未指定時は絞り込まない）
+}
@@ -0,0 +1,197 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"webhook/domain/db"
+	"webhook/usecase/adaptor"
+	"webhook/usecase/input"
+	"webhook/usecase/output"
+)
+
+// maxMergeSources は一度に統合できる障害物の数（統合先とあわせて1つのトランザクションに収まる数）
+const maxMergeSources = 20
+
+// MergeObstacles folds duplicate obstacles into the obstacle input.ID.
+// Images, per-user confirmations and comments of the sources move to the target, and the sources go to the
+// trash with a link to the target so that their history stays reachable from it
+func MergeObstacles(ctx context.Context, input input.ObstacleMerge) (*output.Obstacle, int, error) {
+	id, err := strconv.Atoi(input.ID)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if len(input.SourceIDs) == 0 {
+		return nil, http.StatusBadRequest, fmt.Errorf("source_ids is required")
+	}
+	if len(input.SourceIDs) > maxMergeSources {
+		return nil, http.StatusBadRequest, fmt.Errorf("at most %d obstacles can be merged at once", maxMergeSources)
+	}
+	for i, sourceID := range input.SourceIDs {
+		if sourceID == id {
+			return nil, http.StatusBadRequest, fmt.Errorf("obstacle %d cannot be merged into itself", id)
+		}
+		if slices.Contains(input.SourceIDs[:i], sourceID) {
+			return nil, http.StatusBadRequest, fmt.Errorf("obstacle %d is listed more than once", sourceID)
+		}
+	}
+
+	obstacleRepo, err := db.NewObstacleRepo(ctx)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	target, statusCode, err := obstacleRepo.Get(ctx, id)
+	if err != nil {
+		return nil, statusCode, err
+	}
+	if target == nil || target.IsDeleted() {
+		return nil, http.StatusNotFound, nil
+	}
+	if statusCode, err := checkExpectedVersion(target, input.ExpectedVersion); err != nil {
+		return nil, statusCode, err
+	}
+
+	var sources []*db.Obstacle
+	for _, sourceID := range input.SourceIDs {
+		source, statusCode, err := obstacleRepo.Get(ctx, sourceID)
+		if err != nil {
+			return nil, statusCode, err
+		}
+		if source == nil || source.IsDeleted() {
+			return nil, http.StatusNotFound, fmt.Errorf("obstacle %d not found", sourceID)
+		}
+		sources = append(sources, source)
+	}
+
+	// 確認とコメントは障害物の統合より先に統合先へ複製する（やり直しても重複しない）
+	// 障害物の統合に失敗しても統合元は残るため、もう一度統合を実行できる
+	for _, source := range sources {
+		if statusCode, err := mergeConfirmations(ctx, source.ID, target.ID); err != nil {
+			return nil, statusCode, err
+		}
+		if statusCode, err := mergeComments(ctx, source.ID, target.ID); err != nil {
+			return nil, statusCode, err
+		}
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	for _, source := range sources {
+		if err := mergeImages(target, source); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		target.DangerLevel = max(target.DangerLevel, source.DangerLevel)
+		target.MergedFrom = append(target.MergedFrom, source.ID)
+
+		// 画像は統合先が参照するため、統合元の完全削除で消されないよう統合元からは外す
+		source.Images = nil
+		source.MergedInto = target.ID
+		source.DeletedAt = now
+		source.UpdatedAt = now
+	}
+	target.UpdatedAt = now
+
+	statusCode, err = obstacleRepo.Merge(ctx, target, sources, adaptor.ToDBAudit(input.Audit))
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	// 確認の集計は統合先へ複製したときに加算されているため、最新の値を読み直す
+	merged, statusCode, err := obstacleRepo.Get(ctx, target.ID)
+	if err != nil {
+		return nil, statusCode, err
+	}
+	if merged == nil {
+		return nil, http.StatusNotFound, nil
+	}
+	apiObstacle := adaptor.FromDBObstacle(merged)
+	if err := withImageURL(&apiObstacle); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return &apiObstacle, http.StatusOK, nil
+}
+
+// mergeImages は統合元の画像を統合先の画像の後ろに追加する（同じS3キーの画像は追加しない）
+func mergeImages(target, source *db.Obstacle) error {
+	for _, image := range source.Images {
+		if target.FindImageByKey(image.S3Key) != nil {
+			continue
+		}
+		if target.FindImage(image.ID) != nil {
+			imageID, err := newImageID()
+			if err != nil {
+				return err
+			}
+			image.ID = imageID
+		}
+		image.SortOrder = target.NextImageSortOrder()
+		target.Images = append(target.Images, image)
+	}
+	return nil
+}
+
+// mergeConfirmations は統合元の利用者ごとの確認を統合先に複製する
+// 同じ利用者が両方を確認している場合は新しい方の確認結果を残す
+func mergeConfirmations(ctx context.Context, sourceID, targetID int) (int, error) {
+	confirmationRepo, err := db.NewConfirmationRepo(ctx)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	confirmations, statusCode, err := confirmationRepo.List(ctx, sourceID)
+	if err != nil {
+		return statusCode, err
+	}
+
+	for _, confirmation := range confirmations {
+		existing, statusCode, err := confirmationRepo.Get(ctx, targetID, confirmation.UserID)
+		if err != nil {
+			return statusCode, err
+		}
+		if existing != nil && (existing.Vote == confirmation.Vote || existing.UpdatedAt >= confirmation.UpdatedAt) {
+			continue
+		}
+
+		merged := confirmation
+		merged.ObstacleID = targetID
+		if existing != nil {
+			merged.CreatedAt = existing.CreatedAt
+		}
+		statusCode, err = confirmationRepo.Save(ctx, &merged, existing)
+		if err != nil {
+			return statusCode, err
+		}
+		if statusCode == http.StatusNotFound {
+			return http.StatusConflict, fmt.Errorf("obstacle %d was removed while merging", targetID)
+		}
+	}
+	return http.StatusOK, nil
+}
+
+// mergeComments は統合元のコメントを統合先に複製する
+// コメントIDは投稿日時の順に並ぶため、統合先のスレッドでも投稿順に並ぶ
+func mergeComments(ctx context.Context, sourceID, targetID int) (int, error) {
+	commentRepo, err := db.NewCommentRepo(ctx)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	comments, statusCode, err := commentRepo.List(ctx, sourceID)
+	if err != nil {
+		return statusCode, err
+	}
+
+	for _, comment := range comments {
+		comment.ObstacleID = targetID
+		statusCode, err := commentRepo.Create(ctx, &comment)
+		if errors.Is(err, db.ErrCommentExists) {
+			continue
+		}
+		if err != nil {
+			return statusCode, err
+		}
+	}
+	return http.StatusOK, nil
+}
@@ -0,0 +1,267 @@
+package usecase
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"webhook/domain/db"
+	"webhook/shared/osm"
+	"webhook/usecase/input"
+)
+
+// 一覧の並び順
+const (
+	obstacleSortCreatedAt   = "created_at"   // 新しい順
+	obstacleSortDangerLevel = "danger_level" // 危険度の高い順
+	obstacleSortDistance    = "distance"     // nearから近い順
+)
+
+// obstacleFilter は一覧の絞り込み条件と並び順（未指定の条件は絞り込まない）
+type obstacleFilter struct {
+	deleted       bool
+	types         map[int]bool
+	minDanger     int
+	maxDanger     int
+	statuses      map[db.ObstacleStatus]bool
+	createdAfter  time.Time
+	createdBefore time.Time
+	hasImage      *bool
+	noNearbyRoad  *bool
+	bbox          *osm.Bounds
+	near          *[2]float64
+	radiusKm      float64
+	sort          string
+}
+
+// newObstacleFilter はクエリの文字列から絞り込み条件を作る（不正な値はエラーを返す）
+func newObstacleFilter(input input.ObstacleGetAll) (*obstacleFilter, error) {
+	filter := &obstacleFilter{
+		deleted:   input.Deleted,
+		minDanger: db.DangerLevelLow,
+		maxDanger: db.MaxDangerLevel,
+	}
+
+	for _, value := range input.Types {
+		obstacleType, err := strconv.Atoi(value)
+		if err != nil || obstacleType < 0 || obstacleType > db.MaxObstacleType {
+			return nil, fmt.Errorf("type must be between 0 and %d: %q", db.MaxObstacleType, value)
+		}
+		if filter.types == nil {
+			filter.types = map[int]bool{}
+		}
+		filter.types[obstacleType] = true
+	}
+
+	var err error
+	if filter.minDanger, err = parseDangerParam("min_danger", input.MinDanger, filter.minDanger); err != nil {
+		return nil, err
+	}
+	if filter.maxDanger, err = parseDangerParam("max_danger", input.MaxDanger, filter.maxDanger); err != nil {
+		return nil, err
+	}
+	if filter.minDanger > filter.maxDanger {
+		return nil, fmt.Errorf("min_danger must not be greater than max_danger")
+	}
+
+	for _, value := range input.Statuses {
+		status := db.ObstacleStatus(value)
+		if !status.IsValid() {
+			return nil, fmt.Errorf("unknown status: %q", value)
+		}
+		if filter.statuses == nil {
+			filter.statuses = map[db.ObstacleStatus]bool{}
+		}
+		filter.statuses[status] = true
+	}
+
+	if filter.createdAfter, err = parseTimeParam("created_after", input.CreatedAfter); err != nil {
+		return nil, err
+	}
+	if filter.createdBefore, err = parseTimeParam("created_before", input.CreatedBefore); err != nil {
+		return nil, err
+	}
+	if filter.hasImage, err = parseBoolParam("has_image", input.HasImage); err != nil {
+		return nil, err
+	}
+	if filter.noNearbyRoad, err = parseBoolParam("no_nearby_road", input.NoNearbyRoad); err != nil {
+		return nil, err
+	}
+
+	if input.BBox != "" {
+		values, err := parseFloatsParam("bbox", input.BBox, 4)
+		if err != nil {
+			return nil, err
+		}
+		filter.bbox = &osm.Bounds{MinLat: values[0], MinLon: values[1], MaxLat: values[2], MaxLon: values[3]}
+		if filter.bbox.MinLat > filter.bbox.MaxLat || filter.bbox.MinLon > filter.bbox.MaxLon {
+			return nil, fmt.Errorf("bbox must be south,west,north,east")
+		}
+	}
+
+	if input.Near != "" {
+		values, err := parseFloatsParam("near", input.Near, 2)
+		if err != nil {
+			return nil, err
+		}
+		filter.near = &[2]float64{values[0], values[1]}
+	}
+	if input.Radius != "" {
+		radius, err := strconv.ParseFloat(input.Radius, 64)
+		if err != nil || radius <= 0 {
+			return nil, fmt.Errorf("radius must be a positive number of meters: %q", input.Radius)
+		}
+		if filter.near == nil {
+			return nil, fmt.Errorf("radius requires near")
+		}
+		filter.radiusKm = radius / 1000
+	}
+
+	switch input.Sort {
+	case "", obstacleSortCreatedAt, obstacleSortDangerLevel:
+	case obstacleSortDistance:
+		if filter.near == nil {
+			return nil, fmt.Errorf("sort=distance requires near")
+		}
+	default:
+		return nil, fmt.Errorf("sort must be created_at, danger_level or distance: %q", input.Sort)
+	}
+	// nearだけでは絞り込みも並び替えもしないため、指定の誤りとして扱う
+	if filter.near != nil && filter.radiusKm == 0 && input.Sort != obstacleSortDistance {
+		return nil, fmt.Errorf("near requires radius or sort=distance")
+	}
+	filter.sort = input.Sort
+	return filter, nil
+}
+
+// matches は障害物が絞り込み条件に一致するかを返す
+// 通常はゴミ箱の障害物を除き、deleted指定時はゴミ箱の障害物のみを対象にする
+func (f *obstacleFilter) matches(obstacle *db.Obstacle) bool {
+	if obstacle.IsDeleted() != f.deleted {
+		return false
+	}
+	if f.types != nil && !f.types[obstacle.Type] {
+		return false
+	}
+	if obstacle.DangerLevel < f.minDanger || obstacle.DangerLevel > f.maxDanger {
+		return false
+	}
+	if f.statuses != nil && !f.statuses[obstacle.CurrentStatus()] {
+		return false
+	}
+	if !f.createdAfter.IsZero() || !f.createdBefore.IsZero() {
+		createdAt, err := time.Parse(time.RFC3339, obstacle.CreatedAt)
+		if err != nil {
+			return false
+		}
+		if !f.createdAfter.IsZero() && createdAt.Before(f.createdAfter) {
+			return false
+		}
+		if !f.createdBefore.IsZero() && !createdAt.Before(f.createdBefore) {
+			return false
+		}
+	}
+	if f.hasImage != nil && (len(obstacle.Images) > 0) != *f.hasImage {
+		return false
+	}
+	if f.noNearbyRoad != nil && obstacle.NoNearbyRoad != *f.noNearbyRoad {
+		return false
+	}
+	if f.bbox != nil && !f.bbox.Contains(obstacle.Position) {
+		return false
+	}
+	if f.radiusKm > 0 && calculateDistance(*f.near, obstacle.Position) > f.radiusKm {
+		return false
+	}
+	return true
+}
+
+// sortKey は並び順で比べる障害物の値を返す（小さいほど先に並ぶ）
+func (f *obstacleFilter) sortKey(obstacle *db.Obstacle) float64 {
+	switch f.sort {
+	case obstacleSortCreatedAt:
+		createdAt, err := time.Parse(time.RFC3339, obstacle.CreatedAt)
+		if err != nil {
+			return 0
+		}
+		return -float64(createdAt.Unix())
+	case obstacleSortDangerLevel:
+		return -float64(obstacle.DangerLevel)
+	case obstacleSortDistance:
+		return calculateDistance(*f.near, obstacle.Position)
+	}
+	return 0
+}
+
+// sortObstacles は障害物を並び順に並べる（同じ値の場合はIDの順）
+func (f *obstacleFilter) sortObstacles(obstacles []*db.Obstacle) {
+	keys := make(map[int]float64, len(obstacles))
+	for _, obstacle := range obstacles {
+		keys[obstacle.ID] = f.sortKey(obstacle)
+	}
+	sort.Slice(obstacles, func(i, j int) bool {
+		return obstacleSortLess(keys[obstacles[i].ID], obstacles[i].ID, keys[obstacles[j].ID], obstacles[j].ID)
+	})
+}
+
+func obstacleSortLess(key1 float64, id1 int, key2 float64, id2 int) bool {
+	if key1 != key2 {
+		return key1 < key2
+	}
+	return id1 < id2
+}
+
+func parseDangerParam(name, value string, defaultValue int) (int, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	level, err := strconv.Atoi(value)
+	if err != nil || level < db.DangerLevelLow || level > db.MaxDangerLevel {
+		return 0, fmt.Errorf("%s must be between %d and %d: %q", name, db.DangerLevelLow, db.MaxDangerLevel, value)
+	}
+	return level, nil
+}
+
+// parseTimeParam はRFC3339の日時または日付（UTCの0時）を読む
+func parseTimeParam(name, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%s must be an RFC3339 time or a date: %q", name, value)
+}
+
+func parseBoolParam(name, value string) (*bool, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be true or false: %q", name, value)
+	}
+	return &parsed, nil
+}
+
+// parseFloatsParam はカンマ区切りのcount個の数値を読む
+func parseFloatsParam(name, value string, count int) ([]float64, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != count {
+		return nil, fmt.Errorf("%s must be %d comma-separated numbers: %q", name, count, value)
+	}
+	values := make([]float64, count)
+	for i, part := range parts {
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be %d comma-separated numbers: %q", name, count, value)
+		}
+		values[i] = parsed
+	}
+	return values, nil
+}
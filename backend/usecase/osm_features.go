@@ -0,0 +1,276 @@
+package usecase
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"webhook/domain/db"
+	"webhook/shared/osm"
+)
+
+// osmFeature は障害物として取り込むOpenStreetMapの地物
+type osmFeature struct {
+	ExternalID  string     // osm:node/{id} または osm:way/{id}
+	Position    [2]float64 // [緯度, 経度]
+	Type        int
+	DangerLevel int
+	Description string
+	Tag         string // 障害物の種類を決めたタグ（key=value）
+}
+
+// osmClass はタグから決めた障害物の種類と危険度
+type osmClass struct {
+	Type        int
+	DangerLevel int
+	Label       string // 説明の先頭に付ける名前
+	Tag         string
+}
+
+// 車椅子やベビーカーでは通れない柵
+var osmImpassableBarriers = map[string]bool{
+	"stile":                 true,
+	"turnstile":             true,
+	"full-height_turnstile": true,
+	"kissing_gate":          true,
+	"cycle_barrier":         true,
+	"motorcycle_barrier":    true,
+}
+
+// 通行の妨げにならない、または歩行者に関係しない障壁
+var osmIgnoredBarriers = map[string]bool{
+	"no":                true,
+	"entrance":          true,
+	"border_control":    true,
+	"toll_booth":        true,
+	"height_restrictor": true,
+	"fence":             true,
+	"hedge":             true,
+	"guard_rail":        true,
+	"handrail":          true,
+}
+
+// 舗装されていない路面
+var osmUnpavedSurfaces = map[string]bool{
+	"unpaved":     true,
+	"gravel":      true,
+	"fine_gravel": true,
+	"pebblestone": true,
+	"compacted":   true,
+	"dirt":        true,
+	"earth":       true,
+	"ground":      true,
+	"grass":       true,
+	"mud":         true,
+	"sand":        true,
+	"woodchips":   true,
+}
+
+// 歩行者が通らない道路（路面や勾配を見ない）
+var osmIgnoredHighways = map[string]bool{
+	"motorway":      true,
+	"motorway_link": true,
+	"trunk":         true,
+	"trunk_link":    true,
+	"construction":  true,
+	"proposed":      true,
+	"raceway":       true,
+}
+
+// 勾配（%）と幅（メートル）のしきい値
+const (
+	osmSteepIncline     = 8.0
+	osmVerySteepIncline = 12.0
+	osmNarrowWidth      = 1.0
+)
+
+// classifyOSMNode はノードのタグから障害物の種類と危険度を決める（取り込まない場合はfalse）
+func classifyOSMNode(tags map[string]string) (osmClass, bool) {
+	if tags["highway"] == "steps" {
+		return osmClass{db.ObstacleTypeStairs, db.DangerLevelHigh, "階段", "highway=steps"}, true
+	}
+	if tags["kerb"] == "raised" {
+		return osmClass{db.ObstacleTypeOther, db.DangerLevelMedium, "高い縁石", "kerb=raised"}, true
+	}
+	if tags["amenity"] == "vending_machine" {
+		return osmClass{db.ObstacleTypeVendingMachine, db.DangerLevelLow, "自動販売機", "amenity=vending_machine"}, true
+	}
+
+	barrier := tags["barrier"]
+	tag := "barrier=" + barrier
+	switch {
+	case barrier == "" || osmIgnoredBarriers[barrier]:
+		return osmClass{}, false
+	case barrier == "step":
+		return osmClass{db.ObstacleTypeStairs, db.DangerLevelMedium, "段差", tag}, true
+	case barrier == "kerb":
+		// 段差のない縁石（lowered・flushなど）は取り込まない
+		if kerb := tags["kerb"]; kerb != "" {
+			return osmClass{}, false
+		}
+		return osmClass{db.ObstacleTypeOther, db.DangerLevelLow, "縁石", tag}, true
+	case osmImpassableBarriers[barrier]:
+		return osmClass{db.ObstacleTypeNarrowRoad, db.DangerLevelHigh, "車椅子で通れない柵", tag}, true
+	case barrier == "bollard" || barrier == "block" || barrier == "chain" || barrier == "log" || barrier == "jersey_barrier":
+		return osmClass{db.ObstacleTypeOther, db.DangerLevelLow, "車止め", tag}, true
+	case strings.HasSuffix(barrier, "gate"):
+		return osmClass{db.ObstacleTypeOther, db.DangerLevelLow, "門", tag}, true
+	}
+	return osmClass{db.ObstacleTypeOther, db.DangerLevelLow, "障壁", tag}, true
+}
+
+// classifyOSMWay はウェイのタグから障害物の種類と危険度を決める（取り込まない場合はfalse）
+func classifyOSMWay(tags map[string]string) (osmClass, bool) {
+	switch barrier := tags["barrier"]; {
+	case barrier == "wall" || barrier == "retaining_wall":
+		return osmClass{db.ObstacleTypeBlockWall, db.DangerLevelMedium, "塀", "barrier=" + barrier}, true
+	case barrier == "kerb" && tags["kerb"] == "raised":
+		return osmClass{db.ObstacleTypeOther, db.DangerLevelMedium, "高い縁石", "kerb=raised"}, true
+	}
+
+	highway := tags["highway"]
+	if highway == "" || osmIgnoredHighways[highway] {
+		return osmClass{}, false
+	}
+	if highway == "steps" {
+		return osmClass{db.ObstacleTypeStairs, db.DangerLevelHigh, "階段", "highway=steps"}, true
+	}
+	if incline, ok := parseOSMIncline(tags["incline"]); ok && math.Abs(incline) >= osmSteepIncline {
+		dangerLevel := db.DangerLevelMedium
+		if math.Abs(incline) >= osmVerySteepIncline {
+			dangerLevel = db.DangerLevelHigh
+		}
+		return osmClass{db.ObstacleTypeSteepSlope, dangerLevel, "急な坂", "incline=" + tags["incline"]}, true
+	}
+	if width, ok := parseOSMWidth(tags["width"]); ok && width < osmNarrowWidth {
+		return osmClass{db.ObstacleTypeNarrowRoad, db.DangerLevelMedium, "狭い道", "width=" + tags["width"]}, true
+	}
+	if surface := tags["surface"]; osmUnpavedSurfaces[surface] {
+		return osmClass{db.ObstacleTypeOther, db.DangerLevelMedium, "未舗装の道", "surface=" + surface}, true
+	}
+	return osmClass{}, false
+}
+
+// parseOSMIncline は勾配（「10%」「-8 %」「5°」など）を%で返す（up・downなど数値でない場合はfalse）
+func parseOSMIncline(value string) (float64, bool) {
+	value = strings.TrimSpace(value)
+	degrees := strings.HasSuffix(value, "°")
+	value = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(value, "%"), "°"))
+	incline, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, false
+	}
+	if degrees {
+		incline = math.Tan(incline*math.Pi/180) * 100
+	}
+	return incline, true
+}
+
+// parseOSMWidth は幅（「0.8」「0.8 m」など）をメートルで返す
+func parseOSMWidth(value string) (float64, bool) {
+	value = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "m"))
+	width, err := strconv.ParseFloat(value, 64)
+	if err != nil || width <= 0 {
+		return 0, false
+	}
+	return width, true
+}
+
+func newOSMFeature(kind string, id int64, position [2]float64, class osmClass, tags map[string]string) osmFeature {
+	label := class.Label
+	if name := tags["name"]; name != "" {
+		label += " " + name
+	}
+	return osmFeature{
+		ExternalID:  fmt.Sprintf("osm:%s/%d", kind, id),
+		Position:    position,
+		Type:        class.Type,
+		DangerLevel: class.DangerLevel,
+		Description: fmt.Sprintf("%s（OpenStreetMap %s/%d: %s）", label, kind, id, class.Tag),
+		Tag:         class.Tag,
+	}
+}
+
+// readOSMFeatures は抽出ファイルから取り込む地物と抽出範囲を読む
+// ウェイの位置には中央のノードの位置を使うため、必要なノードの座標を2回目の読み込みで集める
+func readOSMFeatures(format string, data []byte) ([]osmFeature, osm.Bounds, error) {
+	type pendingWay struct {
+		way   *osm.Way
+		class osmClass
+	}
+	var features []osmFeature
+	var pendingWays []pendingWay
+	var bounds *osm.Bounds
+	var nodeBounds osm.Bounds
+	nodeCount := 0
+
+	err := osm.Read(bytes.NewReader(data), format, osm.Handler{
+		Bounds: func(b osm.Bounds) {
+			bounds = &b
+		},
+		Node: func(node *osm.Node) error {
+			if nodeCount == 0 {
+				nodeBounds = osm.Bounds{MinLat: node.Lat, MinLon: node.Lon, MaxLat: node.Lat, MaxLon: node.Lon}
+			}
+			nodeCount++
+			nodeBounds.MinLat = min(nodeBounds.MinLat, node.Lat)
+			nodeBounds.MinLon = min(nodeBounds.MinLon, node.Lon)
+			nodeBounds.MaxLat = max(nodeBounds.MaxLat, node.Lat)
+			nodeBounds.MaxLon = max(nodeBounds.MaxLon, node.Lon)
+
+			if class, ok := classifyOSMNode(node.Tags); ok {
+				features = append(features, newOSMFeature("node", node.ID, [2]float64{node.Lat, node.Lon}, class, node.Tags))
+			}
+			return nil
+		},
+		Way: func(way *osm.Way) error {
+			if class, ok := classifyOSMWay(way.Tags); ok && len(way.NodeIDs) > 0 {
+				pendingWays = append(pendingWays, pendingWay{way: way, class: class})
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, osm.Bounds{}, err
+	}
+	if bounds == nil {
+		bounds = &nodeBounds
+	}
+	if len(pendingWays) == 0 {
+		return features, *bounds, nil
+	}
+
+	positions := map[int64][2]float64{}
+	for _, pending := range pendingWays {
+		for _, nodeID := range pending.way.NodeIDs {
+			positions[nodeID] = [2]float64{math.NaN(), math.NaN()}
+		}
+	}
+	err = osm.Read(bytes.NewReader(data), format, osm.Handler{
+		Node: func(node *osm.Node) error {
+			if _, ok := positions[node.ID]; ok {
+				positions[node.ID] = [2]float64{node.Lat, node.Lon}
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, osm.Bounds{}, err
+	}
+
+	for _, pending := range pendingWays {
+		// 中央のノードから順に、抽出範囲内にあるノードを探す
+		nodeIDs := pending.way.NodeIDs
+		middle := len(nodeIDs) / 2
+		for offset := 0; offset < len(nodeIDs); offset++ {
+			i := (middle + offset) % len(nodeIDs)
+			if position := positions[nodeIDs[i]]; !math.IsNaN(position[0]) {
+				features = append(features, newOSMFeature("way", pending.way.ID, position, pending.class, pending.way.Tags))
+				break
+			}
+		}
+	}
+	return features, *bounds, nil
+}
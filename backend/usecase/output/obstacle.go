@@ -2,18 +2,258 @@ package output
 
 // Models for API layer
 type Obstacle struct {
-	ID          int        `json:"id"`
-	Position    [2]float64 `json:"position"`
-	Type        int        `json:"type"`
-	Description string     `json:"description"`
-	DangerLevel int        `json:"dangerLevel"`
-	Nodes       []int64    `json:"nodes"`
-	NearestDistance float64 `json:"nearestDistance"`
-	NoNearbyRoad  bool       `json:"noNearbyRoad"`
-	ImageS3Key  string     `json:"image_s3_key"`
-	CreatedAt   string     `json:"createdAt"`
+	ID              int                   `json:"id"`
+	Position        [2]float64            `json:"position"`
+	Type            int                   `json:"type"`
+	Description     string                `json:"description"`
+	DangerLevel     int                   `json:"dangerLevel"`
+	Nodes           []int64               `json:"nodes"`
+	NearestDistance float64               `json:"nearestDistance"`
+	NoNearbyRoad    bool                  `json:"noNearbyRoad"`
+	ImageS3Key      string                `json:"image_s3_key"` // 先頭の画像のS3キー（互換性のため）
+	ImageURL        string                `json:"image_url,omitempty"`
+	ThumbnailURL    string                `json:"thumbnail_url,omitempty"` // 先頭の画像の最小サイズのサムネイル
+	Images          []ObstacleImage       `json:"images,omitempty"`
+	Status          string                `json:"status"`
+	Transitions     []ObstacleTransition  `json:"transitions,omitempty"`
+	CreatedAt       string                `json:"createdAt"`
+	UpdatedAt       string                `json:"updatedAt,omitempty"`
+	Version         int                   `json:"version"`
+	DeletedAt       string                `json:"deletedAt,omitempty"`
+	Confirmations   ObstacleConfirmations `json:"confirmations"`
+	Confidence      float64               `json:"confidence"` // 確認の新しさと賛否による信頼度（0〜1）
+	MergedInto      int                   `json:"mergedInto,omitempty"`
+	MergedFrom      []int                 `json:"mergedFrom,omitempty"`
+	ExternalID      string                `json:"externalId,omitempty"` // 一括登録したファイルでのID
+}
+
+type ObstacleConfirmations struct {
+	StillThere      int    `json:"stillThere"`
+	Gone            int    `json:"gone"`
+	LastConfirmedAt string `json:"lastConfirmedAt,omitempty"`
+	LastGoneAt      string `json:"lastGoneAt,omitempty"`
+}
+
+type ObstacleConfirmation struct {
+	ObstacleID    int                   `json:"obstacleId"`
+	UserID        string                `json:"userId"`
+	Vote          string                `json:"vote"`
+	Confirmations ObstacleConfirmations `json:"confirmations"`
+	Confidence    float64               `json:"confidence"`
+}
+
+type ObstacleTransition struct {
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Actor      string `json:"actor"`
+	OnBehalfOf string `json:"onBehalfOf,omitempty"`
+	Comment    string `json:"comment,omitempty"`
+	CreatedAt  string `json:"createdAt"`
+}
+
+type ObstacleImage struct {
+	ID         string                   `json:"id"`
+	S3Key      string                   `json:"s3Key"`
+	URL        string                   `json:"url,omitempty"`
+	Caption    string                   `json:"caption,omitempty"`
+	Uploader   string                   `json:"uploader,omitempty"`
+	TakenAt    string                   `json:"takenAt,omitempty"`
+	SortOrder  int                      `json:"sortOrder"`
+	CreatedAt  string                   `json:"createdAt"`
+	Thumbnails []ObstacleImageThumbnail `json:"thumbnails,omitempty"`
+	Metadata   *ObstacleImageMetadata   `json:"metadata,omitempty"`
+	ViewS3Key  string                   `json:"-"` // 閲覧用URLを発行するS3キー（メタデータを取り除いた画像）
+}
+
+// ObstacleImageMetadata は画像のEXIFから取り出した情報
+// 撮影位置は撮影者の居場所を明かすため返さず、障害物からの距離のみを返す
+type ObstacleImageMetadata struct {
+	CapturedAt      string   `json:"capturedAt,omitempty"`
+	DistanceMeters  *float64 `json:"distanceMeters,omitempty"`
+	FarFromObstacle bool     `json:"farFromObstacle"`
+}
+
+type ObstacleImageThumbnail struct {
+	Width int    `json:"width"`
+	S3Key string `json:"s3Key"`
+	URL   string `json:"url,omitempty"`
+}
+
+type ObstacleImageUpload struct {
+	Image  ObstacleImage     `json:"image"`
+	URL    string            `json:"url"`
+	Fields map[string]string `json:"fields"` // アップロード時にフォームで送信する項目
+}
+
+type ImageUpload struct {
+	URL        string            `json:"url"`
+	Fields     map[string]string `json:"fields"` // アップロード時にフォームで送信する項目
+	ImageS3Key string            `json:"image_s3_key"`
+	MaxBytes   int64             `json:"max_bytes"`
+}
+
+type ListObstacleImageResponse struct {
+	Items []ObstacleImage `json:"items"`
+}
+
+type ObstacleRevision struct {
+	ObstacleID int       `json:"obstacleId"`
+	RevisionID int64     `json:"revisionId"`
+	Actor      string    `json:"actor"`
+	Source     string    `json:"source"`
+	Before     *Obstacle `json:"before,omitempty"`
+	After      *Obstacle `json:"after,omitempty"`
+	CreatedAt  string    `json:"createdAt"`
+}
+
+type ListObstacleRevisionResponse struct {
+	Items []ObstacleRevision `json:"items"`
+}
+
+type PurgeObstacleResponse struct {
+	PurgedIDs []int `json:"purgedIds"`
+	FailedIDs []int `json:"failedIds"`
+}
+
+type ReindexObstaclesResponse struct {
+	Indexed   int   `json:"indexed"`
+	FailedIDs []int `json:"failedIds"`
+}
+
+type CollectOrphanedImagesResponse struct {
+	ScannedCount  int      `json:"scannedCount"`
+	OrphanedKeys  []string `json:"orphanedKeys"`
+	CollectedKeys []string `json:"collectedKeys"`
+	FailedKeys    []string `json:"failedKeys"`
 }
 
 type ListObstacleResponse struct {
-	Items []Obstacle `json:"items"`
+	Items      []Obstacle `json:"items"`
+	NextCursor string     `json:"next_cursor,omitempty"` // 続きがある場合に次のページの取得に使う
+}
+
+// ObstacleSearchResult は検索に一致した障害物と関連度
+type ObstacleSearchResult struct {
+	Obstacle
+	Score float64 `json:"score"`
+}
+
+// RouteObstacle はルート上で検出した障害物と、確認による重み付けをした危険度
+type RouteObstacle struct {
+	Obstacle
+	WeightedDangerLevel float64 `json:"weightedDangerLevel"` // 危険度×信頼度
+}
+
+type SearchObstacleResponse struct {
+	Items []ObstacleSearchResult `json:"items"`
+}
+
+// ObstacleExport はエクスポートしたファイル
+// レスポンスで返せない大きさの場合はファイルの代わりにS3のダウンロードURLを返す
+type ObstacleExport struct {
+	Count       int    `json:"count"`
+	URL         string `json:"url,omitempty"`
+	ExpiresAt   string `json:"expiresAt,omitempty"`
+	ContentType string `json:"-"`
+	FileName    string `json:"-"`
+	Body        []byte `json:"-"`
+}
+
+// ObstacleTile は障害物のベクトルタイル（Mapbox Vector Tile）
+type ObstacleTile struct {
+	Count int    `json:"count"`
+	ETag  string `json:"-"` // 内容のハッシュ（同じ内容のタイルは同じ値になる）
+	Body  []byte `json:"-"`
+}
+
+type OutboxEntry struct {
+	ID         string   `json:"id"`
+	Kind       string   `json:"kind"`
+	ObstacleID int      `json:"obstacleId"`
+	S3Keys     []string `json:"s3Keys,omitempty"`
+	Attempts   int      `json:"attempts"`
+	LastError  string   `json:"lastError,omitempty"`
+	CreatedAt  string   `json:"createdAt"`
+}
+
+type ProcessOutboxResponse struct {
+	CompletedIDs    []string      `json:"completedIds"`
+	RetriedIDs      []string      `json:"retriedIds"`
+	DeadLetters     []OutboxEntry `json:"deadLetters"` // 今回の実行で再試行の上限に達したもの
+	DeadLetterCount int           `json:"deadLetterCount"`
+}
+
+type ObstacleComment struct {
+	ID                 string `json:"id"`
+	ObstacleID         int    `json:"obstacleId"`
+	Author             string `json:"author"`
+	Body               string `json:"body"` // 削除されたコメントは空
+	CreatedAt          string `json:"createdAt"`
+	UpdatedAt          string `json:"updatedAt"`
+	EditedAt           string `json:"editedAt,omitempty"`
+	Deleted            bool   `json:"deleted"`
+	DeletedAt          string `json:"deletedAt,omitempty"`
+	RemovedByModerator bool   `json:"removedByModerator,omitempty"`
+}
+
+type ListObstacleCommentResponse struct {
+	Items []ObstacleComment `json:"items"`
+}
+
+type ImportObstacleRow struct {
+	Row             int      `json:"row"`
+	ExternalID      string   `json:"externalId,omitempty"`
+	Action          string   `json:"action"` // create・update・unchanged・error
+	ObstacleID      int      `json:"obstacleId,omitempty"`
+	Nodes           []int64  `json:"nodes,omitempty"`
+	NearestDistance *float64 `json:"nearestDistance,omitempty"`
+	Errors          []string `json:"errors,omitempty"`
+}
+
+type ImportObstaclesResponse struct {
+	DryRun    bool                `json:"dryRun"`
+	Total     int                 `json:"total"`
+	Created   int                 `json:"created"`
+	Updated   int                 `json:"updated"`
+	Unchanged int                 `json:"unchanged"`
+	Failed    int                 `json:"failed"`
+	Rows      []ImportObstacleRow `json:"rows"`
+}
+
+// ImportOSMChange はOpenStreetMapからの取り込みで変更した（する）障害物
+type ImportOSMChange struct {
+	ExternalID string `json:"externalId"`
+	Action     string `json:"action"` // create・update・reopen・retire・skip・error
+	ObstacleID int    `json:"obstacleId,omitempty"`
+	Tag        string `json:"tag,omitempty"` // 障害物の種類を決めたタグ
+	Error      string `json:"error,omitempty"`
+}
+
+type ImportOSMResponse struct {
+	DryRun    bool              `json:"dryRun"`
+	Features  int               `json:"features"` // 抽出ファイル内の取り込み対象の地物の数
+	Created   int               `json:"created"`
+	Updated   int               `json:"updated"`
+	Reopened  int               `json:"reopened"`
+	Unchanged int               `json:"unchanged"`
+	Retired   int               `json:"retired"`
+	Skipped   int               `json:"skipped"`
+	Failed    int               `json:"failed"`
+	Changes   []ImportOSMChange `json:"changes"` // 変更のない障害物は含めない
+}
+
+// OSMChangeProposal は障害物ごとのOpenStreetMapへの変更の提案
+type OSMChangeProposal struct {
+	ObstacleID int               `json:"obstacleId"`
+	Element    string            `json:"element,omitempty"` // 変更する要素（way/123、追加するノードはnode/-1）
+	Tags       map[string]string `json:"tags,omitempty"`    // 提案したタグ
+	Skipped    string            `json:"skipped,omitempty"` // 提案できなかった理由
+}
+
+type OSMChangeExport struct {
+	Created   int                 `json:"created"`  // 追加するノードの数
+	Modified  int                 `json:"modified"` // 変更するウェイの数
+	Skipped   int                 `json:"skipped"`
+	Proposals []OSMChangeProposal `json:"proposals"`
+	Body      []byte              `json:"-"` // osmChangeのXML
 }
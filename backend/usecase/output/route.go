@@ -6,7 +6,7 @@ type ValhallaRouteResponse struct {
 	Admins  []Admin      `json:"admins"`
 	Units   string       `json:"units"`
 	Language string      `json:"language"`
-	Obstacles []Obstacle `json:"obstacles,omitempty"` // 追加: ルート上の障害物
+	Obstacles []RouteObstacle `json:"obstacles,omitempty"` // 追加: ルート上の障害物
 }
 
 type Trip struct {
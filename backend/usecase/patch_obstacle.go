@@ -0,0 +1,47 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"webhook/domain/db"
+	"webhook/usecase/adaptor"
+	"webhook/usecase/input"
+	"webhook/usecase/output"
+)
+
+// PatchObstacle updates only the fields present in the merge patch
+func PatchObstacle(ctx context.Context, input input.ObstaclePatch) (*output.Obstacle, int, error) {
+	id, err := strconv.Atoi(input.ID)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	patch := db.ObstaclePatch{
+		Position:        input.Position,
+		Type:            input.Type,
+		Description:     input.Description,
+		DangerLevel:     input.DangerLevel,
+		Nodes:           input.Nodes,
+		NearestDistance: input.NearestDistance,
+		NoNearbyRoad:    input.NoNearbyRoad,
+		UpdatedAt:       time.Now().Format(time.RFC3339),
+	}
+
+	obstacleRepo, err := db.NewObstacleRepo(ctx)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	obstacle, statusCode, err := obstacleRepo.Patch(ctx, id, patch, input.ExpectedVersion, adaptor.ToDBAudit(input.Audit))
+	if err != nil {
+		return nil, statusCode, err
+	}
+	if obstacle == nil {
+		return nil, http.StatusNotFound, nil
+	}
+
+	apiObstacle := adaptor.FromDBObstacle(obstacle)
+	return &apiObstacle, http.StatusOK, nil
+}
@@ -0,0 +1,15 @@
+package usecase
+
+import (
+	"net/http"
+
+	"webhook/domain/db"
+)
+
+// checkExpectedVersion はIf-Matchで指定されたバージョンと障害物の現在のバージョンを比較する
+func checkExpectedVersion(obstacle *db.Obstacle, expectedVersion *int) (int, error) {
+	if expectedVersion != nil && obstacle.Version != *expectedVersion {
+		return http.StatusPreconditionFailed, db.ErrVersionConflict
+	}
+	return http.StatusOK, nil
+}
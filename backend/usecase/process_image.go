@@ -0,0 +1,111 @@
+package usecase
+
+import (
+	"bytes"
+	"fmt"
+	"image/jpeg"
+	"path"
+	"strings"
+	"time"
+
+	"webhook/domain/db"
+	"webhook/domain/s3"
+	"webhook/shared/imaging"
+	"webhook/shared/util"
+)
+
+// thumbnailWidths は生成するサムネイルの幅（px）
+// 200pxは地図・一覧表示用、800pxは詳細表示用
+var thumbnailWidths = []int{200, 800}
+
+// orientedImageQuality は向きを補正して再エンコードする際のJPEG品質
+const orientedImageQuality = 90
+
+// processImage はアップロード済みの画像を処理して、処理結果を画像に記録する
+//   - EXIFから撮影位置・撮影日時を取り出し、障害物の位置から離れていないかを判定する
+//   - EXIFなどのメタデータを取り除いた画像を、元画像とは別のキー（processed/）に保存する
+//   - サムネイルを生成する
+//
+// 元画像を上書きするとアップロードを契機とする処理が再び動くため、書き込みはobstacles/の外に限る
+// 対応していない形式の場合はimaging.ErrUnsupportedFormatを返す
+func processImage(s3Repo *s3.S3Repo, ob *db.Obstacle, image *db.ObstacleImage) error {
+	data, err := s3Repo.GetObject(image.S3Key)
+	if err != nil {
+		return err
+	}
+	meta := imaging.ReadMetadata(data)
+	img, err := imaging.Decode(data)
+	if err != nil {
+		return err
+	}
+	img = imaging.Orient(img, meta.Orientation)
+
+	stripped, err := imaging.StripMetadata(data)
+	if err != nil {
+		return err
+	}
+	if meta.Orientation > 1 {
+		// 向きの情報もEXIFと一緒に失われるため、画素データを回転させて保存し直す
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: orientedImageQuality}); err != nil {
+			return fmt.Errorf("failed to encode oriented image: %w", err)
+		}
+		stripped = buf.Bytes()
+	}
+	contentType := imaging.ContentType(stripped)
+	processedKey := processedImageKey(image.S3Key, allowedImageContentTypes[contentType])
+	if err := s3Repo.PutObject(processedKey, stripped, contentType); err != nil {
+		return err
+	}
+
+	var variants []db.ObstacleImageVariant
+	for _, width := range thumbnailWidths {
+		thumbnail, err := imaging.Thumbnail(img, width)
+		if err != nil {
+			return err
+		}
+		key := thumbnailKey(image.S3Key, width)
+		if err := s3Repo.PutObject(key, thumbnail, "image/jpeg"); err != nil {
+			return err
+		}
+		variants = append(variants, db.ObstacleImageVariant{Width: width, S3Key: key})
+	}
+
+	image.ProcessedS3Key = processedKey
+	image.Variants = variants
+	image.Metadata = imageMetadata(ob, meta)
+	if image.TakenAt == "" && image.Metadata.CapturedAt != "" {
+		image.TakenAt = image.Metadata.CapturedAt
+	}
+	image.ProcessedAt = time.Now().Format(time.RFC3339)
+	return nil
+}
+
+// imageMetadata はEXIFの情報を画像の記録用に変換する
+func imageMetadata(ob *db.Obstacle, meta *imaging.Metadata) *db.ObstacleImageMetadata {
+	result := &db.ObstacleImageMetadata{}
+	if !meta.CapturedAt.IsZero() {
+		result.CapturedAt = meta.CapturedAt.Format(time.RFC3339)
+	}
+	if meta.HasGPS {
+		lat, lon := meta.Latitude, meta.Longitude
+		distance := calculateDistance(ob.Position, [2]float64{lat, lon}) * 1000
+		result.Latitude = &lat
+		result.Longitude = &lon
+		result.DistanceMeters = &distance
+		result.FarFromObstacle = distance > util.GetSetting().ObstacleImage.MaxDistanceMeters
+	}
+	return result
+}
+
+// processedImageKey はメタデータを取り除いた画像のS3キーを返す（例: processed/obstacles/1/abc.jpg）
+// 向きを補正してJPEGで保存し直した場合もContent-Typeと一致するよう、拡張子は保存する形式に合わせる
+func processedImageKey(s3Key, ext string) string {
+	return fmt.Sprintf("processed/%s.%s", strings.TrimSuffix(s3Key, path.Ext(s3Key)), ext)
+}
+
+// thumbnailKey はサムネイルのS3キーを返す（例: thumbnails/200/obstacles/1/abc.jpg）
+// 元画像と別のプレフィックスにして、アップロードを契機とする処理の対象外にする
+func thumbnailKey(s3Key string, width int) string {
+	return fmt.Sprintf("thumbnails/%d/%s.jpg", width, strings.TrimSuffix(s3Key, path.Ext(s3Key)))
+}
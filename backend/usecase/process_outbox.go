@@ -0,0 +1,166 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"webhook/domain/db"
+	"webhook/domain/s3"
+	"webhook/shared/util"
+	"webhook/usecase/adaptor"
+	"webhook/usecase/input"
+	"webhook/usecase/output"
+)
+
+const (
+	// outboxBaseBackoff は最初の再試行までの待ち時間（以降は試行ごとに倍にする）
+	outboxBaseBackoff = time.Minute
+	// outboxMaxBackoff は再試行までの待ち時間の上限
+	outboxMaxBackoff = 6 * time.Hour
+)
+
+// ProcessOutbox runs the outbox entries whose next attempt time has passed. Failed entries are retried with
+// exponential backoff and become dead letters after the maximum number of attempts
+func ProcessOutbox(ctx context.Context, input input.OutboxProcess) (*output.ProcessOutboxResponse, int, error) {
+	outboxRepo, err := db.NewOutboxRepo(ctx)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	s3Repo, err := s3.NewS3Repo()
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	entries, statusCode, err := outboxRepo.ListDue(ctx, time.Now().Unix())
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	response := &output.ProcessOutboxResponse{CompletedIDs: []string{}, RetriedIDs: []string{}, DeadLetters: []output.OutboxEntry{}}
+	for i := range entries {
+		entry := &entries[i]
+		completed, err := runOutboxEntry(ctx, outboxRepo, s3Repo, entry, input.MaxAttempts)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		switch {
+		case entry.State == db.OutboxStateDead:
+			response.DeadLetters = append(response.DeadLetters, adaptor.FromDBOutboxEntry(entry))
+		case !completed:
+			response.RetriedIDs = append(response.RetriedIDs, entry.ID)
+		default:
+			response.CompletedIDs = append(response.CompletedIDs, entry.ID)
+		}
+	}
+
+	// 以前の実行で上限に達したものも含めて、対応が必要なデッドレターの件数を報告する
+	dead, statusCode, err := outboxRepo.ListDead(ctx)
+	if err != nil {
+		return nil, statusCode, err
+	}
+	response.DeadLetterCount = len(dead)
+
+	return response, http.StatusOK, nil
+}
+
+// runOutbox は障害物の変更の確定直後に後処理を実行する
+// 失敗した場合やLambdaが途中で終了した場合は、記録済みの処理をProcessOutboxが再試行する
+func runOutbox(ctx context.Context, entry *db.OutboxEntry) {
+	if entry == nil {
+		return
+	}
+	outboxRepo, err := db.NewOutboxRepo(ctx)
+	if err != nil {
+		return
+	}
+	s3Repo, err := s3.NewS3Repo()
+	if err != nil {
+		return
+	}
+	_, _ = runOutboxEntry(ctx, outboxRepo, s3Repo, entry, util.GetSetting().Outbox.MaxAttempts)
+}
+
+// runOutboxEntry は処理を1回実行し、結果に応じて処理を完了・再試行待ち・デッドレターのいずれかにする
+// 処理自体の失敗は記録して再試行するため、返すのは完了したかどうかと結果を保存できなかった場合のエラーのみ
+func runOutboxEntry(ctx context.Context, outboxRepo *db.OutboxRepo, s3Repo *s3.S3Repo, entry *db.OutboxEntry, maxAttempts int) (bool, error) {
+	var lastErr error
+	switch entry.Kind {
+	case db.OutboxKindDeleteS3Objects:
+		lastErr = deleteOutboxS3Objects(s3Repo, entry)
+	case db.OutboxKindPurgeObstacle:
+		// 削除は何度実行しても同じ結果になるため、失敗した場合は残りを含めてすべて再試行する
+		lastErr = deleteOutboxS3Objects(s3Repo, entry)
+		if err := purgeObstacleRecords(ctx, entry.ObstacleID); err != nil {
+			lastErr = err
+		}
+	case db.OutboxKindIndexObstacle:
+		obstacleRepo, err := db.NewObstacleRepo(ctx)
+		if err == nil {
+			_, err = obstacleRepo.Reindex(ctx, entry.ObstacleID)
+		}
+		lastErr = err
+	default:
+		lastErr = fmt.Errorf("unknown outbox kind: %q", entry.Kind)
+	}
+
+	if lastErr == nil {
+		_, err := outboxRepo.Complete(ctx, entry.ID)
+		return err == nil, err
+	}
+
+	now := time.Now()
+	entry.Attempts++
+	entry.LastError = lastErr.Error()
+	entry.UpdatedAt = now.Format(time.RFC3339)
+	if entry.Attempts >= maxAttempts {
+		entry.State = db.OutboxStateDead
+	} else {
+		entry.NextAttemptAt = now.Add(outboxBackoff(entry.Attempts)).Unix()
+	}
+	_, err := outboxRepo.Update(ctx, entry)
+	return false, err
+}
+
+// deleteOutboxS3Objects は処理のS3オブジェクトを削除する
+// 削除できたキーは外し、残ったキーだけを再試行する
+func deleteOutboxS3Objects(s3Repo *s3.S3Repo, entry *db.OutboxEntry) error {
+	var lastErr error
+	var remaining []string
+	for _, key := range entry.S3Keys {
+		if err := s3Repo.DeleteObject(key); err != nil {
+			remaining = append(remaining, key)
+			lastErr = err
+		}
+	}
+	entry.S3Keys = remaining
+	return lastErr
+}
+
+// purgeObstacleRecords は完全削除した障害物の確認・コメントを削除する
+// 変更履歴は監査の記録で、統合先の履歴からも参照するため削除しない
+func purgeObstacleRecords(ctx context.Context, obstacleID int) error {
+	confirmationRepo, err := db.NewConfirmationRepo(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := confirmationRepo.DeleteAll(ctx, obstacleID); err != nil {
+		return err
+	}
+	commentRepo, err := db.NewCommentRepo(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = commentRepo.DeleteAll(ctx, obstacleID)
+	return err
+}
+
+// outboxBackoff は試行回数に応じた再試行までの待ち時間を返す
+func outboxBackoff(attempts int) time.Duration {
+	backoff := outboxBaseBackoff
+	for i := 1; i < attempts && backoff < outboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, outboxMaxBackoff)
+}
@@ -0,0 +1,60 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"webhook/domain/db"
+	"webhook/usecase/input"
+	"webhook/usecase/output"
+)
+
+// PurgeDeletedObstacles permanently deletes obstacles that have been in the trash longer than the retention period,
+// together with their images, confirmations and comments.
+// Revisions are kept as the audit log, so the history of purged and merged obstacles stays reachable
+func PurgeDeletedObstacles(ctx context.Context, input input.ObstaclePurge) (*output.PurgeObstacleResponse, int, error) {
+	obstacleRepo, err := db.NewObstacleRepo(ctx)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	threshold := time.Now().Add(-input.Retention)
+	audit := db.Audit{Actor: "system", Source: "purge"}
+	response := &output.PurgeObstacleResponse{PurgedIDs: []int{}, FailedIDs: []int{}}
+	for obstacle, err := range obstacleRepo.All(ctx) {
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		if !obstacle.IsDeleted() {
+			continue
+		}
+		deletedAt, err := time.Parse(time.RFC3339, obstacle.DeletedAt)
+		if err != nil || deletedAt.After(threshold) {
+			continue
+		}
+
+		// 画像と関連する記録の削除は障害物の削除と同じトランザクションでoutboxに記録し、削除の確定後に実行する
+		outbox, err := db.NewPurgeObstacleEntry(obstacle.ID, obstacleImageKeys(obstacle))
+		if err != nil {
+			response.FailedIDs = append(response.FailedIDs, obstacle.ID)
+			continue
+		}
+		if _, err := obstacleRepo.Delete(ctx, obstacle.ID, &obstacle.Version, audit, outbox); err != nil {
+			response.FailedIDs = append(response.FailedIDs, obstacle.ID)
+			continue
+		}
+		runOutbox(ctx, outbox)
+		response.PurgedIDs = append(response.PurgedIDs, obstacle.ID)
+	}
+
+	return response, http.StatusOK, nil
+}
+
+// obstacleImageKeys は障害物に添付されたすべての画像と縮小版のS3キーを返す
+func obstacleImageKeys(obstacle *db.Obstacle) []string {
+	var keys []string
+	for _, image := range obstacle.Images {
+		keys = append(keys, image.S3Keys()...)
+	}
+	return keys
+}
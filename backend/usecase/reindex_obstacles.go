@@ -0,0 +1,37 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+
+	"webhook/domain/db"
+	"webhook/usecase/output"
+)
+
+// ReindexObstacles rebuilds the search index of every obstacle (including the trash) from its current description,
+// and writes the geohash keys that the location index is built from.
+// Both indexes are kept up to date on every change, so this is only needed for obstacles created before the indexes
+// existed or after restoring the obstacle table
+func ReindexObstacles(ctx context.Context) (*output.ReindexObstaclesResponse, int, error) {
+	obstacleRepo, err := db.NewObstacleRepo(ctx)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	response := &output.ReindexObstaclesResponse{FailedIDs: []int{}}
+	for obstacle, err := range obstacleRepo.All(ctx) {
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		if _, err := obstacleRepo.IndexLocation(ctx, obstacle); err != nil {
+			response.FailedIDs = append(response.FailedIDs, obstacle.ID)
+			continue
+		}
+		if _, err := obstacleRepo.Reindex(ctx, obstacle.ID); err != nil {
+			response.FailedIDs = append(response.FailedIDs, obstacle.ID)
+			continue
+		}
+		response.Indexed++
+	}
+	return response, http.StatusOK, nil
+}
@@ -0,0 +1,61 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"webhook/domain/db"
+	"webhook/usecase/adaptor"
+	"webhook/usecase/input"
+	"webhook/usecase/output"
+)
+
+// ReorderObstacleImages changes the display order of the images of an obstacle
+// ImageIDs must list every image of the obstacle exactly once
+func ReorderObstacleImages(ctx context.Context, input input.ObstacleImageReorder) (*output.Obstacle, int, error) {
+	id, err := strconv.Atoi(input.ID)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	obstacleRepo, err := db.NewObstacleRepo(ctx)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	ob, statusCode, err := obstacleRepo.Get(ctx, id)
+	if err != nil {
+		return nil, statusCode, err
+	}
+	if ob == nil || ob.IsDeleted() {
+		return nil, http.StatusNotFound, nil
+	}
+	if statusCode, err := checkExpectedVersion(ob, input.ExpectedVersion); err != nil {
+		return nil, statusCode, err
+	}
+
+	if len(input.ImageIDs) != len(ob.Images) {
+		return nil, http.StatusBadRequest, fmt.Errorf("image_ids must list all %d images", len(ob.Images))
+	}
+	seen := map[string]bool{}
+	for order, imageID := range input.ImageIDs {
+		image := ob.FindImage(imageID)
+		if image == nil || seen[imageID] {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid or duplicated image id: %s", imageID)
+		}
+		seen[imageID] = true
+		image.SortOrder = order
+	}
+	ob.SortImages()
+	ob.UpdatedAt = time.Now().Format(time.RFC3339)
+
+	statusCode, err = obstacleRepo.CreateOrUpdate(ctx, ob, adaptor.ToDBAudit(input.Audit))
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	apiObstacle := adaptor.FromDBObstacle(ob)
+	return &apiObstacle, http.StatusOK, nil
+}
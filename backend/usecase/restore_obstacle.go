@@ -0,0 +1,54 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"webhook/domain/db"
+	"webhook/usecase/adaptor"
+	"webhook/usecase/input"
+	"webhook/usecase/output"
+)
+
+// RestoreObstacle moves an obstacle back out of the trash
+func RestoreObstacle(ctx context.Context, input input.ObstacleRestore) (*output.Obstacle, int, error) {
+	id, err := strconv.Atoi(input.ID)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	obstacleRepo, err := db.NewObstacleRepo(ctx)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	ob, statusCode, err := obstacleRepo.Get(ctx, id)
+	if err != nil {
+		return nil, statusCode, err
+	}
+	if ob == nil {
+		return nil, http.StatusNotFound, nil
+	}
+	if !ob.IsDeleted() {
+		return nil, http.StatusConflict, fmt.Errorf("obstacle %d is not in the trash", id)
+	}
+	// 統合された障害物の画像・確認は統合先に移っているため、統合先を使う
+	if ob.MergedInto != 0 {
+		return nil, http.StatusConflict, fmt.Errorf("obstacle %d was merged into obstacle %d", id, ob.MergedInto)
+	}
+	if statusCode, err := checkExpectedVersion(ob, input.ExpectedVersion); err != nil {
+		return nil, statusCode, err
+	}
+
+	ob.DeletedAt = ""
+	ob.UpdatedAt = time.Now().Format(time.RFC3339)
+	statusCode, err = obstacleRepo.CreateOrUpdate(ctx, ob, adaptor.ToDBAudit(input.Audit))
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	apiObstacle := adaptor.FromDBObstacle(ob)
+	return &apiObstacle, http.StatusOK, nil
+}
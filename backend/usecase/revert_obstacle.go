@@ -0,0 +1,72 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"webhook/domain/db"
+	"webhook/usecase/adaptor"
+	"webhook/usecase/input"
+	"webhook/usecase/output"
+)
+
+// RevertObstacle restores an obstacle to the state recorded by one of its revisions
+func RevertObstacle(ctx context.Context, input input.ObstacleRevert) (*output.Obstacle, int, error) {
+	id, err := strconv.Atoi(input.ID)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	revisionRepo, err := db.NewRevisionRepo(ctx)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	revision, statusCode, err := revisionRepo.Get(ctx, id, input.RevisionID)
+	if err != nil {
+		return nil, statusCode, err
+	}
+	if revision == nil {
+		return nil, http.StatusNotFound, nil
+	}
+	// 削除の履歴には復元先のスナップショットがない
+	if revision.After == nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("revision %d records a deletion and cannot be reverted to", input.RevisionID)
+	}
+
+	obstacleRepo, err := db.NewObstacleRepo(ctx)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	current, statusCode, err := obstacleRepo.Get(ctx, id)
+	if err != nil {
+		return nil, statusCode, err
+	}
+	// 完全削除・ゴミ箱の障害物は復元（restore）を先に行う
+	if current == nil || current.IsDeleted() {
+		return nil, http.StatusConflict, fmt.Errorf("obstacle %d is deleted and must be restored before reverting", id)
+	}
+	if statusCode, err := checkExpectedVersion(current, input.ExpectedVersion); err != nil {
+		return nil, statusCode, err
+	}
+
+	// 利用者が編集できる項目のみを戻し、状態・画像・確認の集計・統合の記録などは現在の値を残す
+	after := revision.After
+	current.Position = after.Position
+	current.Type = after.Type
+	current.Description = after.Description
+	current.DangerLevel = after.DangerLevel
+	current.Nodes = after.Nodes
+	current.NearestDistance = after.NearestDistance
+	current.NoNearbyRoad = after.NoNearbyRoad
+	current.UpdatedAt = time.Now().Format(time.RFC3339)
+	statusCode, err = obstacleRepo.CreateOrUpdate(ctx, current, adaptor.ToDBAudit(input.Audit))
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	apiObstacle := adaptor.FromDBObstacle(current)
+	return &apiObstacle, http.StatusOK, nil
+}
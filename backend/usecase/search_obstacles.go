@@ -0,0 +1,193 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"webhook/domain/db"
+	"webhook/shared/search"
+	"webhook/usecase/adaptor"
+	"webhook/usecase/input"
+	"webhook/usecase/output"
+)
+
+// 関連度（BM25）の係数
+const (
+	searchK1 = 1.2  // 出現回数による加点が飽和する速さ
+	searchB  = 0.75 // 説明の長さによる補正の強さ
+)
+
+// searchFetchSize は関連度の高い順に障害物を取得する1回あたりの件数
+const searchFetchSize = 100
+
+// searchCandidate は索引のトークンをすべて含む障害物
+type searchCandidate struct {
+	id     int
+	counts []int // 検索のトークンごとの出現回数
+	length int
+	score  float64
+}
+
+// SearchObstacles finds obstacles whose description contains every term of the query, ranked by relevance.
+// Descriptions are indexed as character unigrams and bigrams, so Japanese text is matched without word
+// boundaries. The list filters narrow the results, and a sort order replaces the relevance order
+func SearchObstacles(ctx context.Context, input input.ObstacleSearch) (*output.SearchObstacleResponse, int, error) {
+	terms := search.Terms(input.Query)
+	var tokens []string
+	seen := map[string]bool{}
+	for _, term := range terms {
+		for _, token := range search.QueryTokens(term) {
+			if !seen[token] {
+				seen[token] = true
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, http.StatusBadRequest, fmt.Errorf("q must contain a letter or a number")
+	}
+	limit, err := parseListLimit(input.Filter.Limit)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	filter, err := newObstacleFilter(input.Filter)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	searchIndexRepo, err := db.NewSearchIndexRepo(ctx)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	candidates, statusCode, err := searchCandidates(ctx, searchIndexRepo, tokens)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	// インデックスは確定後に更新するため、説明を読み直して検索語を含むことを確かめる
+	obstacleRepo, err := db.NewObstacleRepo(ctx)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	scores := map[int]float64{}
+	var matched []*db.Obstacle
+	for start := 0; start < len(candidates); start += searchFetchSize {
+		// 関連度の順で返す場合は1ページ分がそろった時点で打ち切る
+		if filter.sort == "" && len(matched) >= limit {
+			break
+		}
+		batch := candidates[start:min(start+searchFetchSize, len(candidates))]
+		ids := make([]int, len(batch))
+		for i, candidate := range batch {
+			ids[i] = candidate.id
+			scores[candidate.id] = candidate.score
+		}
+		obstacles, statusCode, err := obstacleRepo.BatchGet(ctx, ids)
+		if err != nil {
+			return nil, statusCode, err
+		}
+		for _, obstacle := range obstacles {
+			if containsTerms(obstacle.Description, terms) && filter.matches(obstacle) {
+				matched = append(matched, obstacle)
+			}
+		}
+		if filter.sort == "" {
+			sortByScore(matched, scores)
+		}
+	}
+	if filter.sort != "" {
+		filter.sortObstacles(matched)
+	}
+	if len(matched) > limit {
+		matched = matched[:limit]
+	}
+
+	var apiObstacles []output.Obstacle
+	for _, obstacle := range matched {
+		apiObstacles = append(apiObstacles, adaptor.FromDBObstacle(obstacle))
+	}
+	if err := withImageURLs(apiObstacles); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	response := &output.SearchObstacleResponse{Items: []output.ObstacleSearchResult{}}
+	for _, apiObstacle := range apiObstacles {
+		response.Items = append(response.Items, output.ObstacleSearchResult{
+			Obstacle: apiObstacle,
+			Score:    scores[apiObstacle.ID],
+		})
+	}
+	return response, http.StatusOK, nil
+}
+
+// searchCandidates は検索のトークンをすべて含む障害物を関連度の高い順に返す
+// すべての候補が同じトークンを含むため、BM25の逆文書頻度は順位に影響せず、出現回数と説明の長さで順位を決める
+// 説明の平均の長さは、全障害物の代わりに候補の平均で近似する
+func searchCandidates(ctx context.Context, searchIndexRepo *db.SearchIndexRepo, tokens []string) ([]*searchCandidate, int, error) {
+	var candidates map[int]*searchCandidate
+	for i, token := range tokens {
+		postings, statusCode, err := searchIndexRepo.Postings(ctx, token)
+		if err != nil {
+			return nil, statusCode, err
+		}
+		next := map[int]*searchCandidate{}
+		for _, posting := range postings {
+			candidate, ok := candidates[posting.ObstacleID]
+			if i == 0 {
+				candidate = &searchCandidate{id: posting.ObstacleID, length: posting.Length}
+			} else if !ok {
+				continue
+			}
+			candidate.counts = append(candidate.counts, posting.Count)
+			next[posting.ObstacleID] = candidate
+		}
+		candidates = next
+		if len(candidates) == 0 {
+			return nil, http.StatusOK, nil
+		}
+	}
+
+	totalLength := 0
+	for _, candidate := range candidates {
+		totalLength += candidate.length
+	}
+	averageLength := float64(totalLength) / float64(len(candidates))
+
+	result := make([]*searchCandidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		norm := searchK1 * (1 - searchB + searchB*float64(candidate.length)/averageLength)
+		for _, count := range candidate.counts {
+			candidate.score += float64(count) * (searchK1 + 1) / (float64(count) + norm)
+		}
+		result = append(result, candidate)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].score != result[j].score {
+			return result[i].score > result[j].score
+		}
+		return result[i].id < result[j].id
+	})
+	return result, http.StatusOK, nil
+}
+
+// containsTerms は説明が検索語をすべて含むかを返す
+func containsTerms(description string, terms []string) bool {
+	normalized := search.Normalize(description)
+	for _, term := range terms {
+		if !search.Contains(normalized, term) {
+			return false
+		}
+	}
+	return true
+}
+
+// sortByScore は障害物を関連度の高い順に並べる（同じ関連度の場合はIDの順）
+func sortByScore(obstacles []*db.Obstacle, scores map[int]float64) {
+	sort.SliceStable(obstacles, func(i, j int) bool {
+		if scores[obstacles[i].ID] != scores[obstacles[j].ID] {
+			return scores[obstacles[i].ID] > scores[obstacles[j].ID]
+		}
+		return obstacles[i].ID < obstacles[j].ID
+	})
+}
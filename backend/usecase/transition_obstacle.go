@@ -0,0 +1,81 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"webhook/domain/db"
+	"webhook/usecase/adaptor"
+	"webhook/usecase/input"
+	"webhook/usecase/output"
+)
+
+// obstacleTransitions は各状態から遷移可能な状態の一覧
+var obstacleTransitions = map[db.ObstacleStatus][]db.ObstacleStatus{
+	db.ObstacleStatusReported: {db.ObstacleStatusVerified, db.ObstacleStatusRejected},
+	db.ObstacleStatusVerified: {db.ObstacleStatusInRepair, db.ObstacleStatusResolved, db.ObstacleStatusRejected},
+	db.ObstacleStatusInRepair: {db.ObstacleStatusVerified, db.ObstacleStatusResolved},
+	db.ObstacleStatusResolved: {db.ObstacleStatusReported},
+	db.ObstacleStatusRejected: {db.ObstacleStatusReported},
+}
+
+// TransitionObstacle changes the status of an obstacle and records the transition
+func TransitionObstacle(ctx context.Context, input input.ObstacleTransition) (*output.Obstacle, int, error) {
+	id, err := strconv.Atoi(input.ID)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if input.Actor == "" {
+		return nil, http.StatusBadRequest, fmt.Errorf("X-Actor header is required")
+	}
+	to := db.ObstacleStatus(input.Status)
+	if _, ok := obstacleTransitions[to]; !ok {
+		return nil, http.StatusBadRequest, fmt.Errorf("unknown status: %q", input.Status)
+	}
+
+	obstacleRepo, err := db.NewObstacleRepo(ctx)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	ob, statusCode, err := obstacleRepo.Get(ctx, id)
+	if err != nil {
+		return nil, statusCode, err
+	}
+	if ob == nil || ob.IsDeleted() {
+		return nil, http.StatusNotFound, nil
+	}
+
+	from := ob.CurrentStatus()
+	if !canTransition(from, to) {
+		return nil, http.StatusConflict, fmt.Errorf("cannot transition obstacle from %s to %s", from, to)
+	}
+
+	ob.Status = to
+	ob.Transitions = append(ob.Transitions, db.ObstacleTransition{
+		From:       from,
+		To:         to,
+		Actor:      input.Actor,
+		OnBehalfOf: input.OnBehalfOf,
+		Comment:    input.Comment,
+		CreatedAt:  time.Now().Format(time.RFC3339),
+	})
+	statusCode, err = obstacleRepo.CreateOrUpdate(ctx, ob, adaptor.ToDBAudit(input.Audit))
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	apiObstacle := adaptor.FromDBObstacle(ob)
+	return &apiObstacle, http.StatusOK, nil
+}
+
+func canTransition(from, to db.ObstacleStatus) bool {
+	for _, next := range obstacleTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
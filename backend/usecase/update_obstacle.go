@@ -29,28 +29,28 @@ func UpdateObstacle(ctx context.Context, input input.ObstacleUpdate) (*output.Ob
 	if err != nil {
 		return nil, statusCode, err
 	}
-	if ob == nil {
+	if ob == nil || ob.IsDeleted() {
 		return nil, http.StatusNotFound, nil
 	}
-
-	// Update the obstacle
-	obstacle := db.Obstacle{
-		ID:          id,
-		Position:    input.Position,
-		Type:        input.Type,
-		Description: input.Description,
-		DangerLevel: input.DangerLevel,
-		Nodes:       input.Nodes,
-		NearestDistance: input.NearestDistance,
-		NoNearbyRoad:  input.NoNearbyRoad,
-		CreatedAt:   time.Now().Format(time.RFC3339), // Update the timestamp
+	if statusCode, err := checkExpectedVersion(ob, input.ExpectedVersion); err != nil {
+		return nil, statusCode, err
 	}
 
-	statusCode, err = obstacleRepo.CreateOrUpdate(ctx, &obstacle)
+	// Update the obstacle, keeping the fields not managed by this endpoint
+	ob.Position = input.Position
+	ob.Type = input.Type
+	ob.Description = input.Description
+	ob.DangerLevel = input.DangerLevel
+	ob.Nodes = input.Nodes
+	ob.NearestDistance = input.NearestDistance
+	ob.NoNearbyRoad = input.NoNearbyRoad
+	ob.UpdatedAt = time.Now().Format(time.RFC3339)
+
+	statusCode, err = obstacleRepo.CreateOrUpdate(ctx, ob, adaptor.ToDBAudit(input.Audit))
 	if err != nil {
 		return nil, statusCode, err
 	}
 
-	apiObstacle := adaptor.FromDBObstacle(&obstacle)
+	apiObstacle := adaptor.FromDBObstacle(ob)
 	return &apiObstacle, http.StatusOK, nil
 }
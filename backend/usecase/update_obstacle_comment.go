@@ -0,0 +1,59 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"webhook/domain/db"
+	"webhook/usecase/adaptor"
+	"webhook/usecase/input"
+	"webhook/usecase/output"
+)
+
+// UpdateObstacleComment edits the body of a comment. Only the author can edit it
+func UpdateObstacleComment(ctx context.Context, input input.ObstacleCommentUpdate) (*output.ObstacleComment, int, error) {
+	if input.Actor == "" {
+		return nil, http.StatusBadRequest, fmt.Errorf("actor is required")
+	}
+	body, err := commentBody(input.Body)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	ob, statusCode, err := getActiveObstacle(ctx, input.ID)
+	if err != nil || ob == nil {
+		return nil, statusCode, err
+	}
+
+	commentRepo, err := db.NewCommentRepo(ctx)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	comment, statusCode, err := commentRepo.Get(ctx, ob.ID, input.CommentID)
+	if err != nil || comment == nil {
+		return nil, statusCode, err
+	}
+	if comment.Author != input.Actor {
+		return nil, http.StatusForbidden, fmt.Errorf("only the author can edit the comment")
+	}
+	if comment.IsDeleted() {
+		return nil, http.StatusConflict, fmt.Errorf("comment %s has been deleted", comment.CommentID)
+	}
+
+	if comment.Body != body {
+		previousUpdatedAt := comment.UpdatedAt
+		now := time.Now().Format(time.RFC3339)
+		comment.Body = body
+		comment.EditedAt = now
+		comment.UpdatedAt = now
+		statusCode, err = commentRepo.Update(ctx, comment, previousUpdatedAt)
+		if err != nil {
+			return nil, statusCode, err
+		}
+	}
+
+	apiComment := adaptor.FromDBComment(comment)
+	return &apiComment, http.StatusOK, nil
+}
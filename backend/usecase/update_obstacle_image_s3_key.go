@@ -2,18 +2,23 @@ package usecase
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"webhook/domain/db"
 	"webhook/domain/s3"
+	"webhook/shared/imaging"
 	"webhook/usecase/adaptor"
 	"webhook/usecase/input"
 	"webhook/usecase/output"
 )
 
-// UpdateObstacleImageS3Key updates the image_s3_key of an obstacle
+// UpdateObstacleImageS3Key attaches an uploaded image to an obstacle, keeping the images attached earlier,
+// strips its metadata and generates its thumbnails. The key must belong to the obstacle and the object must exist
 func UpdateObstacleImageS3Key(ctx context.Context, input input.ObstacleUpdateImageS3Key) (*output.Obstacle, int, error) {
 	id, err := strconv.Atoi(input.ID)
 	if err != nil {
@@ -28,25 +33,70 @@ func UpdateObstacleImageS3Key(ctx context.Context, input input.ObstacleUpdateIma
 	if err != nil {
 		return nil, statusCode, err
 	}
-	if ob == nil {
+	if ob == nil || ob.IsDeleted() {
 		return nil, http.StatusNotFound, nil
 	}
+	if statusCode, err := checkExpectedVersion(ob, input.ExpectedVersion); err != nil {
+		return nil, statusCode, err
+	}
+
+	s3Repo, err := s3.NewS3Repo()
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
 
-	// 既存画像があれば削除
-	if ob.ImageS3Key != "" && ob.ImageS3Key != input.ImageS3Key {
-		s3Repo, err := s3.NewS3Repo()
+	// 既存の画像は残したまま画像リストに追加する（登録済みのキーの場合は追加しない）
+	now := time.Now().Format(time.RFC3339)
+	changed := false
+	image := ob.FindImageByKey(input.ImageS3Key)
+	if image == nil {
+		// 他の障害物のプレフィックスのキーは登録しない
+		if !strings.HasPrefix(input.ImageS3Key, obstacleImageKeyPrefix(id)) {
+			return nil, http.StatusBadRequest, fmt.Errorf("image_s3_key %q does not belong to obstacle %d", input.ImageS3Key, id)
+		}
+		imageID, err := newImageID()
 		if err != nil {
 			return nil, http.StatusInternalServerError, err
 		}
-		_ = s3Repo.DeleteObject(ob.ImageS3Key)
+		ob.Images = append(ob.Images, db.ObstacleImage{
+			ID:        imageID,
+			S3Key:     input.ImageS3Key,
+			Uploader:  input.Actor,
+			SortOrder: ob.NextImageSortOrder(),
+			CreatedAt: now,
+		})
+		image = &ob.Images[len(ob.Images)-1]
+		changed = true
 	}
 
-	ob.ImageS3Key = input.ImageS3Key
-	ob.CreatedAt = time.Now().Format(time.RFC3339)
-	statusCode, err = obstacleRepo.CreateOrUpdate(ctx, ob)
-	if err != nil {
-		return nil, statusCode, err
+	// 未処理の画像はメタデータの除去とサムネイル生成を行う（POST /obstacles/{id}/imagesで登録した画像もここで確定する）
+	if image.ProcessedAt == "" {
+		exists, err := s3Repo.ObjectExists(image.S3Key)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		if !exists {
+			return nil, http.StatusBadRequest, fmt.Errorf("image %q has not been uploaded", image.S3Key)
+		}
+		if err := processImage(s3Repo, ob, image); err != nil {
+			if errors.Is(err, imaging.ErrUnsupportedFormat) {
+				return nil, http.StatusUnsupportedMediaType, err
+			}
+			return nil, http.StatusInternalServerError, err
+		}
+		changed = true
+	}
+
+	if changed {
+		ob.UpdatedAt = now
+		statusCode, err = obstacleRepo.CreateOrUpdate(ctx, ob, adaptor.ToDBAudit(input.Audit))
+		if err != nil {
+			return nil, statusCode, err
+		}
 	}
 	apiObstacle := adaptor.FromDBObstacle(ob)
+	if err := withImageURL(&apiObstacle); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
 	return &apiObstacle, http.StatusOK, nil
 }
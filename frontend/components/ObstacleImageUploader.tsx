@@ -89,22 +89,25 @@ const ObstacleImageUploader: React.FC<Props> = ({ obstacleId, onUploaded }) => {
         setPreview(URL.createObjectURL(fileObj));
         setUploading(true);
         try {
-            // 1. プリサインドURL取得
+            // 1. アップロード用のプリサインドPOSTを取得（S3キーはサーバーで生成される）
             const res = await fetch(`${API_BASE}/obstacles/${obstacleId}/image-upload`, {
                 method: "POST",
                 headers: { "Content-Type": "application/json" },
-                body: JSON.stringify({ filename: fileObj.name }),
+                body: JSON.stringify({ content_type: fileObj.type, filename: fileObj.name }),
             });
+            if (res.status === 415) throw new Error("JPEG・PNG・WebPの画像のみアップロードできます");
             if (!res.ok) throw new Error("プリサインドURL取得失敗");
-            const { url, image_s3_key } = await res.json();
+            const { url, fields, image_s3_key } = await res.json();
 
-            // 2. S3にPUTアップロード
-            const putRes = await fetch(url, {
-                method: "PUT",
-                body: fileObj,
-                headers: { "Content-Type": fileObj.type },
+            // 2. S3にフォームでアップロード（ファイルは最後の項目にする必要がある）
+            const form = new FormData();
+            Object.entries(fields as Record<string, string>).forEach(([key, value]) => form.append(key, value));
+            form.append("file", fileObj);
+            const postRes = await fetch(url, {
+                method: "POST",
+                body: form,
             });
-            if (!putRes.ok) throw new Error("S3アップロード失敗");
+            if (!postRes.ok) throw new Error("S3アップロード失敗");
 
             // 3. image_s3_keyをAPIで保存
             const saveRes = await fetch(`${API_BASE}/obstacles/${obstacleId}/image`, {
@@ -167,7 +170,7 @@ const ObstacleImageUploader: React.FC<Props> = ({ obstacleId, onUploaded }) => {
             <input
                 ref={fileInputRef}
                 type="file"
-                accept="image/*"
+                accept="image/jpeg,image/png,image/webp"
                 className="hidden"
                 onChange={handleFileChange}
                 disabled={uploading}
@@ -319,6 +319,7 @@ export default function ObstacleEditForm({ obstacle, onSubmit, onCancel, onNeare
                 onSubmit({
                   ...obstacle,
                   image_s3_key: updatedObstacle.image_s3_key,
+                  image_url: updatedObstacle.image_url,
                   createdAt: updatedObstacle.createdAt,
                 });
               }}
@@ -248,9 +248,9 @@ export default function ObstacleMapContainer({ mode }: ObstacleMapContainerProps
             </CardHeader>
             <CardContent>
               {/* 画像表示 */}
-              {selectedObstacle.image_s3_key && (
+              {selectedObstacle.image_url && (
                 <img
-                  src={`https://${process.env.NEXT_PUBLIC_S3_BUCKET}.s3.${process.env.NEXT_PUBLIC_S3_REGION}.amazonaws.com/${selectedObstacle.image_s3_key}?t=${selectedObstacle.createdAt ? new Date(selectedObstacle.createdAt).getTime() : ''}`}
+                  src={selectedObstacle.image_url}
                   alt="障害物画像"
                   className="mb-2 max-w-full max-h-96 object-contain border rounded"
                 />
@@ -22,5 +22,6 @@ export interface Obstacle {
   nearestDistance?: number // Optional for backward compatibility
   createdAt?: string // ISO date string - Optional for backward compatibility
   image_s3_key?: string
+  image_url?: string // 画像の期限付きの閲覧用URL
   noNearbyRoad?: boolean // 近くに道路がないことを示すフラグ
 }
@@ -22,7 +22,8 @@ interface UpdateObstacleRequest {
 }
 
 interface ListObstacleResponse {
-  items: Obstacle[];
+  items: Obstacle[] | null;
+  next_cursor?: string;
 }
 
 export interface ApiResponse<T> {
@@ -54,22 +55,29 @@ async function handleResponse<T>(response: Response): Promise<ApiResponse<T>> {
 }
 
 export const obstacleApi = {
-  // Get all obstacles
+  // Get all obstacles (follows next_cursor until the last page)
   async getAll(): Promise<ApiResponse<Obstacle[]>> {
     try {
-      const response = await fetch(`${API_BASE_URL}/obstacles`);
-      const apiResponse = await handleResponse<ListObstacleResponse>(response);
-      
-      if (apiResponse.data) {
-        return {
-          data: apiResponse.data.items,
-          statusCode: apiResponse.statusCode
-        };
-      }
-      
+      const obstacles: Obstacle[] = [];
+      let cursor: string | undefined;
+      do {
+        const query = cursor ? `?cursor=${encodeURIComponent(cursor)}` : '';
+        const response = await fetch(`${API_BASE_URL}/obstacles${query}`);
+        const apiResponse = await handleResponse<ListObstacleResponse>(response);
+
+        if (!apiResponse.data) {
+          return {
+            error: apiResponse.error,
+            statusCode: apiResponse.statusCode
+          };
+        }
+        obstacles.push(...(apiResponse.data.items ?? []));
+        cursor = apiResponse.data.next_cursor;
+      } while (cursor);
+
       return {
-        error: apiResponse.error,
-        statusCode: apiResponse.statusCode
+        data: obstacles,
+        statusCode: 200
       };
     } catch (error) {
       return { 